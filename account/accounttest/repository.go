@@ -0,0 +1,140 @@
+// Package accounttest provides a contract test suite that every
+// account.Repository implementation must pass.
+package accounttest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/segmentio/ksuid"
+	"github.com/valkyraycho/go-microservices/account"
+)
+
+// RunRepositoryTests runs the contract suite against repositories returned by
+// newRepository, which must return an empty repository on every call.
+func RunRepositoryTests(t *testing.T, newRepository func(t *testing.T) account.Repository) {
+	t.Run("Ping", func(t *testing.T) {
+		r := newRepository(t)
+
+		if err := r.Ping(context.Background()); err != nil {
+			t.Errorf("Ping: %v", err)
+		}
+	})
+
+	t.Run("GetAccountByID", func(t *testing.T) {
+		r := newRepository(t)
+		ctx := context.Background()
+
+		want := account.Account{ID: ksuid.New().String(), Name: "alice"}
+		if err := r.CreateAccount(ctx, want); err != nil {
+			t.Fatalf("CreateAccount: %v", err)
+		}
+
+		got, err := r.GetAccountByID(ctx, want.ID)
+		if err != nil {
+			t.Fatalf("GetAccountByID: %v", err)
+		}
+		if *got != want {
+			t.Errorf("GetAccountByID = %+v, want %+v", *got, want)
+		}
+	})
+
+	t.Run("GetAccountByIDNotFound", func(t *testing.T) {
+		r := newRepository(t)
+
+		_, err := r.GetAccountByID(context.Background(), ksuid.New().String())
+		if !errors.Is(err, account.ErrNotFound) {
+			t.Errorf("GetAccountByID error = %v, want %v", err, account.ErrNotFound)
+		}
+	})
+
+	t.Run("ListAccountsByIDs", func(t *testing.T) {
+		r := newRepository(t)
+		ctx := context.Background()
+
+		ids := make([]string, 3)
+		for i := range ids {
+			a := account.Account{ID: ksuid.New().String(), Name: "account"}
+			if err := r.CreateAccount(ctx, a); err != nil {
+				t.Fatalf("CreateAccount: %v", err)
+			}
+			ids[i] = a.ID
+		}
+
+		accounts, err := r.ListAccountsByIDs(ctx, []string{ids[2], ksuid.New().String(), ids[0]})
+		if err != nil {
+			t.Fatalf("ListAccountsByIDs: %v", err)
+		}
+
+		// Unknown ids are skipped and the rest keep the requested order.
+		got := make([]string, len(accounts))
+		for i, a := range accounts {
+			got[i] = a.ID
+		}
+		if want := []string{ids[2], ids[0]}; !slices.Equal(got, want) {
+			t.Errorf("ListAccountsByIDs = %v, want %v", got, want)
+		}
+
+		none, err := r.ListAccountsByIDs(ctx, nil)
+		if err != nil {
+			t.Fatalf("ListAccountsByIDs: %v", err)
+		}
+		if none == nil || len(none) != 0 {
+			t.Errorf("ListAccountsByIDs(nil) = %v, want an empty slice", none)
+		}
+	})
+
+	t.Run("ListAccounts", func(t *testing.T) {
+		r := newRepository(t)
+		ctx := context.Background()
+
+		ids := make([]string, 5)
+		for i := range ids {
+			a := account.Account{ID: ksuid.New().String(), Name: "account"}
+			if err := r.CreateAccount(ctx, a); err != nil {
+				t.Fatalf("CreateAccount: %v", err)
+			}
+			ids[i] = a.ID
+		}
+
+		// Accounts are listed by descending id.
+		slices.Sort(ids)
+		slices.Reverse(ids)
+
+		tests := []struct {
+			name string
+			skip uint64
+			take uint64
+			want []string
+		}{
+			{"all", 0, 10, ids},
+			{"first page", 0, 2, ids[:2]},
+			{"middle page", 2, 2, ids[2:4]},
+			{"last page", 4, 2, ids[4:]},
+			{"past the end", 5, 2, nil},
+			{"take nothing", 0, 0, nil},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				accounts, err := r.ListAccounts(ctx, tt.skip, tt.take)
+				if err != nil {
+					t.Fatalf("ListAccounts: %v", err)
+				}
+				if accounts == nil {
+					t.Fatal("ListAccounts returned a nil slice")
+				}
+
+				got := make([]string, len(accounts))
+				for i, a := range accounts {
+					got[i] = a.ID
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("ListAccounts(%d, %d) = %v, want %v", tt.skip, tt.take, got, tt.want)
+				}
+			})
+		}
+	})
+}
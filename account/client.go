@@ -4,6 +4,11 @@ import (
 	"context"
 
 	pb "github.com/valkyraycho/go-microservices/account/proto"
+	"github.com/valkyraycho/go-microservices/health"
+	"github.com/valkyraycho/go-microservices/ratelimit"
+	"github.com/valkyraycho/go-microservices/resilience"
+	"github.com/valkyraycho/go-microservices/telemetry"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
@@ -13,8 +18,25 @@ type Client struct {
 	service pb.AccountServiceClient
 }
 
-func NewClient(url string) (*Client, error) {
-	conn, err := grpc.NewClient(url, grpc.WithTransportCredentials(insecure.NewCredentials()))
+// ReadMethods are the AccountService methods that only read, so they are safe to
+// retry and hedge.
+var ReadMethods = []string{"GetAccount", "GetAccounts", "GetAccountsByIDs"}
+
+// NewClient connects to the service at url. Calls are traced and measured,
+// and made resilient to failures as set by policy. Extra options are applied
+// after the defaults, so they can override the transport.
+func NewClient(url string, policy resilience.Config, opts ...grpc.DialOption) (*Client, error) {
+	resilient, err := policy.DialOptions(pb.AccountService_ServiceDesc, ReadMethods...)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(telemetry.ClientMetrics.UnaryClientInterceptor(), telemetry.UnaryClientRequestID, ratelimit.UnaryClientInterceptor),
+		grpc.WithChainStreamInterceptor(telemetry.ClientMetrics.StreamClientInterceptor(), telemetry.StreamClientRequestID, ratelimit.StreamClientInterceptor),
+	}, resilient...), opts...)
+	conn, err := grpc.NewClient(url, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -26,6 +48,12 @@ func (c *Client) Close() {
 	c.conn.Close()
 }
 
+// Ping returns an error unless the account service reports that it is serving,
+// which it only does while its own dependencies are reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	return health.Ping(ctx, c.conn, pb.AccountService_ServiceDesc.ServiceName)
+}
+
 func (c *Client) PostAccount(ctx context.Context, name string) (*Account, error) {
 	res, err := c.service.PostAccount(ctx, &pb.PostAccountRequest{Name: name})
 	if err != nil {
@@ -54,3 +82,18 @@ func (c *Client) GetAccounts(ctx context.Context, skip uint64, take uint64) ([]A
 	}
 	return accounts, nil
 }
+
+// GetAccountsByIDs returns the accounts with the given ids in the same order,
+// leaving out ids that do not exist.
+func (c *Client) GetAccountsByIDs(ctx context.Context, ids []string) ([]Account, error) {
+	res, err := c.service.GetAccountsByIDs(ctx, &pb.GetAccountsByIDsRequest{Ids: ids})
+	if err != nil {
+		return nil, err
+	}
+	accounts := []Account{}
+
+	for _, a := range res.Accounts {
+		accounts = append(accounts, Account{ID: a.Id, Name: a.Name})
+	}
+	return accounts, nil
+}
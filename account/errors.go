@@ -0,0 +1,15 @@
+package account
+
+import (
+	"errors"
+
+	"github.com/valkyraycho/go-microservices/grpcerr"
+)
+
+// statusError reports a missing account as NotFound.
+func statusError(err error) error {
+	if errors.Is(err, ErrNotFound) {
+		return grpcerr.NotFound("account", "", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package account
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+type memoryRepository struct {
+	mu       sync.RWMutex
+	accounts map[string]Account
+}
+
+// NewMemoryRepository returns a Repository that keeps accounts in memory. It
+// is meant for tests and local runs without Postgres.
+func NewMemoryRepository() Repository {
+	return &memoryRepository{accounts: map[string]Account{}}
+}
+
+func (r *memoryRepository) Close() {}
+
+func (r *memoryRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (r *memoryRepository) CreateAccount(ctx context.Context, a Account) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.accounts[a.ID] = a
+	return nil
+}
+
+func (r *memoryRepository) GetAccountByID(ctx context.Context, id string) (*Account, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	a, ok := r.accounts[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &a, nil
+}
+
+func (r *memoryRepository) ListAccounts(ctx context.Context, skip uint64, take uint64) ([]Account, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := make([]Account, 0, len(r.accounts))
+	for _, a := range r.accounts {
+		all = append(all, a)
+	}
+
+	// Same order as the Postgres repository.
+	sort.Slice(all, func(i, j int) bool { return all[i].ID > all[j].ID })
+
+	accounts := []Account{}
+	for i := skip; i < uint64(len(all)) && i-skip < take; i++ {
+		accounts = append(accounts, all[i])
+	}
+	return accounts, nil
+}
+
+func (r *memoryRepository) ListAccountsByIDs(ctx context.Context, ids []string) ([]Account, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	accounts := []Account{}
+	for _, id := range ids {
+		if a, ok := r.accounts[id]; ok {
+			accounts = append(accounts, a)
+		}
+	}
+	return accounts, nil
+}
@@ -0,0 +1,14 @@
+package account_test
+
+import (
+	"testing"
+
+	"github.com/valkyraycho/go-microservices/account"
+	"github.com/valkyraycho/go-microservices/account/accounttest"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	accounttest.RunRepositoryTests(t, func(t *testing.T) account.Repository {
+		return account.NewMemoryRepository()
+	})
+}
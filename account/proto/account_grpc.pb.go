@@ -19,9 +19,10 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	AccountService_PostAccount_FullMethodName = "/account_service.AccountService/PostAccount"
-	AccountService_GetAccount_FullMethodName  = "/account_service.AccountService/GetAccount"
-	AccountService_GetAccounts_FullMethodName = "/account_service.AccountService/GetAccounts"
+	AccountService_PostAccount_FullMethodName      = "/account_service.AccountService/PostAccount"
+	AccountService_GetAccount_FullMethodName       = "/account_service.AccountService/GetAccount"
+	AccountService_GetAccounts_FullMethodName      = "/account_service.AccountService/GetAccounts"
+	AccountService_GetAccountsByIDs_FullMethodName = "/account_service.AccountService/GetAccountsByIDs"
 )
 
 // AccountServiceClient is the client API for AccountService service.
@@ -31,6 +32,7 @@ type AccountServiceClient interface {
 	PostAccount(ctx context.Context, in *PostAccountRequest, opts ...grpc.CallOption) (*PostAccountResponse, error)
 	GetAccount(ctx context.Context, in *GetAccountRequest, opts ...grpc.CallOption) (*GetAccountResponse, error)
 	GetAccounts(ctx context.Context, in *GetAccountsRequest, opts ...grpc.CallOption) (*GetAccountsResponse, error)
+	GetAccountsByIDs(ctx context.Context, in *GetAccountsByIDsRequest, opts ...grpc.CallOption) (*GetAccountsByIDsResponse, error)
 }
 
 type accountServiceClient struct {
@@ -71,6 +73,16 @@ func (c *accountServiceClient) GetAccounts(ctx context.Context, in *GetAccountsR
 	return out, nil
 }
 
+func (c *accountServiceClient) GetAccountsByIDs(ctx context.Context, in *GetAccountsByIDsRequest, opts ...grpc.CallOption) (*GetAccountsByIDsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetAccountsByIDsResponse)
+	err := c.cc.Invoke(ctx, AccountService_GetAccountsByIDs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AccountServiceServer is the server API for AccountService service.
 // All implementations must embed UnimplementedAccountServiceServer
 // for forward compatibility.
@@ -78,6 +90,7 @@ type AccountServiceServer interface {
 	PostAccount(context.Context, *PostAccountRequest) (*PostAccountResponse, error)
 	GetAccount(context.Context, *GetAccountRequest) (*GetAccountResponse, error)
 	GetAccounts(context.Context, *GetAccountsRequest) (*GetAccountsResponse, error)
+	GetAccountsByIDs(context.Context, *GetAccountsByIDsRequest) (*GetAccountsByIDsResponse, error)
 	mustEmbedUnimplementedAccountServiceServer()
 }
 
@@ -97,6 +110,9 @@ func (UnimplementedAccountServiceServer) GetAccount(context.Context, *GetAccount
 func (UnimplementedAccountServiceServer) GetAccounts(context.Context, *GetAccountsRequest) (*GetAccountsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetAccounts not implemented")
 }
+func (UnimplementedAccountServiceServer) GetAccountsByIDs(context.Context, *GetAccountsByIDsRequest) (*GetAccountsByIDsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAccountsByIDs not implemented")
+}
 func (UnimplementedAccountServiceServer) mustEmbedUnimplementedAccountServiceServer() {}
 func (UnimplementedAccountServiceServer) testEmbeddedByValue()                        {}
 
@@ -172,6 +188,24 @@ func _AccountService_GetAccounts_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _AccountService_GetAccountsByIDs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAccountsByIDsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AccountServiceServer).GetAccountsByIDs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AccountService_GetAccountsByIDs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AccountServiceServer).GetAccountsByIDs(ctx, req.(*GetAccountsByIDsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // AccountService_ServiceDesc is the grpc.ServiceDesc for AccountService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -191,6 +225,10 @@ var AccountService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetAccounts",
 			Handler:    _AccountService_GetAccounts_Handler,
 		},
+		{
+			MethodName: "GetAccountsByIDs",
+			Handler:    _AccountService_GetAccountsByIDs_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/account.proto",
@@ -3,23 +3,33 @@ package account
 import (
 	"context"
 	"database/sql"
+	"errors"
 
-	_ "github.com/lib/pq"
+	"github.com/XSAM/otelsql"
+	"github.com/lib/pq"
+	"github.com/valkyraycho/go-microservices/telemetry"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 type Repository interface {
 	Close()
+	// Ping returns an error when the store cannot be reached.
+	Ping(ctx context.Context) error
 	CreateAccount(ctx context.Context, a Account) error
 	GetAccountByID(ctx context.Context, id string) (*Account, error)
 	ListAccounts(ctx context.Context, skip uint64, take uint64) ([]Account, error)
+	ListAccountsByIDs(ctx context.Context, ids []string) ([]Account, error)
 }
 
+var ErrNotFound = errors.New("account not found")
+
 type postgresRepository struct {
-	db *sql.DB
+	db                *sql.DB
+	unregisterDBStats func()
 }
 
 func NewPostgresRepository(url string) (Repository, error) {
-	db, err := sql.Open("postgres", url)
+	db, err := otelsql.Open("postgres", url, otelsql.WithAttributes(semconv.DBSystemPostgreSQL))
 	if err != nil {
 		return nil, err
 	}
@@ -28,15 +38,16 @@ func NewPostgresRepository(url string) (Repository, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &postgresRepository{db}, nil
+	return &postgresRepository{db, telemetry.RegisterDBStats(db, "account")}, nil
 }
 
 func (r *postgresRepository) Close() {
+	r.unregisterDBStats()
 	r.db.Close()
 }
 
-func (r *postgresRepository) Ping() error {
-	return r.db.Ping()
+func (r *postgresRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
 }
 
 func (r *postgresRepository) CreateAccount(ctx context.Context, a Account) error {
@@ -48,7 +59,9 @@ func (r *postgresRepository) GetAccountByID(ctx context.Context, id string) (*Ac
 	row := r.db.QueryRowContext(ctx, "SELECT id, name FROM accounts WHERE id = $1", id)
 	a := &Account{}
 
-	if err := row.Scan(&a.ID, a.Name); err != nil {
+	if err := row.Scan(&a.ID, &a.Name); errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	} else if err != nil {
 		return nil, err
 	}
 	return a, nil
@@ -65,9 +78,10 @@ func (r *postgresRepository) ListAccounts(ctx context.Context, skip uint64, take
 
 	for rows.Next() {
 		a := &Account{}
-		if err := rows.Scan(&a.ID, a.Name); err == nil {
-			accounts = append(accounts, *a)
+		if err := rows.Scan(&a.ID, &a.Name); err != nil {
+			return nil, err
 		}
+		accounts = append(accounts, *a)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -76,3 +90,34 @@ func (r *postgresRepository) ListAccounts(ctx context.Context, skip uint64, take
 
 	return accounts, nil
 }
+
+func (r *postgresRepository) ListAccountsByIDs(ctx context.Context, ids []string) ([]Account, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, name FROM accounts WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := map[string]Account{}
+
+	for rows.Next() {
+		a := Account{}
+		if err := rows.Scan(&a.ID, &a.Name); err != nil {
+			return nil, err
+		}
+		found[a.ID] = a
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Keep the order of the requested ids and skip unknown ones.
+	accounts := []Account{}
+	for _, id := range ids {
+		if a, ok := found[id]; ok {
+			accounts = append(accounts, a)
+		}
+	}
+	return accounts, nil
+}
@@ -0,0 +1,54 @@
+package account_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/valkyraycho/go-microservices/account"
+	"github.com/valkyraycho/go-microservices/account/accounttest"
+	"github.com/valkyraycho/go-microservices/migrate"
+)
+
+// TestPostgresRepository runs against the database in TEST_DATABASE_URL and
+// truncates the accounts table, so it must not point at real data.
+func TestPostgresRepository(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := migrate.Up(context.Background(), url, account.Migrations); err != nil {
+		t.Fatal(err)
+	}
+
+	accounttest.RunRepositoryTests(t, func(t *testing.T) account.Repository {
+		if _, err := db.Exec("TRUNCATE accounts"); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := account.NewPostgresRepository(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(r.Close)
+		return r
+	})
+}
+
+func TestMigrations(t *testing.T) {
+	migrations, err := migrate.Load(account.Migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Error("no migrations are embedded")
+	}
+}
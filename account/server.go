@@ -4,8 +4,14 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"time"
 
 	pb "github.com/valkyraycho/go-microservices/account/proto"
+	"github.com/valkyraycho/go-microservices/graceful"
+	"github.com/valkyraycho/go-microservices/grpcerr"
+	"github.com/valkyraycho/go-microservices/health"
+	"github.com/valkyraycho/go-microservices/telemetry"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 )
@@ -15,17 +21,34 @@ type accountServer struct {
 	service Service
 }
 
-func ListenGRPC(s Service, port int) error {
+// ListenGRPC serves s on port until ctx is done, then waits up to
+// shutdownTimeout for the calls in flight to finish. The options are passed
+// to NewGRPCServer.
+func ListenGRPC(ctx context.Context, s Service, port int, shutdownTimeout time.Duration, opts ...grpc.ServerOption) error {
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 
 	if err != nil {
 		return err
 	}
 
-	server := grpc.NewServer()
+	return graceful.ServeGRPC(ctx, NewGRPCServer(s, opts...), lis, shutdownTimeout)
+}
+
+// NewGRPCServer returns a gRPC server with the account service registered, ready
+// to serve on any listener. Its health service reports SERVING while the
+// store can be reached. Extra options, such as the transport credentials,
+// are applied after the defaults.
+func NewGRPCServer(s Service, opts ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(append([]grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(telemetry.ServerMetrics.UnaryServerInterceptor(), telemetry.UnaryServerLogging, grpcerr.UnaryServerInterceptor(statusError)),
+		grpc.ChainStreamInterceptor(telemetry.StreamServerLogging),
+	}, opts...)...)
 	pb.RegisterAccountServiceServer(server, &accountServer{service: s})
+	health.Register(server, health.Checks{"store": s.Ping})
 	reflection.Register(server)
-	return server.Serve(lis)
+	telemetry.ServerMetrics.InitializeMetrics(server)
+	return server
 }
 
 func (s *accountServer) PostAccount(ctx context.Context, r *pb.PostAccountRequest) (*pb.PostAccountResponse, error) {
@@ -72,3 +95,20 @@ func (s *accountServer) GetAccounts(ctx context.Context, r *pb.GetAccountsReques
 	}
 	return &pb.GetAccountsResponse{Accounts: accounts}, nil
 }
+
+func (s *accountServer) GetAccountsByIDs(ctx context.Context, r *pb.GetAccountsByIDsRequest) (*pb.GetAccountsByIDsResponse, error) {
+	res, err := s.service.GetAccountsByIDs(ctx, r.Ids)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := []*pb.Account{}
+
+	for _, a := range res {
+		accounts = append(accounts, &pb.Account{
+			Id:   a.ID,
+			Name: a.Name,
+		})
+	}
+	return &pb.GetAccountsByIDsResponse{Accounts: accounts}, nil
+}
@@ -2,16 +2,28 @@ package account
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/segmentio/ksuid"
+	"github.com/valkyraycho/go-microservices/grpcerr"
 )
 
 type Service interface {
 	PostAccount(ctx context.Context, name string) (*Account, error)
 	GetAccount(ctx context.Context, id string) (*Account, error)
 	GetAccounts(ctx context.Context, skip uint64, take uint64) ([]Account, error)
+	GetAccountsByIDs(ctx context.Context, ids []string) ([]Account, error)
+	// Ping returns an error when the store behind the service cannot be
+	// reached.
+	Ping(ctx context.Context) error
 }
 
+// MaxNameLength is the longest name, in characters, the accounts table can
+// store.
+const MaxNameLength = 24
+
 type Account struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
@@ -26,6 +38,16 @@ func NewService(r Repository) Service {
 }
 
 func (s *accountService) PostAccount(ctx context.Context, name string) (*Account, error) {
+	var v grpcerr.Violations
+	if strings.TrimSpace(name) == "" {
+		v.Add("name", "must not be empty")
+	} else if utf8.RuneCountInString(name) > MaxNameLength {
+		v.Add("name", fmt.Sprintf("must be at most %d characters", MaxNameLength))
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
 	a := &Account{ID: ksuid.New().String(), Name: name}
 
 	if err := s.repository.CreateAccount(ctx, *a); err != nil {
@@ -43,3 +65,13 @@ func (s *accountService) GetAccounts(ctx context.Context, skip uint64, take uint
 	}
 	return s.repository.ListAccounts(ctx, skip, take)
 }
+func (s *accountService) GetAccountsByIDs(ctx context.Context, ids []string) ([]Account, error) {
+	if len(ids) == 0 {
+		return []Account{}, nil
+	}
+	return s.repository.ListAccountsByIDs(ctx, ids)
+}
+
+func (s *accountService) Ping(ctx context.Context) error {
+	return s.repository.Ping(ctx)
+}
@@ -0,0 +1,26 @@
+package account_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/valkyraycho/go-microservices/account"
+	"github.com/valkyraycho/go-microservices/grpcerr/grpcerrtest"
+)
+
+func TestPostAccountValidation(t *testing.T) {
+	s := account.NewService(account.NewMemoryRepository())
+
+	for _, name := range []string{"", "  ", strings.Repeat("é", account.MaxNameLength+1)} {
+		_, err := s.PostAccount(context.Background(), name)
+		if got := grpcerrtest.ViolatedFields(t, err); !reflect.DeepEqual(got, []string{"name"}) {
+			t.Errorf("PostAccount(%q) violated %v, want [name]", name, got)
+		}
+	}
+
+	if _, err := s.PostAccount(context.Background(), strings.Repeat("é", account.MaxNameLength)); err != nil {
+		t.Errorf("PostAccount with a name of %d characters: %v", account.MaxNameLength, err)
+	}
+}
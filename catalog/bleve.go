@@ -0,0 +1,228 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/v2/analysis/token/edgengram"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/single"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/highlight/highlighter/html"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+type bleveRepository struct {
+	index  bleve.Index
+	config SearchConfig
+}
+
+var productFields = []string{"name", "description", "price", "archived"}
+
+// NewBleveRepository opens the embedded index at path, creating it if it does
+// not exist yet. It is meant for local runs and CI where Elasticsearch is not
+// available.
+func NewBleveRepository(path string, config SearchConfig) (Repository, error) {
+	index, err := bleve.Open(path)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		index, err = bleve.New(path, newProductMapping())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &bleveRepository{index, config}, nil
+}
+
+func newProductMapping() mapping.IndexMapping {
+	m := bleve.NewIndexMapping()
+
+	// Suggest inputs are indexed as lowercased edge n-grams, so a prefix is a
+	// single term lookup that can also be matched fuzzily.
+	m.AddCustomTokenFilter("suggest_ngram", map[string]interface{}{
+		"type": edgengram.Name,
+		"min":  1.0,
+		"max":  50.0,
+	})
+	m.AddCustomAnalyzer("suggest", map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     single.Name,
+		"token_filters": []string{lowercase.Name, "suggest_ngram"},
+	})
+
+	textField := bleve.NewTextFieldMapping()
+	textField.Analyzer = "standard"
+
+	priceField := bleve.NewNumericFieldMapping()
+
+	archivedField := bleve.NewBooleanFieldMapping()
+	archivedField.IncludeInAll = false
+
+	suggestField := bleve.NewTextFieldMapping()
+	suggestField.Analyzer = "suggest"
+	suggestField.Store = false
+	suggestField.IncludeInAll = false
+
+	product := bleve.NewDocumentMapping()
+	product.AddFieldMappingsAt("name", textField)
+	product.AddFieldMappingsAt("description", textField)
+	product.AddFieldMappingsAt("price", priceField)
+	product.AddFieldMappingsAt("suggest", suggestField)
+	product.AddFieldMappingsAt("archived", archivedField)
+
+	m.DefaultMapping = product
+	return m
+}
+
+func (r *bleveRepository) Close() {
+	r.index.Close()
+}
+
+// Ping fails once the index is closed.
+func (r *bleveRepository) Ping(ctx context.Context) error {
+	_, err := r.index.DocCount()
+	return err
+}
+
+func (r *bleveRepository) CreateProduct(ctx context.Context, p Product) error {
+	return r.index.Index(p.ID, newProductDocument(p))
+}
+
+func (r *bleveRepository) GetProductByID(ctx context.Context, id string) (*Product, error) {
+	products, err := r.search(ctx, bleve.NewDocIDQuery([]string{id}), 0, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(products) == 0 {
+		return nil, ErrNotFound
+	}
+	return &products[0], nil
+}
+
+func (r *bleveRepository) ListProducts(ctx context.Context, skip uint64, take uint64) ([]Product, error) {
+	return r.search(ctx, bleve.NewMatchAllQuery(), skip, take)
+}
+
+func (r *bleveRepository) ListProductsByIDs(ctx context.Context, ids []string) ([]Product, error) {
+	found, err := r.search(ctx, bleve.NewDocIDQuery(ids), 0, uint64(len(ids)))
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]Product, len(found))
+	for _, p := range found {
+		byID[p.ID] = p
+	}
+
+	// Keep the order of the requested ids, like a multi-get does.
+	products := []Product{}
+	for _, id := range ids {
+		if p, ok := byID[id]; ok {
+			products = append(products, p)
+		}
+	}
+	return products, nil
+}
+
+func (r *bleveRepository) ArchiveProduct(ctx context.Context, id string) error {
+	p, err := r.GetProductByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	p.Archived = true
+	return r.index.Index(p.ID, newProductDocument(*p))
+}
+
+func (r *bleveRepository) SearchProducts(ctx context.Context, q string, skip uint64, take uint64) ([]SearchResult, error) {
+	expanded := r.config.Synonyms.Expand(q)
+
+	name := bleve.NewMatchQuery(expanded)
+	name.SetField("name")
+	name.SetBoost(r.config.NameBoost)
+
+	description := bleve.NewMatchQuery(expanded)
+	description.SetField("description")
+	description.SetBoost(r.config.DescriptionBoost)
+
+	req := bleve.NewSearchRequestOptions(bleve.NewDisjunctionQuery(name, description), int(take), int(skip), false)
+	req.Fields = productFields
+	req.SortBy([]string{"-_score", "_id"})
+	req.Highlight = bleve.NewHighlightWithStyle(html.Name)
+	req.Highlight.AddField("name")
+	req.Highlight.AddField("description")
+
+	res, err := r.index.SearchInContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []SearchResult{}
+	for _, hit := range res.Hits {
+		results = append(results, SearchResult{
+			Product:    productFromHit(hit),
+			Score:      hit.Score,
+			Highlights: sortHighlights(hit.Fragments),
+		})
+	}
+	return results, nil
+}
+
+func (r *bleveRepository) SuggestProducts(ctx context.Context, prefix string, take uint64) ([]Suggestion, error) {
+	q := bleve.NewFuzzyQuery(strings.ToLower(strings.TrimSpace(prefix)))
+	q.SetField("suggest")
+	q.SetFuzziness(suggestFuzziness(prefix))
+
+	products, err := r.search(ctx, q, 0, take)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := []Suggestion{}
+	for _, p := range products {
+		suggestions = append(suggestions, newSuggestion(p.ID, p.Name, prefix))
+	}
+	return suggestions, nil
+}
+
+func (r *bleveRepository) search(ctx context.Context, q query.Query, skip uint64, take uint64) ([]Product, error) {
+	req := bleve.NewSearchRequestOptions(q, int(take), int(skip), false)
+	req.Fields = productFields
+
+	// Match-all and id queries score every hit the same, so fall back to the
+	// document id to keep pagination stable.
+	req.SortBy([]string{"-_score", "_id"})
+
+	res, err := r.index.SearchInContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	products := []Product{}
+	for _, hit := range res.Hits {
+		products = append(products, productFromHit(hit))
+	}
+	return products, nil
+}
+
+func productFromHit(hit *search.DocumentMatch) Product {
+	p := Product{ID: hit.ID}
+	if name, ok := hit.Fields["name"].(string); ok {
+		p.Name = name
+	}
+	if description, ok := hit.Fields["description"].(string); ok {
+		p.Description = description
+	}
+	if price, ok := hit.Fields["price"].(float64); ok {
+		p.Price = price
+	}
+	if archived, ok := hit.Fields["archived"].(bool); ok {
+		p.Archived = archived
+	}
+	return p
+}
@@ -0,0 +1,55 @@
+package catalog_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/valkyraycho/go-microservices/catalog"
+	"github.com/valkyraycho/go-microservices/catalog/catalogtest"
+)
+
+func TestBleveRepository(t *testing.T) {
+	catalogtest.RunRepositoryTests(t, func(t *testing.T) catalog.Repository {
+		r, err := catalog.NewBleveRepository(filepath.Join(t.TempDir(), "catalog.bleve"), catalog.DefaultSearchConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(r.Close)
+		return r
+	})
+}
+
+func TestBleveRepositorySearchConfig(t *testing.T) {
+	config := catalog.SearchConfig{
+		NameBoost:        1,
+		DescriptionBoost: 10,
+		Synonyms:         catalog.Synonyms{"notebook": {"notebook", "laptop"}},
+	}
+	r, err := catalog.NewBleveRepository(filepath.Join(t.TempDir(), "catalog.bleve"), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	ctx := context.Background()
+	for _, p := range []catalog.Product{
+		{ID: "1", Name: "Laptop stand", Description: "Aluminium"},
+		{ID: "2", Name: "Sleeve", Description: "Fits any laptop"},
+		{ID: "3", Name: "Notebook", Description: "Paper"},
+	} {
+		if err := r.CreateProduct(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := r.SearchProducts(ctx, "notebook", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Synonyms widen the match and the description boost outweighs the name.
+	if len(results) != 3 || results[0].Product.ID != "2" {
+		t.Errorf("SearchProducts(notebook) = %+v, want all three products with 2 first", results)
+	}
+}
@@ -0,0 +1,165 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/redis/go-redis/v9"
+)
+
+// CacheConfig sets how the products a Client looks up by id are cached.
+// Processes read it from variables with the PRODUCT_CACHE_ prefix.
+type CacheConfig struct {
+	// TTL bounds how long a product is served from the cache. Archiving a
+	// product through a client drops it from that client's cache, so other
+	// processes may see it unarchived for up to TTL unless they share a
+	// Redis cache. Zero turns caching off.
+	TTL time.Duration `envconfig:"TTL" default:"1m"`
+	// Size bounds the number of products kept in memory.
+	Size int `envconfig:"SIZE" default:"10000"`
+	// RedisURL, such as redis://localhost:6379/0, keeps the products in
+	// Redis instead of in memory, so that processes share the cache.
+	RedisURL string `envconfig:"REDIS_URL"`
+}
+
+// ProductCache keeps products by id for a limited time.
+type ProductCache interface {
+	Close()
+	// GetProducts returns the products with the given ids that are cached,
+	// keyed by id.
+	GetProducts(ctx context.Context, ids []string) (map[string]Product, error)
+	SetProducts(ctx context.Context, products []Product) error
+	DeleteProducts(ctx context.Context, ids []string) error
+}
+
+// NewProductCache returns the cache cfg describes, or nil when caching is
+// off.
+func NewProductCache(cfg CacheConfig) (ProductCache, error) {
+	switch {
+	case cfg.TTL <= 0:
+		return nil, nil
+	case cfg.RedisURL != "":
+		return NewRedisCache(cfg.RedisURL, cfg.TTL)
+	default:
+		return NewMemoryCache(cfg.Size, cfg.TTL), nil
+	}
+}
+
+type memoryCache struct {
+	lru *expirable.LRU[string, Product]
+}
+
+// NewMemoryCache returns a ProductCache that keeps up to size products in
+// memory, evicting the least recently used first.
+func NewMemoryCache(size int, ttl time.Duration) ProductCache {
+	return &memoryCache{lru: expirable.NewLRU[string, Product](size, nil, ttl)}
+}
+
+func (c *memoryCache) Close() {}
+
+func (c *memoryCache) GetProducts(ctx context.Context, ids []string) (map[string]Product, error) {
+	products := map[string]Product{}
+	for _, id := range ids {
+		if p, ok := c.lru.Get(id); ok {
+			products[id] = p
+		}
+	}
+	return products, nil
+}
+
+func (c *memoryCache) SetProducts(ctx context.Context, products []Product) error {
+	for _, p := range products {
+		c.lru.Add(p.ID, p)
+	}
+	return nil
+}
+
+func (c *memoryCache) DeleteProducts(ctx context.Context, ids []string) error {
+	for _, id := range ids {
+		c.lru.Remove(id)
+	}
+	return nil
+}
+
+type redisCache struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewRedisCache returns a ProductCache that keeps products in the Redis
+// database at url, or any server that speaks its protocol, as JSON.
+func NewRedisCache(url string, ttl time.Duration) (ProductCache, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &redisCache{client: redis.NewClient(opts), ttl: ttl}, nil
+}
+
+func (c *redisCache) Close() {
+	c.client.Close()
+}
+
+func productKey(id string) string {
+	return "catalog:product:" + id
+}
+
+func (c *redisCache) GetProducts(ctx context.Context, ids []string) (map[string]Product, error) {
+	products := map[string]Product{}
+	if len(ids) == 0 {
+		return products, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = productKey(id)
+	}
+	values, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var p Product
+		if err := json.Unmarshal([]byte(s), &p); err != nil {
+			return nil, err
+		}
+		products[p.ID] = p
+	}
+	return products, nil
+}
+
+func (c *redisCache) SetProducts(ctx context.Context, products []Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	pipe := c.client.Pipeline()
+	for _, p := range products {
+		b, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, productKey(p.ID), b, c.ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *redisCache) DeleteProducts(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = productKey(id)
+	}
+	return c.client.Del(ctx, keys...).Err()
+}
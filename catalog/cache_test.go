@@ -0,0 +1,275 @@
+package catalog_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/valkyraycho/go-microservices/catalog"
+	"github.com/valkyraycho/go-microservices/resilience"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func testCache(t *testing.T, cache catalog.ProductCache, expire func()) {
+	ctx := context.Background()
+	a := catalog.Product{ID: "a", Name: "Keyboard", Price: 50}
+	b := catalog.Product{ID: "b", Name: "Mouse", Price: 20, Archived: true}
+
+	if err := cache.SetProducts(ctx, []catalog.Product{a, b}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := cache.GetProducts(ctx, []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["a"] != a || got["b"] != b {
+		t.Errorf("GetProducts = %v, want a and b", got)
+	}
+
+	if err := cache.DeleteProducts(ctx, []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := cache.GetProducts(ctx, []string{"a", "b"}); len(got) != 1 || got["b"] != b {
+		t.Errorf("GetProducts after deleting a = %v, want b", got)
+	}
+
+	expire()
+	if got, _ := cache.GetProducts(ctx, []string{"b"}); len(got) != 0 {
+		t.Errorf("GetProducts after the TTL = %v, want nothing", got)
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	testCache(t, catalog.NewMemoryCache(10, 50*time.Millisecond), func() {
+		time.Sleep(100 * time.Millisecond)
+	})
+}
+
+func TestRedisCache(t *testing.T) {
+	server := miniredis.RunT(t)
+	cache, err := catalog.NewRedisCache("redis://"+server.Addr(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	testCache(t, cache, func() { server.FastForward(time.Minute) })
+}
+
+func TestNewProductCache(t *testing.T) {
+	if cache, err := catalog.NewProductCache(catalog.CacheConfig{}); cache != nil || err != nil {
+		t.Errorf("NewProductCache without a TTL = %v, %v; want no cache", cache, err)
+	}
+	if _, err := catalog.NewProductCache(catalog.CacheConfig{TTL: time.Minute, RedisURL: "not a url"}); err == nil {
+		t.Error("NewProductCache accepted an invalid Redis URL")
+	}
+}
+
+// cachedClient serves the catalog with an in-memory repository over bufconn,
+// behind a client using cache. release, when not nil, holds every call to the
+// service until it is closed.
+type cachedClient struct {
+	*catalog.Client
+	release chan struct{}
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func newCachedClient(t *testing.T, cache catalog.ProductCache) *cachedClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	server := catalog.NewGRPCServer(catalog.NewService(catalog.NewMemoryRepository()))
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	c := &cachedClient{calls: map[string]int{}}
+	client, err := catalog.NewClient("passthrough:///catalog", resilience.Config{},
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithChainUnaryInterceptor(c.count),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	client.UseCache(cache)
+	c.Client = client
+	return c
+}
+
+func (c *cachedClient) count(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	c.mu.Lock()
+	c.calls[method]++
+	release := c.release
+	c.mu.Unlock()
+	if release != nil {
+		<-release
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+func (c *cachedClient) Calls(method string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[method]
+}
+
+const (
+	getProduct  = "/catalog_service.CatalogService/GetProduct"
+	getProducts = "/catalog_service.CatalogService/GetProducts"
+)
+
+func TestClientReadsThroughCache(t *testing.T) {
+	ctx := context.Background()
+	c := newCachedClient(t, catalog.NewMemoryCache(10, time.Minute))
+
+	a, err := c.PostProduct(ctx, "Keyboard", "", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.PostProduct(ctx, "Mouse", "", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := c.GetProducts(ctx, 0, 0, []string{a.ID}, ""); err != nil || len(got) != 1 {
+		t.Fatalf("GetProducts = %v, %v", got, err)
+	}
+	// Only b is missing from the cache now, and unknown ids are left out.
+	got, err := c.GetProducts(ctx, 0, 0, []string{b.ID, a.ID, "missing", a.ID}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != *b || got[1] != *a {
+		t.Errorf("GetProducts = %v, want b and a in the order asked", got)
+	}
+	if calls := c.Calls(getProducts); calls != 2 {
+		t.Errorf("GetProducts reached the service %d times, want 2", calls)
+	}
+
+	if _, err := c.GetProducts(ctx, 0, 0, []string{a.ID, b.ID}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := c.GetProduct(ctx, a.ID); err != nil || *p != *a {
+		t.Errorf("GetProduct = %v, %v; want %v", p, err, a)
+	}
+	if calls := c.Calls(getProducts) + c.Calls(getProduct); calls != 2 {
+		t.Errorf("lookups of cached products reached the service %d times in all, want 2", calls)
+	}
+
+	// Pages and searches are not cached.
+	for range 2 {
+		if _, err := c.GetProducts(ctx, 0, 10, nil, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := c.Calls(getProducts); calls != 4 {
+		t.Errorf("GetProducts reached the service %d times, want 4", calls)
+	}
+}
+
+func TestClientInvalidatesArchivedProducts(t *testing.T) {
+	ctx := context.Background()
+	c := newCachedClient(t, catalog.NewMemoryCache(10, time.Minute))
+
+	p, err := c.PostProduct(ctx, "Keyboard", "", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetProduct(ctx, p.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ArchiveProduct(ctx, p.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := c.GetProduct(ctx, p.ID); err != nil || !got.Archived {
+		t.Errorf("GetProduct after archiving = %v, %v; want it archived", got, err)
+	}
+	if got, err := c.GetProducts(ctx, 0, 0, []string{p.ID}, ""); err != nil || len(got) != 1 || !got[0].Archived {
+		t.Errorf("GetProducts after archiving = %v, %v; want it archived", got, err)
+	}
+}
+
+func TestClientSharesConcurrentLookups(t *testing.T) {
+	ctx := context.Background()
+	c := newCachedClient(t, catalog.NewMemoryCache(10, time.Minute))
+
+	a, err := c.PostProduct(ctx, "Keyboard", "", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.PostProduct(ctx, "Mouse", "", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.mu.Lock()
+	c.release = make(chan struct{})
+	c.mu.Unlock()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// The ids are listed in either order.
+			ids := []string{a.ID, b.ID}
+			if i%2 == 1 {
+				ids = []string{b.ID, a.ID}
+			}
+			products, err := c.GetProducts(ctx, 0, 0, ids, "")
+			if err == nil && len(products) != 2 {
+				t.Errorf("GetProducts = %v, want 2 products", products)
+			}
+			errs <- err
+		}()
+	}
+
+	// Let every lookup join the first before it is answered.
+	time.Sleep(50 * time.Millisecond)
+	close(c.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if calls := c.Calls(getProducts); calls != 1 {
+		t.Errorf("concurrent lookups reached the service %d times, want once", calls)
+	}
+}
+
+func TestClientSurvivesCacheFailures(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	cache, err := catalog.NewRedisCache("redis://"+server.Addr(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	c := newCachedClient(t, cache)
+
+	p, err := c.PostProduct(ctx, "Keyboard", "", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	if got, err := c.GetProducts(ctx, 0, 0, []string{p.ID}, ""); err != nil || len(got) != 1 {
+		t.Errorf("GetProducts with the cache down = %v, %v", got, err)
+	}
+	if _, err := c.ArchiveProduct(ctx, p.ID); err != nil {
+		t.Errorf("ArchiveProduct with the cache down: %v", err)
+	}
+}
@@ -0,0 +1,331 @@
+// Package catalogtest provides a contract test suite that every
+// catalog.Repository implementation must pass.
+package catalogtest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/segmentio/ksuid"
+	"github.com/valkyraycho/go-microservices/catalog"
+)
+
+// RunRepositoryTests runs the contract suite against repositories returned by
+// newRepository, which must return an empty repository on every call.
+func RunRepositoryTests(t *testing.T, newRepository func(t *testing.T) catalog.Repository) {
+	t.Run("Ping", func(t *testing.T) {
+		r := newRepository(t)
+
+		if err := r.Ping(context.Background()); err != nil {
+			t.Errorf("Ping: %v", err)
+		}
+	})
+
+	t.Run("GetProductByID", func(t *testing.T) {
+		r := newRepository(t)
+		ctx := context.Background()
+
+		want := catalog.Product{ID: ksuid.New().String(), Name: "Keyboard", Description: "Mechanical", Price: 89.5}
+		if err := r.CreateProduct(ctx, want); err != nil {
+			t.Fatalf("CreateProduct: %v", err)
+		}
+
+		got, err := r.GetProductByID(ctx, want.ID)
+		if err != nil {
+			t.Fatalf("GetProductByID: %v", err)
+		}
+		if *got != want {
+			t.Errorf("GetProductByID = %+v, want %+v", *got, want)
+		}
+	})
+
+	t.Run("GetProductByIDNotFound", func(t *testing.T) {
+		r := newRepository(t)
+
+		_, err := r.GetProductByID(context.Background(), ksuid.New().String())
+		if !errors.Is(err, catalog.ErrNotFound) {
+			t.Errorf("GetProductByID error = %v, want %v", err, catalog.ErrNotFound)
+		}
+	})
+
+	t.Run("ArchiveProduct", func(t *testing.T) {
+		r := newRepository(t)
+		ctx := context.Background()
+
+		ids := createProducts(t, r, []catalog.Product{{Name: "Keyboard", Price: 10}, {Name: "Mouse"}})
+		if err := r.ArchiveProduct(ctx, ids[0]); err != nil {
+			t.Fatalf("ArchiveProduct: %v", err)
+		}
+
+		got, err := r.GetProductByID(ctx, ids[0])
+		if err != nil {
+			t.Fatalf("GetProductByID: %v", err)
+		}
+		if want := (catalog.Product{ID: ids[0], Name: "Keyboard", Price: 10, Archived: true}); *got != want {
+			t.Errorf("GetProductByID = %+v, want %+v", *got, want)
+		}
+
+		products, err := r.ListProductsByIDs(ctx, ids)
+		if err != nil {
+			t.Fatalf("ListProductsByIDs: %v", err)
+		}
+		if len(products) != 2 || !products[0].Archived || products[1].Archived {
+			t.Errorf("ListProductsByIDs = %+v, want only %s archived", products, ids[0])
+		}
+	})
+
+	t.Run("ArchiveProductNotFound", func(t *testing.T) {
+		r := newRepository(t)
+
+		err := r.ArchiveProduct(context.Background(), ksuid.New().String())
+		if !errors.Is(err, catalog.ErrNotFound) {
+			t.Errorf("ArchiveProduct error = %v, want %v", err, catalog.ErrNotFound)
+		}
+	})
+
+	t.Run("ListProducts", func(t *testing.T) {
+		r := newRepository(t)
+		ctx := context.Background()
+
+		ids := createProducts(t, r, []catalog.Product{
+			{Name: "Keyboard"}, {Name: "Mouse"}, {Name: "Monitor"}, {Name: "Desk"}, {Name: "Chair"},
+		})
+
+		all, err := r.ListProducts(ctx, 0, 10)
+		if err != nil {
+			t.Fatalf("ListProducts: %v", err)
+		}
+		if got := productIDs(all); !sameIDs(got, ids) {
+			t.Fatalf("ListProducts(0, 10) = %v, want %v in any order", got, ids)
+		}
+
+		// Pages are taken from one stable order, so they never overlap and
+		// together cover every product.
+		var paged []string
+		for skip := uint64(0); skip < 6; skip += 2 {
+			page, err := r.ListProducts(ctx, skip, 2)
+			if err != nil {
+				t.Fatalf("ListProducts: %v", err)
+			}
+			if page == nil {
+				t.Fatal("ListProducts returned a nil slice")
+			}
+			if want := min(2, len(ids)-int(skip)); len(page) != want {
+				t.Fatalf("ListProducts(%d, 2) returned %d products, want %d", skip, len(page), want)
+			}
+			paged = append(paged, productIDs(page)...)
+		}
+		if !slices.Equal(paged, productIDs(all)) {
+			t.Errorf("paged ListProducts = %v, want %v", paged, productIDs(all))
+		}
+
+		empty, err := r.ListProducts(ctx, 5, 2)
+		if err != nil {
+			t.Fatalf("ListProducts: %v", err)
+		}
+		if empty == nil || len(empty) != 0 {
+			t.Errorf("ListProducts past the end = %v, want an empty slice", empty)
+		}
+	})
+
+	t.Run("ListProductsEmpty", func(t *testing.T) {
+		r := newRepository(t)
+
+		products, err := r.ListProducts(context.Background(), 0, 10)
+		if err != nil {
+			t.Fatalf("ListProducts: %v", err)
+		}
+		if products == nil || len(products) != 0 {
+			t.Errorf("ListProducts = %v, want an empty slice", products)
+		}
+	})
+
+	t.Run("ListProductsByIDs", func(t *testing.T) {
+		r := newRepository(t)
+		ctx := context.Background()
+
+		ids := createProducts(t, r, []catalog.Product{{Name: "Keyboard"}, {Name: "Mouse"}, {Name: "Monitor"}})
+		missing := ksuid.New().String()
+
+		products, err := r.ListProductsByIDs(ctx, []string{ids[2], missing, ids[0]})
+		if err != nil {
+			t.Fatalf("ListProductsByIDs: %v", err)
+		}
+
+		// Unknown ids are skipped and the rest keep the requested order.
+		if got, want := productIDs(products), []string{ids[2], ids[0]}; !slices.Equal(got, want) {
+			t.Errorf("ListProductsByIDs = %v, want %v", got, want)
+		}
+
+		none, err := r.ListProductsByIDs(ctx, nil)
+		if err != nil {
+			t.Fatalf("ListProductsByIDs: %v", err)
+		}
+		if none == nil || len(none) != 0 {
+			t.Errorf("ListProductsByIDs(nil) = %v, want an empty slice", none)
+		}
+	})
+
+	t.Run("SearchProducts", func(t *testing.T) {
+		r := newRepository(t)
+		ctx := context.Background()
+
+		ids := createProducts(t, r, []catalog.Product{
+			{Name: "Red keyboard", Description: "Mechanical"},
+			{Name: "Mouse", Description: "Wireless and red"},
+			{Name: "Monitor", Description: "Wide"},
+		})
+
+		results, err := r.SearchProducts(ctx, "red", 0, 10)
+		if err != nil {
+			t.Fatalf("SearchProducts: %v", err)
+		}
+
+		// Name matches rank above description matches.
+		if got, want := resultIDs(results), ids[:2]; !slices.Equal(got, want) {
+			t.Fatalf("SearchProducts(red) = %v, want %v", got, want)
+		}
+		if results[0].Score <= results[1].Score {
+			t.Errorf("SearchProducts(red) scores = %v, %v, want descending", results[0].Score, results[1].Score)
+		}
+
+		wantHighlights := []string{"name", "description"}
+		for i, result := range results {
+			if !hasHighlight(result, wantHighlights[i], "<mark>red</mark>") {
+				t.Errorf("SearchProducts(red) highlights for %q = %+v, want red marked in %s", result.Product.Name, result.Highlights, wantHighlights[i])
+			}
+		}
+
+		page, err := r.SearchProducts(ctx, "red", 1, 10)
+		if err != nil {
+			t.Fatalf("SearchProducts: %v", err)
+		}
+		if got, want := resultIDs(page), ids[1:2]; !slices.Equal(got, want) {
+			t.Errorf("SearchProducts(red) skipping 1 = %v, want %v", got, want)
+		}
+
+		none, err := r.SearchProducts(ctx, "chair", 0, 10)
+		if err != nil {
+			t.Fatalf("SearchProducts: %v", err)
+		}
+		if none == nil || len(none) != 0 {
+			t.Errorf("SearchProducts(chair) = %v, want an empty slice", none)
+		}
+	})
+
+	t.Run("SuggestProducts", func(t *testing.T) {
+		r := newRepository(t)
+		ctx := context.Background()
+
+		ids := createProducts(t, r, []catalog.Product{
+			{Name: "Red keyboard"},
+			{Name: "Keyboard cover"},
+			{Name: "Mouse"},
+		})
+
+		suggestions, err := r.SuggestProducts(ctx, "key", 10)
+		if err != nil {
+			t.Fatalf("SuggestProducts: %v", err)
+		}
+		if got, want := suggestionIDs(suggestions), ids[:2]; !sameIDs(got, want) {
+			t.Fatalf("SuggestProducts(key) = %v, want %v in any order", got, want)
+		}
+
+		// Any word of the name can be completed, and the highlight covers
+		// the completed part.
+		wantSpans := map[string]catalog.Span{ids[0]: {Start: 4, End: 7}, ids[1]: {Start: 0, End: 3}}
+		for _, s := range suggestions {
+			if len(s.Highlights) != 1 || s.Highlights[0] != wantSpans[s.ID] {
+				t.Errorf("SuggestProducts(key) highlights for %q = %v, want [%v]", s.Name, s.Highlights, wantSpans[s.ID])
+			}
+		}
+
+		limited, err := r.SuggestProducts(ctx, "key", 1)
+		if err != nil {
+			t.Fatalf("SuggestProducts: %v", err)
+		}
+		if len(limited) != 1 {
+			t.Errorf("SuggestProducts(key, 1) returned %d suggestions, want 1", len(limited))
+		}
+
+		typo, err := r.SuggestProducts(ctx, "moise", 10)
+		if err != nil {
+			t.Fatalf("SuggestProducts: %v", err)
+		}
+		if got, want := suggestionIDs(typo), ids[2:]; !slices.Equal(got, want) {
+			t.Errorf("SuggestProducts(moise) = %v, want %v", got, want)
+		}
+
+		none, err := r.SuggestProducts(ctx, "chair", 10)
+		if err != nil {
+			t.Fatalf("SuggestProducts: %v", err)
+		}
+		if none == nil || len(none) != 0 {
+			t.Errorf("SuggestProducts(chair) = %v, want an empty slice", none)
+		}
+	})
+}
+
+// createProducts stores products under fresh ids and returns the ids in the
+// same order.
+func createProducts(t *testing.T, r catalog.Repository, products []catalog.Product) []string {
+	t.Helper()
+
+	ids := make([]string, len(products))
+	for i, p := range products {
+		p.ID = ksuid.New().String()
+		if err := r.CreateProduct(context.Background(), p); err != nil {
+			t.Fatalf("CreateProduct: %v", err)
+		}
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+func productIDs(products []catalog.Product) []string {
+	ids := make([]string, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+func resultIDs(results []catalog.SearchResult) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.Product.ID
+	}
+	return ids
+}
+
+func hasHighlight(result catalog.SearchResult, field string, mark string) bool {
+	for _, h := range result.Highlights {
+		if h.Field != field {
+			continue
+		}
+		for _, fragment := range h.Fragments {
+			if strings.Contains(strings.ToLower(fragment), mark) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func suggestionIDs(suggestions []catalog.Suggestion) []string {
+	ids := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		ids[i] = s.ID
+	}
+	return ids
+}
+
+func sameIDs(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
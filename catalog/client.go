@@ -2,30 +2,74 @@ package catalog
 
 import (
 	"context"
+	"log/slog"
+	"slices"
+	"strings"
 
 	pb "github.com/valkyraycho/go-microservices/catalog/proto"
+	"github.com/valkyraycho/go-microservices/health"
+	"github.com/valkyraycho/go-microservices/ratelimit"
+	"github.com/valkyraycho/go-microservices/resilience"
+	"github.com/valkyraycho/go-microservices/telemetry"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"golang.org/x/sync/singleflight"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
 type Client struct {
 	conn    *grpc.ClientConn
 	service pb.CatalogServiceClient
+
+	cache ProductCache
+	group singleflight.Group
 }
 
-func NewClient(url string) (*Client, error) {
-	conn, err := grpc.NewClient(url, grpc.WithTransportCredentials(insecure.NewCredentials()))
+// ReadMethods are the CatalogService methods that only read, so they are safe to
+// retry and hedge.
+var ReadMethods = []string{"GetProduct", "GetProducts", "SuggestProducts"}
+
+// NewClient connects to the service at url. Calls are traced and measured,
+// and made resilient to failures as set by policy. Extra options are applied
+// after the defaults, so they can override the transport.
+func NewClient(url string, policy resilience.Config, opts ...grpc.DialOption) (*Client, error) {
+	resilient, err := policy.DialOptions(pb.CatalogService_ServiceDesc, ReadMethods...)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(telemetry.ClientMetrics.UnaryClientInterceptor(), telemetry.UnaryClientRequestID, ratelimit.UnaryClientInterceptor),
+		grpc.WithChainStreamInterceptor(telemetry.ClientMetrics.StreamClientInterceptor(), telemetry.StreamClientRequestID, ratelimit.StreamClientInterceptor),
+	}, resilient...), opts...)
+	conn, err := grpc.NewClient(url, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Client{conn, pb.NewCatalogServiceClient(conn)}, nil
+	return &Client{conn: conn, service: pb.NewCatalogServiceClient(conn)}, nil
+}
+
+// UseCache makes the client look products up by id in cache before asking
+// the service, and keep the products the service returns there. Concurrent
+// lookups of the same products that miss the cache share one call to the
+// service. It must be called before the client is used.
+func (c *Client) UseCache(cache ProductCache) {
+	c.cache = cache
 }
 
 func (c *Client) Close() {
 	c.conn.Close()
 }
 
+// Ping returns an error unless the catalog service reports that it is serving,
+// which it only does while its own dependencies are reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	return health.Ping(ctx, c.conn, pb.CatalogService_ServiceDesc.ServiceName)
+}
+
 func (c *Client) PostProduct(ctx context.Context, name string, description string, price float64) (*Product, error) {
 	res, err := c.service.PostProduct(ctx, &pb.PostProductRequest{
 		Name:        name,
@@ -42,10 +86,35 @@ func (c *Client) PostProduct(ctx context.Context, name string, description strin
 		Name:        res.Product.Name,
 		Description: res.Product.Description,
 		Price:       res.Product.Price,
+		Archived:    res.Product.Archived,
 	}, nil
 }
 
 func (c *Client) GetProduct(ctx context.Context, id string) (*Product, error) {
+	if c.cache == nil {
+		return c.getProduct(ctx, id)
+	}
+
+	if cached := c.cached(ctx, []string{id}); len(cached) == 1 {
+		p := cached[id]
+		return &p, nil
+	}
+	v, err := c.shared(ctx, "product:"+id, func(ctx context.Context) (any, error) {
+		p, err := c.getProduct(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		c.store(ctx, []Product{*p})
+		return *p, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	p := v.(Product)
+	return &p, nil
+}
+
+func (c *Client) getProduct(ctx context.Context, id string) (*Product, error) {
 	res, err := c.service.GetProduct(ctx, &pb.GetProductRequest{
 		Id: id,
 	})
@@ -59,9 +128,72 @@ func (c *Client) GetProduct(ctx context.Context, id string) (*Product, error) {
 		Name:        res.Product.Name,
 		Description: res.Product.Description,
 		Price:       res.Product.Price,
+		Archived:    res.Product.Archived,
 	}, nil
 }
+
+// GetProducts returns a page of products, or the products with the given
+// ids, or the products that match query. Products looked up by id come from
+// the cache when the client has one.
 func (c *Client) GetProducts(ctx context.Context, skip uint64, take uint64, ids []string, query string) ([]Product, error) {
+	if c.cache == nil || len(ids) == 0 {
+		return c.getProducts(ctx, skip, take, ids, query)
+	}
+
+	found := c.cached(ctx, ids)
+	var missing []string
+	for _, id := range ids {
+		if _, ok := found[id]; !ok && !slices.Contains(missing, id) {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		// Lookups of the same products share a call, whatever order they
+		// list them in.
+		slices.Sort(missing)
+		v, err := c.shared(ctx, "products:"+strings.Join(missing, ","), func(ctx context.Context) (any, error) {
+			products, err := c.getProducts(ctx, 0, 0, missing, "")
+			if err != nil {
+				return nil, err
+			}
+			c.store(ctx, products)
+			return products, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range v.([]Product) {
+			found[p.ID] = p
+		}
+	}
+
+	products := []Product{}
+	for _, id := range ids {
+		if p, ok := found[id]; ok {
+			products = append(products, p)
+			delete(found, id)
+		}
+	}
+	return products, nil
+}
+
+// FetchProducts returns the products with the given ids from the service,
+// never from the cache. Other processes archive products without clearing
+// this client's cache, so checks that must see products as they are now,
+// such as whether they can still be ordered, fetch them instead. The cache is
+// refreshed with them.
+func (c *Client) FetchProducts(ctx context.Context, ids []string) ([]Product, error) {
+	products, err := c.getProducts(ctx, 0, 0, ids, "")
+	if err != nil {
+		return nil, err
+	}
+	if c.cache != nil {
+		c.store(ctx, products)
+	}
+	return products, nil
+}
+
+func (c *Client) getProducts(ctx context.Context, skip uint64, take uint64, ids []string, query string) ([]Product, error) {
 	res, err := c.service.GetProducts(ctx, &pb.GetProductsRequest{
 		Skip:  skip,
 		Take:  take,
@@ -81,8 +213,146 @@ func (c *Client) GetProducts(ctx context.Context, skip uint64, take uint64, ids
 			Name:        p.Name,
 			Description: p.Description,
 			Price:       p.Price,
+			Archived:    p.Archived,
 		})
 	}
 
 	return products, nil
 }
+
+// SearchProducts runs a full-text search and returns the matching products
+// with their scores and highlights, best match first.
+func (c *Client) SearchProducts(ctx context.Context, query string, skip uint64, take uint64) ([]SearchResult, error) {
+	res, err := c.service.GetProducts(ctx, &pb.GetProductsRequest{
+		Skip:  skip,
+		Take:  take,
+		Query: query,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make(map[string]*pb.SearchHit, len(res.Hits))
+	for _, h := range res.Hits {
+		hits[h.ProductId] = h
+	}
+
+	results := []SearchResult{}
+
+	for _, p := range res.Products {
+		result := SearchResult{
+			Product: Product{
+				ID:          p.Id,
+				Name:        p.Name,
+				Description: p.Description,
+				Price:       p.Price,
+				Archived:    p.Archived,
+			},
+			Highlights: []Highlight{},
+		}
+		if h, ok := hits[p.Id]; ok {
+			result.Score = h.Score
+			for _, hl := range h.Highlights {
+				result.Highlights = append(result.Highlights, Highlight{Field: hl.Field, Fragments: hl.Fragments})
+			}
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (c *Client) SuggestProducts(ctx context.Context, prefix string, take uint64) ([]Suggestion, error) {
+	res, err := c.service.SuggestProducts(ctx, &pb.SuggestProductsRequest{
+		Prefix: prefix,
+		Take:   take,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := []Suggestion{}
+
+	for _, s := range res.Suggestions {
+		highlights := []Span{}
+		for _, h := range s.Highlights {
+			highlights = append(highlights, Span{Start: int(h.Start), End: int(h.End)})
+		}
+		suggestions = append(suggestions, Suggestion{
+			ID:         s.Id,
+			Name:       s.Name,
+			Highlights: highlights,
+		})
+	}
+
+	return suggestions, nil
+}
+
+// ArchiveProduct marks a product as no longer orderable and returns it.
+func (c *Client) ArchiveProduct(ctx context.Context, id string) (*Product, error) {
+	res, err := c.service.ArchiveProduct(ctx, &pb.ArchiveProductRequest{Id: id})
+	if err != nil {
+		return nil, err
+	}
+	c.forget(ctx, id)
+
+	return &Product{
+		ID:          res.Product.Id,
+		Name:        res.Product.Name,
+		Description: res.Product.Description,
+		Price:       res.Product.Price,
+		Archived:    res.Product.Archived,
+	}, nil
+}
+
+// cached returns the products with the given ids that are in the cache. The
+// cache only saves calls, so when it fails the products are fetched instead.
+func (c *Client) cached(ctx context.Context, ids []string) map[string]Product {
+	products, err := c.cache.GetProducts(ctx, ids)
+	if err != nil {
+		slog.WarnContext(ctx, "reading the product cache", "err", err)
+		products = map[string]Product{}
+	}
+	cacheLookups.WithLabelValues("hit").Add(float64(len(products)))
+	cacheLookups.WithLabelValues("miss").Add(float64(len(ids) - len(products)))
+	return products
+}
+
+func (c *Client) store(ctx context.Context, products []Product) {
+	if err := c.cache.SetProducts(ctx, products); err != nil {
+		slog.WarnContext(ctx, "writing the product cache", "err", err)
+	}
+}
+
+func (c *Client) forget(ctx context.Context, id string) {
+	if c.cache == nil {
+		return
+	}
+	if err := c.cache.DeleteProducts(ctx, []string{id}); err != nil {
+		slog.ErrorContext(ctx, "invalidating the product cache", "product_id", id, "err", err)
+	}
+}
+
+// shared runs fetch once for all the concurrent calls with the same key.
+// Callers that give up do not cancel it for the others, but it stops at the
+// deadline of the caller that started it.
+func (c *Client) shared(ctx context.Context, key string, fetch func(context.Context) (any, error)) (any, error) {
+	ch := c.group.DoChan(key, func() (any, error) {
+		fetchCtx := context.WithoutCancel(ctx)
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			fetchCtx, cancel = context.WithDeadline(fetchCtx, deadline)
+			defer cancel()
+		}
+		return fetch(fetchCtx)
+	})
+
+	select {
+	case res := <-ch:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
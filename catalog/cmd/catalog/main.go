@@ -1,38 +1,147 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"log/slog"
+	"os"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/tinrab/retry"
 	"github.com/valkyraycho/go-microservices/catalog"
+	"github.com/valkyraycho/go-microservices/graceful"
+	"github.com/valkyraycho/go-microservices/health"
+	"github.com/valkyraycho/go-microservices/ratelimit"
+	"github.com/valkyraycho/go-microservices/telemetry"
+	"github.com/valkyraycho/go-microservices/tlsconfig"
 )
 
 type Config struct {
 	DatabaseURL string `envconfig:"DATABASE_URL"`
+	Backend     string `envconfig:"CATALOG_BACKEND" default:"elasticsearch"`
+	IndexPath   string `envconfig:"CATALOG_INDEX_PATH" default:"catalog.bleve"`
+
+	NameBoost        float64 `envconfig:"SEARCH_NAME_BOOST" default:"2"`
+	DescriptionBoost float64 `envconfig:"SEARCH_DESCRIPTION_BOOST" default:"1"`
+	SynonymsPath     string  `envconfig:"SEARCH_SYNONYMS_PATH"`
+
+	MetricsPort int `envconfig:"METRICS_PORT" default:"9090"`
+	// ShutdownTimeout bounds how long the server waits for calls in flight
+	// once it is asked to stop.
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"10s"`
+
+	// GRPCTLS secures the server, and the clients it opens, with mutual TLS.
+	GRPCTLS tlsconfig.Config `envconfig:"GRPC_TLS"`
+
+	// RateLimit bounds the calls of each caller, unless CallerQuotas sets a
+	// limit of its own for it. Callers are named by the common name of their
+	// certificate under mutual TLS, and by IP address otherwise.
+	RateLimit    ratelimit.Limit            `envconfig:"RATE_LIMIT" default:"500/1s"`
+	CallerQuotas map[string]ratelimit.Limit `envconfig:"CALLER_QUOTAS"`
+	// ClientRateLimit bounds the calls of each client that ClientForwarders
+	// name in their calls. Forwarders are only trusted under mutual TLS.
+	ClientRateLimit  ratelimit.Limit `envconfig:"CLIENT_RATE_LIMIT" default:"100/1s"`
+	ClientForwarders []string        `envconfig:"CLIENT_FORWARDERS" default:"graphql-gateway,rest-gateway,order-service"`
 }
 
 func main() {
+	// The image has no gRPC client, so the compose healthcheck runs the
+	// binary itself to ask the server whether it is serving.
+	if len(os.Args) > 1 && os.Args[1] == "healthcheck" {
+		if err := probe(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// probe connects to the server with the same credentials its clients use.
+func probe() error {
 	var cfg Config
-	err := envconfig.Process("", &cfg)
+	if err := envconfig.Process("", &cfg); err != nil {
+		return err
+	}
+	creds, err := tlsconfig.DialOption(cfg.GRPCTLS)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	return health.Probe("localhost:8080", creds)
+}
+
+func run() error {
+	var cfg Config
+	if err := envconfig.Process("", &cfg); err != nil {
+		return err
+	}
+
+	shutdown, err := telemetry.Setup(context.Background(), "catalog")
+	if err != nil {
+		return err
+	}
+	// Spans still buffered are sent once everything else has shut down.
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			slog.Error("flushing spans", "err", err)
+		}
+	}()
+
+	go func() {
+		log.Fatal(telemetry.ListenMetrics(cfg.MetricsPort, nil))
+	}()
+
+	serverCreds, err := tlsconfig.ServerOption(cfg.GRPCTLS)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Backend != "elasticsearch" && cfg.Backend != "bleve" {
+		return fmt.Errorf("unknown catalog backend %q", cfg.Backend)
+	}
+
+	search := catalog.SearchConfig{NameBoost: cfg.NameBoost, DescriptionBoost: cfg.DescriptionBoost}
+	if cfg.SynonymsPath != "" {
+		search.Synonyms, err = catalog.LoadSynonyms(cfg.SynonymsPath)
+		if err != nil {
+			return err
+		}
 	}
 
 	var r catalog.Repository
 
 	retry.ForeverSleep(2*time.Second, func(i int) error {
-		r, err = catalog.NewElasticRepository(cfg.DatabaseURL)
+		if cfg.Backend == "bleve" {
+			r, err = catalog.NewBleveRepository(cfg.IndexPath, search)
+		} else {
+			r, err = catalog.NewElasticRepository(cfg.DatabaseURL, search)
+		}
 		if err != nil {
-			log.Println(err)
+			slog.Error("opening the catalog store", "err", err)
 			return err
 		}
 		return nil
 	})
 	defer r.Close()
 
-	log.Println("Listening on port 8080...")
+	// Signals are only handled once the store is connected; before that there
+	// is nothing to drain.
+	ctx, stop := graceful.SignalContext()
+	defer stop()
+
+	slog.Info("listening", "port", 8080)
 	s := catalog.NewService(r)
-	log.Fatal(catalog.ListenGRPC(s, 8080))
+	quotas := ratelimit.NewQuotas(cfg.RateLimit, cfg.CallerQuotas)
+	quotas.LimitClients(cfg.ClientRateLimit, cfg.ClientForwarders)
+	if err := catalog.ListenGRPC(ctx, s, 8080, cfg.ShutdownTimeout, append(quotas.ServerOptions(), serverCreds)...); err != nil {
+		return err
+	}
+	slog.Info("shut down")
+	return nil
 }
@@ -0,0 +1,15 @@
+package catalog
+
+import (
+	"errors"
+
+	"github.com/valkyraycho/go-microservices/grpcerr"
+)
+
+// statusError reports a missing product as NotFound.
+func statusError(err error) error {
+	if errors.Is(err, ErrNotFound) {
+		return grpcerr.NotFound("product", "", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package catalog
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type memoryRepository struct {
+	mu       sync.RWMutex
+	products map[string]Product
+}
+
+// NewMemoryRepository returns a Repository that keeps products in memory. It
+// is meant for tests; search is a plain case-insensitive word match on name
+// and description, ranked with DefaultSearchConfig.
+func NewMemoryRepository() Repository {
+	return &memoryRepository{products: map[string]Product{}}
+}
+
+func (r *memoryRepository) Close() {}
+
+func (r *memoryRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (r *memoryRepository) CreateProduct(ctx context.Context, p Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.products[p.ID] = p
+	return nil
+}
+
+func (r *memoryRepository) GetProductByID(ctx context.Context, id string) (*Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.products[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &p, nil
+}
+
+func (r *memoryRepository) ListProducts(ctx context.Context, skip uint64, take uint64) ([]Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := make([]Product, 0, len(r.products))
+	for _, p := range r.products {
+		all = append(all, p)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+
+	products := []Product{}
+	for i := skip; i < uint64(len(all)) && i-skip < take; i++ {
+		products = append(products, all[i])
+	}
+	return products, nil
+}
+
+func (r *memoryRepository) ListProductsByIDs(ctx context.Context, ids []string) ([]Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	products := []Product{}
+	for _, id := range ids {
+		if p, ok := r.products[id]; ok {
+			products = append(products, p)
+		}
+	}
+	return products, nil
+}
+
+func (r *memoryRepository) ArchiveProduct(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.products[id]
+	if !ok {
+		return ErrNotFound
+	}
+	p.Archived = true
+	r.products[id] = p
+	return nil
+}
+
+func (r *memoryRepository) SearchProducts(ctx context.Context, query string, skip uint64, take uint64) ([]SearchResult, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	terms := map[string]bool{}
+	for _, t := range strings.Fields(strings.ToLower(DefaultSearchConfig.Synonyms.Expand(query))) {
+		terms[t] = true
+	}
+
+	all := []SearchResult{}
+	for _, p := range r.products {
+		result := SearchResult{Product: p, Highlights: []Highlight{}}
+
+		if fragment, ok := highlightTerms(p.Description, terms); ok {
+			result.Score += DefaultSearchConfig.DescriptionBoost
+			result.Highlights = append(result.Highlights, Highlight{Field: "description", Fragments: []string{fragment}})
+		}
+		if fragment, ok := highlightTerms(p.Name, terms); ok {
+			result.Score += DefaultSearchConfig.NameBoost
+			result.Highlights = append(result.Highlights, Highlight{Field: "name", Fragments: []string{fragment}})
+		}
+
+		if result.Score > 0 {
+			all = append(all, result)
+		}
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Score != all[j].Score {
+			return all[i].Score > all[j].Score
+		}
+		return all[i].Product.ID < all[j].Product.ID
+	})
+
+	results := []SearchResult{}
+	for i := skip; i < uint64(len(all)) && i-skip < take; i++ {
+		results = append(results, all[i])
+	}
+	return results, nil
+}
+
+func (r *memoryRepository) SuggestProducts(ctx context.Context, prefix string, take uint64) ([]Suggestion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	type match struct {
+		product  Product
+		distance int
+	}
+
+	matches := []match{}
+	for _, p := range r.products {
+		if _, distance, ok := matchSuggestion(p.Name, prefix); ok {
+			matches = append(matches, match{p, distance})
+		}
+	}
+
+	// Exact completions rank above the ones that needed typo correction.
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].product.ID < matches[j].product.ID
+	})
+
+	suggestions := []Suggestion{}
+	for i := 0; i < len(matches) && uint64(i) < take; i++ {
+		suggestions = append(suggestions, newSuggestion(matches[i].product.ID, matches[i].product.Name, prefix))
+	}
+	return suggestions, nil
+}
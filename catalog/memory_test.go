@@ -0,0 +1,14 @@
+package catalog_test
+
+import (
+	"testing"
+
+	"github.com/valkyraycho/go-microservices/catalog"
+	"github.com/valkyraycho/go-microservices/catalog/catalogtest"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	catalogtest.RunRepositoryTests(t, func(t *testing.T) catalog.Repository {
+		return catalog.NewMemoryRepository()
+	})
+}
@@ -0,0 +1,17 @@
+package catalog
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var productsCreated = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "products_created_total",
+	Help: "Number of products added to the catalog.",
+})
+
+// cacheLookups counts the products clients look up in their cache.
+var cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "catalog_cache_lookups_total",
+	Help: "Number of products looked up in the product cache, by whether they were cached.",
+}, []string{"result"})
@@ -4,51 +4,162 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
 
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"gopkg.in/olivere/elastic.v5"
 )
 
 type Repository interface {
 	Close()
+	// Ping returns an error when the store cannot be reached.
+	Ping(ctx context.Context) error
 	CreateProduct(ctx context.Context, p Product) error
 	GetProductByID(ctx context.Context, id string) (*Product, error)
 	ListProducts(ctx context.Context, skip uint64, take uint64) ([]Product, error)
 	ListProductsByIDs(ctx context.Context, ids []string) ([]Product, error)
-	SearchProducts(ctx context.Context, query string, skip uint64, take uint64) ([]Product, error)
+	ArchiveProduct(ctx context.Context, id string) error
+	SearchProducts(ctx context.Context, query string, skip uint64, take uint64) ([]SearchResult, error)
+	SuggestProducts(ctx context.Context, prefix string, take uint64) ([]Suggestion, error)
 }
+
+var ErrNotFound = errors.New("entity not found")
+
 type elasticRepository struct {
 	client *elastic.Client
+	config SearchConfig
 }
 
 type productDocument struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Price       float64  `json:"price"`
+	Suggest     []string `json:"suggest,omitempty"`
+	Archived    bool     `json:"archived,omitempty"`
 }
 
-func NewElasticRepository(url string) (Repository, error) {
-	client, err := elastic.NewClient(elastic.SetURL(url), elastic.SetSniff(false))
+const productMapping = `{
+	"properties": {
+		"name": {"type": "text"},
+		"description": {"type": "text"},
+		"price": {"type": "double"},
+		"suggest": {"type": "completion"},
+		"archived": {"type": "boolean"}
+	}
+}`
+
+const catalogMapping = `{"mappings": {"product": ` + productMapping + `}}`
+
+// tracedHTTPClient records a span for every request to Elasticsearch. Paths
+// contain document ids, so they are left out of the span name.
+func tracedHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: otelhttp.NewTransport(http.DefaultTransport,
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return "elasticsearch " + r.Method
+			}),
+		),
+	}
+}
+
+func NewElasticRepository(url string, config SearchConfig) (Repository, error) {
+	client, err := elastic.NewClient(elastic.SetURL(url), elastic.SetSniff(false), elastic.SetHttpClient(tracedHTTPClient()))
 	if err != nil {
 		return nil, err
 	}
 
-	return &elasticRepository{client}, nil
+	ctx := context.Background()
+	exists, err := client.IndexExists("catalog").Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if _, err := client.CreateIndex("catalog").BodyString(catalogMapping).Do(ctx); err != nil {
+			return nil, err
+		}
+	} else if err := upgradeCatalogIndex(ctx, client); err != nil {
+		return nil, err
+	}
+
+	return &elasticRepository{client, config}, nil
+}
+
+// upgradeCatalogIndex adds the fields added to the mapping since the index was
+// created, and fills in the suggestions of the products indexed before the
+// suggest field existed, so that they can be suggested too.
+func upgradeCatalogIndex(ctx context.Context, client *elastic.Client) error {
+	if _, err := client.PutMapping().Index("catalog").Type("product").BodyString(productMapping).Do(ctx); err != nil {
+		return err
+	}
+
+	scroll := client.Scroll("catalog").Type("product").
+		Query(elastic.NewBoolQuery().MustNot(elastic.NewExistsQuery("suggest"))).
+		Size(500)
+	defer scroll.Clear(context.WithoutCancel(ctx))
+
+	for {
+		res, err := scroll.Do(ctx)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		bulk := client.Bulk().Index("catalog").Type("product").Refresh("wait_for")
+		for _, hit := range res.Hits.Hits {
+			p := productDocument{}
+			if err := json.Unmarshal(*hit.Source, &p); err != nil {
+				return err
+			}
+			doc := newProductDocument(p.product(hit.Id))
+			bulk.Add(elastic.NewBulkIndexRequest().Id(hit.Id).Doc(doc))
+		}
+		if bulk.NumberOfActions() == 0 {
+			return nil
+		}
+		indexed, err := bulk.Do(ctx)
+		if err != nil {
+			return err
+		}
+		if failed := indexed.Failed(); len(failed) > 0 {
+			return fmt.Errorf("filling in the suggestions of product %s: %s", failed[0].Id, failed[0].Error.Reason)
+		}
+	}
 }
 
 func (r *elasticRepository) Close() {}
 
+// Ping fails while the catalog index is red, since searches against it fail
+// too.
+func (r *elasticRepository) Ping(ctx context.Context) error {
+	res, err := r.client.ClusterHealth().Index("catalog").Do(ctx)
+	if err != nil {
+		return err
+	}
+	if res.Status == "red" {
+		return fmt.Errorf("catalog index is %s", res.Status)
+	}
+	return nil
+}
+
 func (r *elasticRepository) CreateProduct(ctx context.Context, p Product) error {
-	_, err := r.client.Index().Index("catalog").Type("product").Id(p.ID).BodyJson(productDocument{Name: p.Name, Description: p.Description, Price: p.Price}).Do(ctx)
+	_, err := r.client.Index().Index("catalog").Type("product").Id(p.ID).BodyJson(newProductDocument(p)).Refresh("wait_for").Do(ctx)
 	return err
 }
 func (r *elasticRepository) GetProductByID(ctx context.Context, id string) (*Product, error) {
 	res, err := r.client.Get().Index("catalog").Type("product").Id(id).Do(ctx)
+	if elastic.IsNotFound(err) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	if !res.Found {
-		return nil, errors.New("entity not found")
+		return nil, ErrNotFound
 	}
 
 	p := productDocument{}
@@ -56,17 +167,16 @@ func (r *elasticRepository) GetProductByID(ctx context.Context, id string) (*Pro
 		return nil, err
 	}
 
-	return &Product{
-		ID:          id,
-		Name:        p.Name,
-		Description: p.Description,
-		Price:       p.Price,
-	}, nil
+	product := p.product(id)
+	return &product, nil
 
 }
 func (r *elasticRepository) ListProducts(ctx context.Context, skip uint64, take uint64) ([]Product, error) {
 	res, err := r.client.Search().Index("catalog").Type("product").Query(elastic.NewMatchAllQuery()).From(int(skip)).Size(int(take)).Do(ctx)
 
+	if elastic.IsNotFound(err) {
+		return []Product{}, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -78,16 +188,15 @@ func (r *elasticRepository) ListProducts(ctx context.Context, skip uint64, take
 		if err := json.Unmarshal(*hit.Source, &p); err != nil {
 			return nil, err
 		}
-		products = append(products, Product{
-			ID:          hit.Id,
-			Name:        p.Name,
-			Description: p.Description,
-			Price:       p.Price,
-		})
+		products = append(products, p.product(hit.Id))
 	}
 	return products, nil
 }
 func (r *elasticRepository) ListProductsByIDs(ctx context.Context, ids []string) ([]Product, error) {
+	if len(ids) == 0 {
+		return []Product{}, nil
+	}
+
 	items := []*elastic.MultiGetItem{}
 
 	for _, id := range ids {
@@ -102,39 +211,98 @@ func (r *elasticRepository) ListProductsByIDs(ctx context.Context, ids []string)
 
 	products := []Product{}
 	for _, doc := range res.Docs {
+		if !doc.Found {
+			continue
+		}
 		p := productDocument{}
 		if err := json.Unmarshal(*doc.Source, &p); err != nil {
 			return nil, err
 		}
-		products = append(products, Product{
-			ID:          doc.Id,
-			Name:        p.Name,
-			Description: p.Description,
-			Price:       p.Price,
-		})
+		products = append(products, p.product(doc.Id))
 	}
 	return products, nil
 }
-func (r *elasticRepository) SearchProducts(ctx context.Context, query string, skip uint64, take uint64) ([]Product, error) {
-	res, err := r.client.Search().Index("catalog").Type("product").Query(elastic.NewMultiMatchQuery(query, "name", "description")).From(int(skip)).Size(int(take)).Do(ctx)
+func (r *elasticRepository) ArchiveProduct(ctx context.Context, id string) error {
+	_, err := r.client.Update().Index("catalog").Type("product").Id(id).Doc(map[string]any{"archived": true}).Refresh("wait_for").Do(ctx)
+	if elastic.IsNotFound(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (r *elasticRepository) SearchProducts(ctx context.Context, query string, skip uint64, take uint64) ([]SearchResult, error) {
+	q := elastic.NewMultiMatchQuery(r.config.Synonyms.Expand(query)).
+		FieldWithBoost("name", r.config.NameBoost).
+		FieldWithBoost("description", r.config.DescriptionBoost)
+
+	highlight := elastic.NewHighlight().
+		Fields(elastic.NewHighlighterField("name"), elastic.NewHighlighterField("description")).
+		PreTags("<mark>").
+		PostTags("</mark>").
+		Encoder("html")
 
+	res, err := r.client.Search().Index("catalog").Type("product").Query(q).Highlight(highlight).From(int(skip)).Size(int(take)).Do(ctx)
+
+	if elastic.IsNotFound(err) {
+		return []SearchResult{}, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	products := []Product{}
+	results := []SearchResult{}
 
 	for _, hit := range res.Hits.Hits {
 		p := productDocument{}
 		if err := json.Unmarshal(*hit.Source, &p); err != nil {
 			return nil, err
 		}
-		products = append(products, Product{
-			ID:          hit.Id,
-			Name:        p.Name,
-			Description: p.Description,
-			Price:       p.Price,
+
+		score := 0.0
+		if hit.Score != nil {
+			score = *hit.Score
+		}
+
+		results = append(results, SearchResult{
+			Product:    p.product(hit.Id),
+			Score:      score,
+			Highlights: sortHighlights(hit.Highlight),
 		})
 	}
-	return products, nil
+	return results, nil
+}
+
+func (r *elasticRepository) SuggestProducts(ctx context.Context, prefix string, take uint64) ([]Suggestion, error) {
+	suggester := elastic.NewCompletionSuggester("products").
+		Field("suggest").
+		Size(int(take)).
+		PrefixWithOptions(prefix, elastic.NewFuzzyCompletionSuggesterOptions().EditDistance("AUTO"))
+
+	res, err := r.client.Search().Index("catalog").Type("product").Suggester(suggester).Size(0).Do(ctx)
+
+	if elastic.IsNotFound(err) {
+		return []Suggestion{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := []Suggestion{}
+	seen := map[string]bool{}
+
+	for _, s := range res.Suggest["products"] {
+		for _, option := range s.Options {
+			if seen[option.Id] || option.Source == nil {
+				continue
+			}
+			seen[option.Id] = true
+
+			p := productDocument{}
+			if err := json.Unmarshal(*option.Source, &p); err != nil {
+				return nil, err
+			}
+			suggestions = append(suggestions, newSuggestion(option.Id, p.Name, prefix))
+		}
+	}
+	return suggestions, nil
 }
@@ -0,0 +1,92 @@
+package catalog_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/valkyraycho/go-microservices/catalog"
+	"github.com/valkyraycho/go-microservices/catalog/catalogtest"
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// TestElasticRepository runs against the cluster in TEST_ELASTICSEARCH_URL and
+// deletes the catalog index, so it must not point at real data.
+func TestElasticRepository(t *testing.T) {
+	url := os.Getenv("TEST_ELASTICSEARCH_URL")
+	if url == "" {
+		t.Skip("TEST_ELASTICSEARCH_URL is not set")
+	}
+
+	client, err := elastic.NewClient(elastic.SetURL(url), elastic.SetSniff(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	catalogtest.RunRepositoryTests(t, func(t *testing.T) catalog.Repository {
+		_, err := client.DeleteIndex("catalog").Do(context.Background())
+		if err != nil && !elastic.IsNotFound(err) {
+			t.Fatal(err)
+		}
+
+		r, err := catalog.NewElasticRepository(url, catalog.DefaultSearchConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(r.Close)
+		return r
+	})
+}
+
+// TestElasticRepositoryUpgrade starts from a catalog index created before
+// products were suggested, and checks that the products already indexed are
+// suggested once the repository has upgraded it.
+func TestElasticRepositoryUpgrade(t *testing.T) {
+	url := os.Getenv("TEST_ELASTICSEARCH_URL")
+	if url == "" {
+		t.Skip("TEST_ELASTICSEARCH_URL is not set")
+	}
+	ctx := context.Background()
+
+	client, err := elastic.NewClient(elastic.SetURL(url), elastic.SetSniff(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.DeleteIndex("catalog").Do(ctx); err != nil && !elastic.IsNotFound(err) {
+		t.Fatal(err)
+	}
+	_, err = client.CreateIndex("catalog").BodyString(`{
+		"mappings": {
+			"product": {
+				"properties": {
+					"name": {"type": "text"},
+					"description": {"type": "text"},
+					"price": {"type": "double"}
+				}
+			}
+		}
+	}`).Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Index().Index("catalog").Type("product").Id("old").
+		BodyJson(map[string]any{"name": "Mechanical Keyboard", "description": "", "price": 50}).
+		Refresh("wait_for").Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := catalog.NewElasticRepository(url, catalog.DefaultSearchConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	suggestions, err := r.SuggestProducts(ctx, "mech", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestions) != 1 || suggestions[0].ID != "old" {
+		t.Errorf("SuggestProducts = %v, want the product indexed before the upgrade", suggestions)
+	}
+}
@@ -0,0 +1,176 @@
+package catalog
+
+import (
+	"bufio"
+	"html"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SearchResult is a product matched by a full-text search, with its relevance
+// score and the parts of its fields that matched.
+type SearchResult struct {
+	Product    Product     `json:"product"`
+	Score      float64     `json:"score"`
+	Highlights []Highlight `json:"highlights"`
+}
+
+// Highlight holds HTML-escaped fragments of a field with the matched terms
+// wrapped in <mark> tags.
+type Highlight struct {
+	Field     string   `json:"field"`
+	Fragments []string `json:"fragments"`
+}
+
+// SearchConfig tunes how SearchProducts ranks products.
+type SearchConfig struct {
+	NameBoost        float64
+	DescriptionBoost float64
+	Synonyms         Synonyms
+}
+
+// DefaultSearchConfig ranks name matches above description matches and uses
+// no synonyms.
+var DefaultSearchConfig = SearchConfig{NameBoost: 2, DescriptionBoost: 1}
+
+// Synonyms maps a lowercased term to the terms a query for it also matches.
+type Synonyms map[string][]string
+
+// LoadSynonyms reads a synonyms file in the Solr format used by
+// Elasticsearch: one rule per line, either a comma-separated list of
+// equivalent terms ("laptop, notebook") or an explicit mapping
+// ("lappy, lapto => laptop"). Blank lines and lines starting with # are
+// ignored. Only single-word terms on the left of a mapping are matched.
+func LoadSynonyms(path string) (Synonyms, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	synonyms := Synonyms{}
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if from, to, ok := strings.Cut(line, "=>"); ok {
+			targets := splitSynonyms(to)
+			for _, term := range splitSynonyms(from) {
+				synonyms[term] = appendMissing(synonyms[term], targets...)
+			}
+			continue
+		}
+
+		terms := splitSynonyms(line)
+		for _, term := range terms {
+			synonyms[term] = appendMissing(synonyms[term], terms...)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return synonyms, nil
+}
+
+// Expand rewrites query so that it also matches the synonyms of its terms.
+// Terms without synonyms are kept as they are.
+func (s Synonyms) Expand(query string) string {
+	if len(s) == 0 {
+		return query
+	}
+
+	terms := []string{}
+	for _, term := range strings.Fields(strings.ToLower(query)) {
+		if synonyms, ok := s[term]; ok {
+			terms = appendMissing(terms, synonyms...)
+		} else {
+			terms = appendMissing(terms, term)
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+func splitSynonyms(s string) []string {
+	terms := []string{}
+	for _, term := range strings.Split(s, ",") {
+		if term = strings.ToLower(strings.TrimSpace(term)); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+func appendMissing(terms []string, more ...string) []string {
+	for _, m := range more {
+		found := false
+		for _, t := range terms {
+			if t == m {
+				found = true
+				break
+			}
+		}
+		if !found {
+			terms = append(terms, m)
+		}
+	}
+	return terms
+}
+
+// highlightTerms wraps every word of text found in terms in <mark> tags. It
+// reports whether any word matched.
+func highlightTerms(text string, terms map[string]bool) (string, bool) {
+	var b strings.Builder
+	matched := false
+	start := -1
+
+	flush := func(end int) {
+		word := text[start:end]
+		if terms[strings.ToLower(word)] {
+			b.WriteString("<mark>" + html.EscapeString(word) + "</mark>")
+			matched = true
+		} else {
+			b.WriteString(html.EscapeString(word))
+		}
+		start = -1
+	}
+
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start == -1 {
+			start = i
+		} else if !isWord {
+			if start != -1 {
+				flush(i)
+			}
+			b.WriteString(html.EscapeString(string(r)))
+		}
+	}
+	if start != -1 {
+		flush(len(text))
+	}
+
+	return b.String(), matched
+}
+
+// sortHighlights orders highlights by field name, since search engines
+// return them as maps.
+func sortHighlights(highlights map[string][]string) []Highlight {
+	fields := make([]string, 0, len(highlights))
+	for field := range highlights {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	sorted := []Highlight{}
+	for _, field := range fields {
+		sorted = append(sorted, Highlight{Field: field, Fragments: highlights[field]})
+	}
+	return sorted
+}
@@ -0,0 +1,47 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSynonyms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "synonyms.txt")
+	err := os.WriteFile(path, []byte("# comment\n\nlaptop, Notebook\nlappy, lapto => laptop\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	synonyms, err := LoadSynonyms(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"laptop", "laptop notebook"},
+		{"Notebook bag", "laptop notebook bag"},
+		{"lappy", "laptop"},
+		{"mouse", "mouse"},
+	}
+
+	for _, tt := range tests {
+		if got := synonyms.Expand(tt.query); got != tt.want {
+			t.Errorf("Expand(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestHighlightTerms(t *testing.T) {
+	got, ok := highlightTerms("Red <b>keyboard</b>, red!", map[string]bool{"red": true})
+	if want := "<mark>Red</mark> &lt;b&gt;keyboard&lt;/b&gt;, <mark>red</mark>!"; !ok || got != want {
+		t.Errorf("highlightTerms = %q, %v, want %q, true", got, ok, want)
+	}
+
+	if _, ok := highlightTerms("Mouse", map[string]bool{"red": true}); ok {
+		t.Error("highlightTerms matched a text without the term")
+	}
+}
@@ -4,8 +4,14 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"time"
 
 	pb "github.com/valkyraycho/go-microservices/catalog/proto"
+	"github.com/valkyraycho/go-microservices/graceful"
+	"github.com/valkyraycho/go-microservices/grpcerr"
+	"github.com/valkyraycho/go-microservices/health"
+	"github.com/valkyraycho/go-microservices/telemetry"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 )
@@ -15,17 +21,34 @@ type catalogServer struct {
 	service Service
 }
 
-func ListenGRPC(s Service, port int) error {
+// ListenGRPC serves s on port until ctx is done, then waits up to
+// shutdownTimeout for the calls in flight to finish. The options are passed
+// to NewGRPCServer.
+func ListenGRPC(ctx context.Context, s Service, port int, shutdownTimeout time.Duration, opts ...grpc.ServerOption) error {
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 
 	if err != nil {
 		return err
 	}
 
-	server := grpc.NewServer()
+	return graceful.ServeGRPC(ctx, NewGRPCServer(s, opts...), lis, shutdownTimeout)
+}
+
+// NewGRPCServer returns a gRPC server with the catalog service registered, ready
+// to serve on any listener. Its health service reports SERVING while the
+// store can be reached. Extra options, such as the transport credentials,
+// are applied after the defaults.
+func NewGRPCServer(s Service, opts ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(append([]grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(telemetry.ServerMetrics.UnaryServerInterceptor(), telemetry.UnaryServerLogging, grpcerr.UnaryServerInterceptor(statusError)),
+		grpc.ChainStreamInterceptor(telemetry.StreamServerLogging),
+	}, opts...)...)
 	pb.RegisterCatalogServiceServer(server, &catalogServer{service: s})
+	health.Register(server, health.Checks{"store": s.Ping})
 	reflection.Register(server)
-	return server.Serve(lis)
+	telemetry.ServerMetrics.InitializeMetrics(server)
+	return server
 }
 
 func (s *catalogServer) PostProduct(ctx context.Context, r *pb.PostProductRequest) (*pb.PostProductResponse, error) {
@@ -39,6 +62,7 @@ func (s *catalogServer) PostProduct(ctx context.Context, r *pb.PostProductReques
 		Name:        p.Name,
 		Description: p.Description,
 		Price:       p.Price,
+		Archived:    p.Archived,
 	}}, nil
 }
 func (s *catalogServer) GetProduct(ctx context.Context, r *pb.GetProductRequest) (*pb.GetProductResponse, error) {
@@ -51,17 +75,19 @@ func (s *catalogServer) GetProduct(ctx context.Context, r *pb.GetProductRequest)
 		Name:        p.Name,
 		Description: p.Description,
 		Price:       p.Price,
+		Archived:    p.Archived,
 	}}, nil
 
 }
 func (s *catalogServer) GetProducts(ctx context.Context, r *pb.GetProductsRequest) (*pb.GetProductsResponse, error) {
 	var res []Product
+	var results []SearchResult
 	var err error
 
 	if len(r.Ids) != 0 {
 		res, err = s.service.GetProductsByIDs(ctx, r.Ids)
 	} else if r.Query != "" {
-		res, err = s.service.SearchProducts(ctx, r.Query, r.Skip, r.Take)
+		results, err = s.service.SearchProducts(ctx, r.Query, r.Skip, r.Take)
 	} else {
 		res, err = s.service.GetProducts(ctx, r.Skip, r.Take)
 	}
@@ -69,6 +95,22 @@ func (s *catalogServer) GetProducts(ctx context.Context, r *pb.GetProductsReques
 	if err != nil {
 		return nil, err
 	}
+
+	hits := []*pb.SearchHit{}
+
+	for _, result := range results {
+		highlights := []*pb.SearchHit_Highlight{}
+		for _, h := range result.Highlights {
+			highlights = append(highlights, &pb.SearchHit_Highlight{Field: h.Field, Fragments: h.Fragments})
+		}
+		hits = append(hits, &pb.SearchHit{
+			ProductId:  result.Product.ID,
+			Score:      result.Score,
+			Highlights: highlights,
+		})
+		res = append(res, result.Product)
+	}
+
 	products := []*pb.Product{}
 
 	for _, p := range res {
@@ -77,7 +119,44 @@ func (s *catalogServer) GetProducts(ctx context.Context, r *pb.GetProductsReques
 			Name:        p.Name,
 			Description: p.Description,
 			Price:       p.Price,
+			Archived:    p.Archived,
+		})
+	}
+	return &pb.GetProductsResponse{Products: products, Hits: hits}, nil
+}
+
+func (s *catalogServer) SuggestProducts(ctx context.Context, r *pb.SuggestProductsRequest) (*pb.SuggestProductsResponse, error) {
+	res, err := s.service.SuggestProducts(ctx, r.Prefix, r.Take)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := []*pb.ProductSuggestion{}
+
+	for _, suggestion := range res {
+		highlights := []*pb.ProductSuggestion_Span{}
+		for _, h := range suggestion.Highlights {
+			highlights = append(highlights, &pb.ProductSuggestion_Span{Start: uint32(h.Start), End: uint32(h.End)})
+		}
+		suggestions = append(suggestions, &pb.ProductSuggestion{
+			Id:         suggestion.ID,
+			Name:       suggestion.Name,
+			Highlights: highlights,
 		})
 	}
-	return &pb.GetProductsResponse{Products: products}, nil
+	return &pb.SuggestProductsResponse{Suggestions: suggestions}, nil
+}
+
+func (s *catalogServer) ArchiveProduct(ctx context.Context, r *pb.ArchiveProductRequest) (*pb.ArchiveProductResponse, error) {
+	p, err := s.service.ArchiveProduct(ctx, r.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ArchiveProductResponse{Product: &pb.Product{
+		Id:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       p.Price,
+		Archived:    p.Archived,
+	}}, nil
 }
@@ -2,8 +2,11 @@ package catalog
 
 import (
 	"context"
+	"math"
+	"strings"
 
 	"github.com/segmentio/ksuid"
+	"github.com/valkyraycho/go-microservices/grpcerr"
 )
 
 type Service interface {
@@ -11,7 +14,12 @@ type Service interface {
 	GetProduct(ctx context.Context, id string) (*Product, error)
 	GetProducts(ctx context.Context, skip uint64, take uint64) ([]Product, error)
 	GetProductsByIDs(ctx context.Context, ids []string) ([]Product, error)
-	SearchProducts(ctx context.Context, query string, skip uint64, take uint64) ([]Product, error)
+	SearchProducts(ctx context.Context, query string, skip uint64, take uint64) ([]SearchResult, error)
+	SuggestProducts(ctx context.Context, prefix string, take uint64) ([]Suggestion, error)
+	ArchiveProduct(ctx context.Context, id string) (*Product, error)
+	// Ping returns an error when the store behind the service cannot be
+	// reached.
+	Ping(ctx context.Context) error
 }
 
 type Product struct {
@@ -19,6 +27,9 @@ type Product struct {
 	Name        string  `json:"name"`
 	Description string  `json:"description"`
 	Price       float64 `json:"price"`
+	// Archived products can no longer be ordered, but stay readable for the
+	// orders that already contain them.
+	Archived bool `json:"archived"`
 }
 
 type catalogService struct {
@@ -29,10 +40,24 @@ func NewService(r Repository) Service {
 	return &catalogService{r}
 }
 func (s *catalogService) PostProduct(ctx context.Context, name string, description string, price float64) (*Product, error) {
+	var v grpcerr.Violations
+	if strings.TrimSpace(name) == "" {
+		v.Add("name", "must not be empty")
+	}
+	if math.IsNaN(price) || math.IsInf(price, 0) {
+		v.Add("price", "must be a number")
+	} else if price < 0 {
+		v.Add("price", "must not be negative")
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
 	p := &Product{ID: ksuid.New().String(), Name: name, Description: description, Price: price}
 	if err := s.repository.CreateProduct(ctx, *p); err != nil {
 		return nil, err
 	}
+	productsCreated.Inc()
 	return p, nil
 }
 func (s *catalogService) GetProduct(ctx context.Context, id string) (*Product, error) {
@@ -47,9 +72,30 @@ func (s *catalogService) GetProducts(ctx context.Context, skip uint64, take uint
 func (s *catalogService) GetProductsByIDs(ctx context.Context, ids []string) ([]Product, error) {
 	return s.repository.ListProductsByIDs(ctx, ids)
 }
-func (s *catalogService) SearchProducts(ctx context.Context, query string, skip uint64, take uint64) ([]Product, error) {
+func (s *catalogService) SearchProducts(ctx context.Context, query string, skip uint64, take uint64) ([]SearchResult, error) {
 	if take > 100 || skip == 0 && take == 0 {
 		take = 100
 	}
 	return s.repository.SearchProducts(ctx, query, skip, take)
 }
+func (s *catalogService) SuggestProducts(ctx context.Context, prefix string, take uint64) ([]Suggestion, error) {
+	if strings.TrimSpace(prefix) == "" {
+		return []Suggestion{}, nil
+	}
+	if take > 100 {
+		take = 100
+	} else if take == 0 {
+		take = 10
+	}
+	return s.repository.SuggestProducts(ctx, prefix, take)
+}
+func (s *catalogService) ArchiveProduct(ctx context.Context, id string) (*Product, error) {
+	if err := s.repository.ArchiveProduct(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repository.GetProductByID(ctx, id)
+}
+
+func (s *catalogService) Ping(ctx context.Context) error {
+	return s.repository.Ping(ctx)
+}
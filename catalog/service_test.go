@@ -0,0 +1,37 @@
+package catalog_test
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/valkyraycho/go-microservices/catalog"
+	"github.com/valkyraycho/go-microservices/grpcerr/grpcerrtest"
+)
+
+func TestPostProductValidation(t *testing.T) {
+	s := catalog.NewService(catalog.NewMemoryRepository())
+
+	tests := []struct {
+		name  string
+		price float64
+		want  []string
+	}{
+		{"", 1, []string{"name"}},
+		{"Keyboard", -0.01, []string{"price"}},
+		{"Keyboard", math.NaN(), []string{"price"}},
+		{" ", -1, []string{"name", "price"}},
+	}
+
+	for _, tt := range tests {
+		_, err := s.PostProduct(context.Background(), tt.name, "", tt.price)
+		if got := grpcerrtest.ViolatedFields(t, err); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("PostProduct(%q, %v) violated %v, want %v", tt.name, tt.price, got, tt.want)
+		}
+	}
+
+	if _, err := s.PostProduct(context.Background(), "Sticker", "", 0); err != nil {
+		t.Errorf("PostProduct with a zero price: %v", err)
+	}
+}
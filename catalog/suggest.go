@@ -0,0 +1,130 @@
+package catalog
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Suggestion is a product whose name completes a search prefix.
+type Suggestion struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Highlights []Span `json:"highlights"`
+}
+
+// Span marks the part of a suggested name that matched the prefix, as
+// character offsets with an exclusive end.
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+func newProductDocument(p Product) productDocument {
+	return productDocument{
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       p.Price,
+		Suggest:     suggestInputs(p.Name),
+		Archived:    p.Archived,
+	}
+}
+
+func (d productDocument) product(id string) Product {
+	return Product{
+		ID:          id,
+		Name:        d.Name,
+		Description: d.Description,
+		Price:       d.Price,
+		Archived:    d.Archived,
+	}
+}
+
+func newSuggestion(id string, name string, prefix string) Suggestion {
+	s := Suggestion{ID: id, Name: name, Highlights: []Span{}}
+	if span, _, ok := matchSuggestion(name, prefix); ok {
+		s.Highlights = append(s.Highlights, span)
+	}
+	return s
+}
+
+// suggestInputs returns the strings a prefix is completed against: the name
+// itself and every suffix of it that starts a word, so "Red keyboard" is
+// suggested for both "red" and "key".
+func suggestInputs(name string) []string {
+	inputs := []string{}
+	inWord := false
+	for i, r := range name {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && !inWord {
+			inputs = append(inputs, name[i:])
+		}
+		inWord = isWord
+	}
+	return inputs
+}
+
+// suggestFuzziness is the number of typos tolerated in a prefix, following
+// Elasticsearch's AUTO fuzziness.
+func suggestFuzziness(prefix string) int {
+	switch n := len([]rune(prefix)); {
+	case n < 3:
+		return 0
+	case n < 6:
+		return 1
+	}
+	return 2
+}
+
+// matchSuggestion reports whether prefix completes name within the allowed
+// fuzziness. It returns the matched span and the number of typos, so exact
+// matches can be ranked first.
+func matchSuggestion(name string, prefix string) (Span, int, bool) {
+	p := []rune(strings.ToLower(strings.TrimSpace(prefix)))
+	if len(p) == 0 {
+		return Span{}, 0, false
+	}
+
+	best, bestSpan := -1, Span{}
+	for _, input := range suggestInputs(name) {
+		start := len([]rune(name)) - len([]rune(input))
+		distance, length := prefixDistance(p, []rune(strings.ToLower(input)))
+		if best == -1 || distance < best {
+			best, bestSpan = distance, Span{Start: start, End: start + length}
+		}
+	}
+
+	if best == -1 || best > suggestFuzziness(string(p)) {
+		return Span{}, 0, false
+	}
+	return bestSpan, best, true
+}
+
+// prefixDistance returns the smallest edit distance between p and any prefix
+// of s, along with the length of that prefix.
+func prefixDistance(p []rune, s []rune) (int, int) {
+	row := make([]int, len(s)+1)
+	for j := range row {
+		row[j] = j
+	}
+
+	for i := 1; i <= len(p); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(s); j++ {
+			cost := 1
+			if p[i-1] == s[j-1] {
+				cost = 0
+			}
+			cur := min(row[j]+1, row[j-1]+1, prev+cost)
+			prev, row[j] = row[j], cur
+		}
+	}
+
+	distance, length := row[0], 0
+	for j, d := range row {
+		if d < distance {
+			distance, length = d, j
+		}
+	}
+	return distance, length
+}
@@ -0,0 +1,31 @@
+package catalog
+
+import "testing"
+
+func TestMatchSuggestion(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   string
+		want     Span
+		distance int
+		ok       bool
+	}{
+		{"Red keyboard", "red", Span{0, 3}, 0, true},
+		{"Red keyboard", "KEY", Span{4, 7}, 0, true},
+		{"Red keyboard", "red key", Span{0, 7}, 0, true},
+		{"Red keyboard", "kyeboard", Span{4, 12}, 2, true},
+		{"Mouse", "mose", Span{0, 5}, 1, true},
+		{"Mouse", "mo", Span{0, 2}, 0, true},
+		{"Mouse", "mi", Span{}, 0, false},
+		{"Mouse", "chair", Span{}, 0, false},
+		{"Mouse", " ", Span{}, 0, false},
+		{"Café crème", "crè", Span{5, 8}, 0, true},
+	}
+
+	for _, tt := range tests {
+		span, distance, ok := matchSuggestion(tt.name, tt.prefix)
+		if ok != tt.ok || span != tt.want || distance != tt.distance {
+			t.Errorf("matchSuggestion(%q, %q) = %v, %d, %v, want %v, %d, %v", tt.name, tt.prefix, span, distance, ok, tt.want, tt.distance, tt.ok)
+		}
+	}
+}
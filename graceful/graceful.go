@@ -0,0 +1,89 @@
+// Package graceful runs servers until they are asked to stop, then drains
+// the requests they are still handling before returning.
+package graceful
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// SignalContext returns a context that is canceled on SIGINT or SIGTERM. Once
+// it is, a second signal kills the process as usual.
+func SignalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// ServeGRPC serves on lis until ctx is done. It then stops accepting
+// connections and waits up to timeout for calls in flight to finish, after
+// which the remaining calls, such as open streams, are canceled.
+func ServeGRPC(ctx context.Context, server *grpc.Server, lis net.Listener, timeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() { errc <- server.Serve(lis) }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-stopped:
+	case <-timer.C:
+		slog.Warn("calls still running after the shutdown timeout, canceling them", "timeout", timeout)
+		server.Stop()
+		<-stopped
+	}
+	return <-errc
+}
+
+// ServeHTTP serves server on lis until ctx is done, over TLS if the server
+// has a TLS config. It then stops accepting connections and waits up to
+// timeout for requests in flight to finish before closing the connections
+// that are left.
+func ServeHTTP(ctx context.Context, server *http.Server, lis net.Listener, timeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() {
+		if server.TLSConfig != nil {
+			// The certificate comes from the TLS config.
+			errc <- server.ServeTLS(lis, "", "")
+		} else {
+			errc <- server.Serve(lis)
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("requests still running after the shutdown timeout, closing them", "timeout", timeout)
+		server.Close()
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package graceful_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/valkyraycho/go-microservices/graceful"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// slowServer answers any gRPC method once release is closed, or fails when
+// the call is canceled first. started receives a value when a call arrives.
+func slowServer(started chan<- struct{}, release <-chan struct{}) *grpc.Server {
+	return grpc.NewServer(grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+		if err := stream.RecvMsg(&emptypb.Empty{}); err != nil {
+			return err
+		}
+		started <- struct{}{}
+		select {
+		case <-release:
+			return stream.SendMsg(&emptypb.Empty{})
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}))
+}
+
+// serveGRPC runs ServeGRPC until the returned cancel is called and starts a
+// call to it. The result of ServeGRPC is sent on served and that of the call
+// on called.
+func serveGRPC(t *testing.T, server *grpc.Server, timeout time.Duration) (cancel func(), served, called <-chan error) {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- graceful.ServeGRPC(ctx, server, lis, timeout) }()
+
+	conn, err := grpc.NewClient("passthrough:///slow",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	callErr := make(chan error, 1)
+	go func() {
+		callErr <- conn.Invoke(context.Background(), "/test.Slow/Call", &emptypb.Empty{}, &emptypb.Empty{})
+	}()
+	return cancel, serveErr, callErr
+}
+
+func TestServeGRPCDrains(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	stop, served, called := serveGRPC(t, slowServer(started, release), time.Minute)
+
+	<-started
+	stop()
+
+	select {
+	case err := <-served:
+		t.Fatalf("ServeGRPC returned %v with a call in flight", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-called; err != nil {
+		t.Errorf("call in flight failed: %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("ServeGRPC: %v", err)
+	}
+}
+
+func TestServeGRPCTimeout(t *testing.T) {
+	started := make(chan struct{}, 1)
+	stop, served, called := serveGRPC(t, slowServer(started, nil), 50*time.Millisecond)
+
+	<-started
+	stop()
+
+	if err := <-served; err != nil {
+		t.Errorf("ServeGRPC: %v", err)
+	}
+	if err := <-called; err == nil {
+		t.Error("call that outlived the timeout succeeded")
+	}
+}
+
+func TestServeHTTPDrains(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	served := make(chan error, 1)
+	go func() { served <- graceful.ServeHTTP(ctx, server, lis, time.Minute) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	called := make(chan result, 1)
+	go func() {
+		res, err := http.Get("http://" + lis.Addr().String())
+		if err != nil {
+			called <- result{err: err}
+			return
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		called <- result{string(body), err}
+	}()
+
+	<-started
+	stop()
+	close(release)
+
+	if res := <-called; res.err != nil || res.body != "done" {
+		t.Errorf("request in flight = %q, %v; want %q", res.body, res.err, "done")
+	}
+	if err := <-served; err != nil {
+		t.Errorf("ServeHTTP: %v", err)
+	}
+	if _, err := http.Get("http://" + lis.Addr().String()); err == nil {
+		t.Error("server still accepts requests after shutting down")
+	}
+}
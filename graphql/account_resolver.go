@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"time"
 )
 
 type accountResolver struct {
@@ -10,32 +9,14 @@ type accountResolver struct {
 }
 
 func (r *accountResolver) Orders(ctx context.Context, obj *Account) ([]*Order, error) {
-	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
-	defer cancel()
-
-	orderList, err := r.server.orderClient.GetOrdersForAccount(ctx, obj.ID)
+	orderList, err := loadersFor(ctx).ordersByAccount.Load(ctx, obj.ID)
 	if err != nil {
 		return nil, err
 	}
 
 	orders := []*Order{}
 	for _, o := range orderList {
-		products := []*OrderedProduct{}
-		for _, p := range o.Products {
-			products = append(products, &OrderedProduct{
-				ID:          p.ID,
-				Name:        p.Name,
-				Description: p.Description,
-				Price:       p.Price,
-				Quantity:    int(p.Quantity),
-			})
-		}
-		orders = append(orders, &Order{
-			ID:         o.ID,
-			CreatedAt:  o.CreatedAt,
-			TotalPrice: o.TotalPrice,
-			Products:   products,
-		})
+		orders = append(orders, newOrder(o))
 	}
 	return orders, nil
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type adminKey struct{}
+
+// withAdmin marks requests that send token as a bearer token as coming from
+// an admin. When token is empty nobody is an admin.
+func withAdmin(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if ok && token != "" && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1 {
+			r = r.WithContext(context.WithValue(r.Context(), adminKey{}, true))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isAdmin(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminKey{}).(bool)
+	return admin
+}
+
+// adminDirective implements @admin.
+func adminDirective(ctx context.Context, obj any, next graphql.Resolver) (any, error) {
+	if !isAdmin(ctx) {
+		return nil, status.Error(codes.Unauthenticated, "admin access required")
+	}
+	return next(ctx)
+}
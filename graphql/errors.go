@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/valkyraycho/go-microservices/grpcerr"
+	"github.com/valkyraycho/go-microservices/ratelimit"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// Error codes reported in the extensions of GraphQL errors.
+const (
+	codeNotFound        = "NOT_FOUND"
+	codeInvalidArgument = "INVALID_ARGUMENT"
+	codeUnauthenticated = "UNAUTHENTICATED"
+	codeInternal        = "INTERNAL"
+)
+
+var errorCodes = map[codes.Code]string{
+	codes.NotFound:        codeNotFound,
+	codes.InvalidArgument: codeInvalidArgument,
+	codes.OutOfRange:      codeInvalidArgument,
+	codes.Unauthenticated: codeUnauthenticated,
+}
+
+// FieldError tells the client which input field of a mutation is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// fieldErrors lists the field violations a service attached to s.
+func fieldErrors(s *status.Status) []FieldError {
+	fieldErrors := []FieldError{}
+	for _, detail := range s.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range badRequest.FieldViolations {
+				fieldErrors = append(fieldErrors, FieldError{Field: v.Field, Message: v.Description})
+			}
+		}
+	}
+	return fieldErrors
+}
+
+// invalidFields reports violations the gateway finds itself the same way the
+// services do.
+func invalidFields(violations []*errdetails.BadRequest_FieldViolation) error {
+	s, err := status.New(codes.InvalidArgument, "invalid argument").WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "invalid argument")
+	}
+	return s.Err()
+}
+
+// sentID is an id the client sent for an input field, and the id of the
+// service it stands for.
+type sentID struct {
+	local string
+	sent  string
+}
+
+// withSentIDs rewrites the field violations a service reported for the
+// fields in ids, and the message that lists them, to name the ids the client
+// sent instead of those of the service, which clients never see.
+func withSentIDs(err error, ids map[string]sentID) error {
+	s, ok := status.FromError(err)
+	if !ok || s.Code() != codes.InvalidArgument {
+		return err
+	}
+
+	message := s.Message()
+	details := []protoadapt.MessageV1{}
+	for _, detail := range s.Details() {
+		badRequest, ok := detail.(*errdetails.BadRequest)
+		if !ok {
+			if d, ok := detail.(protoadapt.MessageV1); ok {
+				details = append(details, d)
+			}
+			continue
+		}
+		v := grpcerr.Violations{}
+		for _, fv := range badRequest.FieldViolations {
+			description := fv.Description
+			if id, ok := ids[fv.Field]; ok && id.local != id.sent {
+				description = strings.ReplaceAll(description, id.local, id.sent)
+				message = strings.ReplaceAll(message, fv.Field+" "+fv.Description, fv.Field+" "+description)
+			}
+			v.Add(fv.Field, description)
+		}
+		details = append(details, &errdetails.BadRequest{FieldViolations: v})
+	}
+	return grpcerr.WithDetails(status.New(s.Code(), message), details...)
+}
+
+// presentError sets extensions.code on errors returned by resolvers from the
+// gRPC status of the failed call. Messages of internal failures are logged
+// and replaced, so clients never see the details of a service.
+func presentError(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &grpcErr) {
+		s := grpcErr.GRPCStatus()
+		if code, ok := errorCodes[s.Code()]; ok {
+			gqlErr.Message = s.Message()
+			gqlErr.Extensions = map[string]any{"code": code}
+			if fieldErrors := fieldErrors(s); len(fieldErrors) > 0 {
+				gqlErr.Extensions["fieldErrors"] = fieldErrors
+			}
+			return gqlErr
+		}
+		if s.Code() == codes.ResourceExhausted {
+			// A service throttled the gateway. Clients are told when to
+			// retry if the service said so.
+			gqlErr.Message = s.Message()
+			gqlErr.Extensions = map[string]any{"code": codeRateLimited}
+			if retryAfter, ok := ratelimit.RetryDelay(err); ok {
+				gqlErr.Extensions["retryAfter"] = ratelimit.RetryAfterSeconds(retryAfter)
+			}
+			return gqlErr
+		}
+	} else if errors.As(err, new(*gqlerror.Error)) {
+		// Errors the gateway reports itself, such as invalid arguments,
+		// are meant for the client.
+		return gqlErr
+	}
+
+	slog.ErrorContext(ctx, "internal error", "path", gqlErr.Path.String(), "err", err)
+	gqlErr.Message = "internal server error"
+	gqlErr.Extensions = map[string]any{"code": codeInternal}
+	return gqlErr
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/valkyraycho/go-microservices/ratelimit"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorCodes(t *testing.T) {
+	stack := newTestStack(t, withAdminToken)
+
+	tests := []struct {
+		name    string
+		query   string
+		code    string
+		message string
+	}{
+		{"missing account", `{ accounts(id: "missing") { id } }`, codeNotFound, "account missing not found"},
+		{"missing product", `{ products(id: "missing") { id } }`, codeNotFound, "product missing not found"},
+		{"order for missing account", `mutation { createOrder(order: {accountId: "missing", products: [{id: "p", quantity: 1}]}) { id } }`, codeNotFound, "account not found"},
+		{"missing order", `mutation { updateOrderStatus(orderId: "missing", status: PAID) { id } }`, codeNotFound, "order not found"},
+		{"zero quantity", `mutation { createOrder(order: {accountId: "a", products: [{id: "p", quantity: 0}]}) { id } }`, codeInvalidArgument, "invalid argument: products[0].quantity must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := postErrors(t, stack, tt.query, asAdmin)
+			if len(errs) != 1 {
+				t.Fatalf("errors = %+v, want one", errs)
+			}
+			if errs[0].Extensions["code"] != tt.code || errs[0].Message != tt.message {
+				t.Errorf("error = %+v, want %s %q", errs[0], tt.code, tt.message)
+			}
+		})
+	}
+}
+
+func TestMutationFieldErrors(t *testing.T) {
+	stack := newTestStack(t)
+
+	var account struct {
+		CreateAccount struct{ ID string }
+	}
+	stack.MustPost(`mutation { createAccount(account: {name: "alice"}) { id } }`, &account)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []any
+	}{
+		{
+			"empty account name",
+			`mutation { createAccount(account: {name: " "}) { id } }`,
+			[]any{fieldError("name", "must not be empty")},
+		},
+		{
+			"long account name",
+			`mutation { createAccount(account: {name: "a name that is far too long"}) { id } }`,
+			[]any{fieldError("name", "must be at most 24 characters")},
+		},
+		{
+			"invalid product",
+			`mutation { createProduct(product: {name: "", description: "", price: -1}) { id } }`,
+			[]any{fieldError("name", "must not be empty"), fieldError("price", "must not be negative")},
+		},
+		{
+			"negative quantity",
+			`mutation { createOrder(order: {accountId: "a", products: [{id: "p", quantity: 1}, {id: "q", quantity: -1}]}) { id } }`,
+			[]any{fieldError("products[1].quantity", "must be positive")},
+		},
+		{
+			// 4294967297 would wrap around to 1 as an unsigned 32-bit
+			// quantity.
+			"huge quantity",
+			`mutation { createOrder(order: {accountId: "a", products: [{id: "p", quantity: 4294967297}]}) { id } }`,
+			[]any{fieldError("products[0].quantity", "must be at most 2147483647")},
+		},
+		{
+			"unknown product",
+			`mutation { createOrder(order: {accountId: "` + account.CreateAccount.ID + `", products: [{id: "missing", quantity: 1}]}) { id } }`,
+			[]any{fieldError("products[0].id", "product missing not found")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := postErrors(t, stack, tt.query)
+			if len(errs) != 1 {
+				t.Fatalf("errors = %+v, want one", errs)
+			}
+			if errs[0].Extensions["code"] != codeInvalidArgument {
+				t.Errorf("code = %v, want %s", errs[0].Extensions["code"], codeInvalidArgument)
+			}
+			if got := errs[0].Extensions["fieldErrors"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fieldErrors = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func fieldError(field, message string) any {
+	return map[string]any{"field": field, "message": message}
+}
+
+func TestPresentErrorRedactsInternalErrors(t *testing.T) {
+	ctx := context.Background()
+
+	for _, err := range []error{
+		status.Error(codes.Internal, "pq: relation \"accounts\" does not exist"),
+		status.Error(codes.Unavailable, "connection refused"),
+		errors.New("unexpected EOF"),
+	} {
+		got := presentError(ctx, err)
+		if got.Message != "internal server error" || got.Extensions["code"] != codeInternal {
+			t.Errorf("presentError(%v) = %q %v, want a redacted INTERNAL error", err, got.Message, got.Extensions)
+		}
+	}
+
+	invalid := gqlerror.Errorf("invalid value for argument")
+	if got := presentError(ctx, invalid); got.Message != invalid.Message {
+		t.Errorf("presentError(%v) = %q, want the message kept", invalid, got.Message)
+	}
+}
+
+func TestPresentErrorThrottled(t *testing.T) {
+	got := presentError(context.Background(), ratelimit.Exhausted(1500*time.Millisecond))
+	if got.Extensions["code"] != codeRateLimited || got.Extensions["retryAfter"] != 2 {
+		t.Errorf("presentError = %q %v, want %s with a retry delay of 2s", got.Message, got.Extensions, codeRateLimited)
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"github.com/99designs/gqlgen/graphql"
 	"github.com/valkyraycho/go-microservices/account"
 	"github.com/valkyraycho/go-microservices/catalog"
+	"github.com/valkyraycho/go-microservices/health"
 	"github.com/valkyraycho/go-microservices/order"
+	"github.com/valkyraycho/go-microservices/resilience"
+	"google.golang.org/grpc"
 )
 
 type Server struct {
@@ -13,19 +16,19 @@ type Server struct {
 	orderClient   *order.Client
 }
 
-func NewGraphQLServer(accountUrl, catalogURL, orderURL string) (*Server, error) {
-	accountClient, err := account.NewClient(accountUrl)
+func NewGraphQLServer(accountUrl, catalogURL, orderURL string, policy resilience.Config, opts ...grpc.DialOption) (*Server, error) {
+	accountClient, err := account.NewClient(accountUrl, policy, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	catalogClient, err := catalog.NewClient(catalogURL)
+	catalogClient, err := catalog.NewClient(catalogURL, policy, opts...)
 	if err != nil {
 		accountClient.Close()
 		return nil, err
 	}
 
-	orderClient, err := order.NewClient(orderURL)
+	orderClient, err := order.NewClient(orderURL, policy, opts...)
 	if err != nil {
 		accountClient.Close()
 		catalogClient.Close()
@@ -39,6 +42,21 @@ func NewGraphQLServer(accountUrl, catalogURL, orderURL string) (*Server, error)
 	}, nil
 }
 
+func (s *Server) Close() {
+	s.accountClient.Close()
+	s.catalogClient.Close()
+	s.orderClient.Close()
+}
+
+// checks reports whether the services behind the gateway are serving.
+func (s *Server) checks() health.Checks {
+	return health.Checks{
+		"account": s.accountClient.Ping,
+		"catalog": s.catalogClient.Ping,
+		"order":   s.orderClient.Ping,
+	}
+}
+
 func (s *Server) Mutation() MutationResolver {
 	return &mutationResolver{
 		server: s,
@@ -49,14 +67,37 @@ func (s *Server) Account() AccountResolver {
 		server: s,
 	}
 }
+func (s *Server) Order() OrderResolver {
+	return &orderResolver{
+		server: s,
+	}
+}
+func (s *Server) OrderedProduct() OrderedProductResolver {
+	return &orderedProductResolver{
+		server: s,
+	}
+}
+func (s *Server) Product() ProductResolver {
+	return &productResolver{
+		server: s,
+	}
+}
 func (s *Server) Query() QueryResolver {
 	return &queryResolver{
 		server: s,
 	}
 }
 
+func (s *Server) Subscription() SubscriptionResolver {
+	return &subscriptionResolver{
+		server: s,
+	}
+}
+
 func (s *Server) ToExecutableSchema() graphql.ExecutableSchema {
 	return NewExecutableSchema(Config{
-		Resolvers: s,
+		Resolvers:  s,
+		Directives: DirectiveRoot{Admin: adminDirective},
+		Complexity: newComplexityRoot(),
 	})
 }
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+)
+
+func TestCreateAndQueryAccount(t *testing.T) {
+	stack := newTestStack(t)
+
+	var created struct {
+		CreateAccount struct{ ID, Name string }
+	}
+	stack.MustPost(`mutation($name: String!) { createAccount(account: {name: $name}) { id name } }`, &created, client.Var("name", "alice"))
+
+	if created.CreateAccount.ID == "" || created.CreateAccount.Name != "alice" {
+		t.Fatalf("createAccount = %+v", created.CreateAccount)
+	}
+
+	var queried struct {
+		Accounts []struct{ ID, Name string }
+	}
+	stack.MustPost(`query($id: String) { accounts(id: $id) { id name } }`, &queried, client.Var("id", created.CreateAccount.ID))
+
+	if len(queried.Accounts) != 1 || queried.Accounts[0] != created.CreateAccount {
+		t.Errorf("accounts(id) = %+v, want [%+v]", queried.Accounts, created.CreateAccount)
+	}
+}
+
+func TestSearchProducts(t *testing.T) {
+	stack := newTestStack(t)
+
+	for _, name := range []string{"Red keyboard", "Blue mouse", "Red chair"} {
+		var created struct {
+			CreateProduct struct{ ID string }
+		}
+		stack.MustPost(`mutation($name: String!) { createProduct(product: {name: $name, description: "", price: 1}) { id } }`, &created, client.Var("name", name))
+	}
+
+	var res struct {
+		Products []struct{ Name string }
+	}
+	stack.MustPost(`{ products(query: "red") { name } }`, &res)
+
+	if len(res.Products) != 2 {
+		t.Errorf("products(query: red) = %+v, want 2 products", res.Products)
+	}
+}
+
+func TestSearchProductsHighlights(t *testing.T) {
+	stack := newTestStack(t)
+
+	for _, p := range [][2]string{{"Red keyboard", "Mechanical"}, {"Mouse", "Wireless and red"}} {
+		var created struct {
+			CreateProduct struct{ ID string }
+		}
+		stack.MustPost(`mutation($name: String!, $description: String!) { createProduct(product: {name: $name, description: $description, price: 1}) { id } }`, &created, client.Var("name", p[0]), client.Var("description", p[1]))
+	}
+
+	var res struct {
+		SearchProducts []struct {
+			Product    struct{ Name string }
+			Score      float64
+			Highlights []struct {
+				Field     string
+				Fragments []string
+			}
+		}
+	}
+	stack.MustPost(`{ searchProducts(query: "red") { product { name } score highlights { field fragments } } }`, &res)
+
+	if len(res.SearchProducts) != 2 {
+		t.Fatalf("searchProducts = %+v, want 2 results", res.SearchProducts)
+	}
+	first := res.SearchProducts[0]
+	if first.Product.Name != "Red keyboard" || first.Score <= res.SearchProducts[1].Score {
+		t.Errorf("searchProducts ranked %+v first, want Red keyboard with the highest score", first)
+	}
+	if len(first.Highlights) != 1 || first.Highlights[0].Field != "name" || first.Highlights[0].Fragments[0] != "<mark>Red</mark> keyboard" {
+		t.Errorf("searchProducts highlights = %+v, want Red marked in name", first.Highlights)
+	}
+}
+
+func TestCreateOrder(t *testing.T) {
+	stack := newTestStack(t)
+
+	var acc struct {
+		CreateAccount struct{ ID string }
+	}
+	stack.MustPost(`mutation { createAccount(account: {name: "alice"}) { id } }`, &acc)
+
+	var keyboard, mouse struct {
+		CreateProduct struct{ ID string }
+	}
+	stack.MustPost(`mutation { createProduct(product: {name: "Keyboard", description: "", price: 10}) { id } }`, &keyboard)
+	stack.MustPost(`mutation { createProduct(product: {name: "Mouse", description: "", price: 2.5}) { id } }`, &mouse)
+
+	var created struct {
+		CreateOrder struct {
+			ID         string
+			TotalPrice float64
+		}
+	}
+	stack.MustPost(`mutation($order: OrderInput!) { createOrder(order: $order) { id totalPrice } }`, &created, client.Var("order", map[string]any{
+		"accountId": acc.CreateAccount.ID,
+		"products": []map[string]any{
+			{"id": keyboard.CreateProduct.ID, "quantity": 1},
+			{"id": mouse.CreateProduct.ID, "quantity": 2},
+		},
+	}))
+
+	if created.CreateOrder.TotalPrice != 15 {
+		t.Errorf("createOrder totalPrice = %v, want 15", created.CreateOrder.TotalPrice)
+	}
+
+	var res struct {
+		Accounts []struct {
+			Orders []struct {
+				ID       string
+				Products []struct {
+					Name     string
+					Quantity int
+				}
+			}
+		}
+	}
+	stack.MustPost(`query($id: String) { accounts(id: $id) { orders { id products { name quantity } } } }`, &res, client.Var("id", acc.CreateAccount.ID))
+
+	if len(res.Accounts) != 1 || len(res.Accounts[0].Orders) != 1 {
+		t.Fatalf("accounts(id) = %+v, want one account with one order", res.Accounts)
+	}
+	if o := res.Accounts[0].Orders[0]; o.ID != created.CreateOrder.ID || len(o.Products) != 2 {
+		t.Errorf("order = %+v, want %s with 2 products", o, created.CreateOrder.ID)
+	}
+}
+
+func TestCreateOrderUnknownAccount(t *testing.T) {
+	stack := newTestStack(t)
+
+	var res struct {
+		CreateOrder *struct{ ID string }
+	}
+	err := stack.Post(`mutation { createOrder(order: {accountId: "missing", products: [{id: "p", quantity: 1}]}) { id } }`, &res)
+	if err == nil {
+		t.Error("createOrder for an unknown account succeeded")
+	}
+}
+
+func TestCreateOrderMergesDuplicateProducts(t *testing.T) {
+	stack := newTestStack(t)
+
+	var acc struct {
+		CreateAccount struct{ ID string }
+	}
+	stack.MustPost(`mutation { createAccount(account: {name: "alice"}) { id } }`, &acc)
+
+	var keyboard struct {
+		CreateProduct struct{ ID string }
+	}
+	stack.MustPost(`mutation { createProduct(product: {name: "Keyboard", description: "", price: 10}) { id } }`, &keyboard)
+
+	var created struct {
+		CreateOrder struct {
+			TotalPrice float64
+			Products   []struct {
+				ID       string
+				Quantity int
+			}
+		}
+	}
+	stack.MustPost(`mutation($order: OrderInput!) { createOrder(order: $order) { totalPrice products { id quantity } } }`, &created, client.Var("order", map[string]any{
+		"accountId": acc.CreateAccount.ID,
+		"products": []map[string]any{
+			{"id": keyboard.CreateProduct.ID, "quantity": 1},
+			{"id": keyboard.CreateProduct.ID, "quantity": 2},
+		},
+	}))
+
+	o := created.CreateOrder
+	if len(o.Products) != 1 || o.Products[0].ID != keyboard.CreateProduct.ID || o.Products[0].Quantity != 3 || o.TotalPrice != 30 {
+		t.Errorf("createOrder = %+v, want 3 keyboards for 30", o)
+	}
+}
+
+func TestCreateOrderRejectsUnavailableProducts(t *testing.T) {
+	stack := newTestStack(t, withAdminToken)
+
+	var acc struct {
+		CreateAccount struct{ ID string }
+	}
+	stack.MustPost(`mutation { createAccount(account: {name: "alice"}) { id } }`, &acc)
+
+	var keyboard, mouse struct {
+		CreateProduct struct{ ID string }
+	}
+	stack.MustPost(`mutation { createProduct(product: {name: "Keyboard", description: "", price: 10}) { id } }`, &keyboard)
+	stack.MustPost(`mutation { createProduct(product: {name: "Mouse", description: "", price: 2.5}) { id } }`, &mouse)
+
+	var archived struct {
+		ArchiveProduct struct{ Archived bool }
+	}
+	stack.MustPost(`mutation($id: String!) { archiveProduct(id: $id) { archived } }`, &archived, client.Var("id", mouse.CreateProduct.ID), asAdmin)
+	if !archived.ArchiveProduct.Archived {
+		t.Fatal("archiveProduct did not archive the product")
+	}
+
+	errs := postErrors(t, stack, `mutation($order: OrderInput!) { createOrder(order: $order) { id } }`, client.Var("order", map[string]any{
+		"accountId": acc.CreateAccount.ID,
+		"products": []map[string]any{
+			{"id": keyboard.CreateProduct.ID, "quantity": 1},
+			{"id": mouse.CreateProduct.ID, "quantity": 1},
+			{"id": "missing", "quantity": 1},
+		},
+	}))
+
+	// Products are named by the ids the client sent.
+	want := []any{
+		fieldError("products[1].id", "product "+mouse.CreateProduct.ID+" is archived"),
+		fieldError("products[2].id", "product missing not found"),
+	}
+	if len(errs) != 1 || !reflect.DeepEqual(errs[0].Extensions["fieldErrors"], want) {
+		t.Fatalf("errors = %+v, want fieldErrors %v", errs, want)
+	}
+	if !strings.Contains(errs[0].Message, mouse.CreateProduct.ID) {
+		t.Errorf("message = %q, want it to name %s", errs[0].Message, mouse.CreateProduct.ID)
+	}
+
+	var res struct {
+		Accounts []struct{ Orders []struct{ ID string } }
+	}
+	stack.MustPost(`query($id: String) { accounts(id: $id) { orders { id } } }`, &res, client.Var("id", acc.CreateAccount.ID))
+	if len(res.Accounts) != 1 || len(res.Accounts[0].Orders) != 0 {
+		t.Errorf("accounts(id) = %+v, want no orders", res.Accounts)
+	}
+}
+
+func TestCreateOrderRejectsEmptyOrders(t *testing.T) {
+	stack := newTestStack(t)
+
+	errs := postErrors(t, stack, `mutation { createOrder(order: {accountId: "a", products: []}) { id } }`)
+	if len(errs) != 1 || !reflect.DeepEqual(errs[0].Extensions["fieldErrors"], []any{fieldError("products", "must not be empty")}) {
+		t.Errorf("errors = %+v, want products must not be empty", errs)
+	}
+}
+
+func TestProductSuggestions(t *testing.T) {
+	stack := newTestStack(t)
+
+	for _, name := range []string{"Red keyboard", "Mouse"} {
+		var created struct {
+			CreateProduct struct{ ID string }
+		}
+		stack.MustPost(`mutation($name: String!) { createProduct(product: {name: $name, description: "", price: 1}) { id } }`, &created, client.Var("name", name))
+	}
+
+	var res struct {
+		ProductSuggestions []struct {
+			Name       string
+			Highlights []struct{ Start, End int }
+		}
+	}
+	stack.MustPost(`{ productSuggestions(prefix: "keyb", limit: 5) { name highlights { start end } } }`, &res)
+
+	if len(res.ProductSuggestions) != 1 {
+		t.Fatalf("productSuggestions = %+v, want one suggestion", res.ProductSuggestions)
+	}
+	s := res.ProductSuggestions[0]
+	if s.Name != "Red keyboard" || len(s.Highlights) != 1 || s.Highlights[0].Start != 4 || s.Highlights[0].End != 8 {
+		t.Errorf("productSuggestions = %+v, want Red keyboard highlighted at 4-8", s)
+	}
+}
+
+func TestArchiveProductRequiresAdmin(t *testing.T) {
+	stack := newTestStack(t, withAdminToken)
+
+	var created struct {
+		CreateProduct struct{ ID string }
+	}
+	stack.MustPost(`mutation { createProduct(product: {name: "Keyboard", description: "", price: 10}) { id } }`, &created)
+
+	query := `mutation($id: String!) { archiveProduct(id: $id) { archived } }`
+	for name, options := range map[string][]client.Option{
+		"no token":    {client.Var("id", created.CreateProduct.ID)},
+		"wrong token": {client.Var("id", created.CreateProduct.ID), client.AddHeader("Authorization", "Bearer guess")},
+	} {
+		errs := postErrors(t, stack, query, options...)
+		if len(errs) != 1 || errs[0].Extensions["code"] != codeUnauthenticated {
+			t.Errorf("%s: errors = %+v, want UNAUTHENTICATED", name, errs)
+		}
+	}
+
+	var res struct {
+		Products []struct{ Archived bool }
+	}
+	stack.MustPost(`query($id: String) { products(id: $id) { archived } }`, &res, client.Var("id", created.CreateProduct.ID))
+	if len(res.Products) != 1 || res.Products[0].Archived {
+		t.Errorf("products = %+v, want the product still orderable", res.Products)
+	}
+}
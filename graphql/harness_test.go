@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/kelseyhightower/envconfig"
+	"github.com/valkyraycho/go-microservices/account"
+	"github.com/valkyraycho/go-microservices/catalog"
+	"github.com/valkyraycho/go-microservices/order"
+	"github.com/valkyraycho/go-microservices/resilience"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testStack runs the account, catalog and order services in memory, connected
+// over bufconn, behind a gateway Server.
+type testStack struct {
+	*client.Client
+	handler   http.Handler
+	server    *Server
+	listeners map[string]*bufconn.Listener
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+// newTestStack starts every service with an in-memory repository. The gateway
+// uses the default AppConfig, changed by configure. Everything is torn down
+// when the test ends.
+func newTestStack(t *testing.T, configure ...func(*AppConfig)) *testStack {
+	t.Helper()
+
+	stack := &testStack{listeners: map[string]*bufconn.Listener{}, calls: map[string]int{}}
+	dialer := grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return stack.listeners[addr].DialContext(ctx)
+	})
+
+	stack.serve(t, "account", account.NewGRPCServer(account.NewService(account.NewMemoryRepository())))
+	stack.serve(t, "catalog", catalog.NewGRPCServer(catalog.NewService(catalog.NewMemoryRepository())))
+
+	accountClient, err := account.NewClient("passthrough:///account", resilience.Config{}, dialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(accountClient.Close)
+
+	catalogClient, err := catalog.NewClient("passthrough:///catalog", resilience.Config{}, dialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(catalogClient.Close)
+
+	stack.serve(t, "order", order.NewGRPCServer(order.NewService(order.NewMemoryRepository()), accountClient, catalogClient))
+
+	stack.server, err = NewGraphQLServer("passthrough:///account", "passthrough:///catalog", "passthrough:///order", resilience.Config{}, dialer, grpc.WithChainUnaryInterceptor(stack.count))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stack.server.Close)
+
+	var cfg AppConfig
+	if err := envconfig.Process("", &cfg); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range configure {
+		c(&cfg)
+	}
+
+	h, err := newHandler(stack.server, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stack.handler = h
+	stack.Client = client.New(h)
+	return stack
+}
+
+// asAdmin authenticates a request with the admin token of stacks made with
+// withAdminToken.
+var asAdmin = client.AddHeader("Authorization", "Bearer secret")
+
+// withAdminToken configures the gateway with the token asAdmin sends.
+func withAdminToken(cfg *AppConfig) {
+	cfg.AdminToken = "secret"
+}
+
+// count records every call the gateway makes to the services.
+func (s *testStack) count(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	s.mu.Lock()
+	s.calls[method]++
+	s.mu.Unlock()
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// Calls returns how many times the gateway called method, given as
+// "/package.Service/Method".
+func (s *testStack) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+func (s *testStack) serve(t *testing.T, name string, server *grpc.Server) {
+	lis := bufconn.Listen(1 << 20)
+	s.listeners[name] = lis
+
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/valkyraycho/go-microservices/health"
+)
+
+func TestReadyz(t *testing.T) {
+	readyz := func(stack *testStack) (int, []string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		health.Handler(stack.server.checks()).ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+
+		var body struct {
+			Failed []string `json:"failed"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, body.Failed
+	}
+
+	t.Run("Ready", func(t *testing.T) {
+		if code, failed := readyz(newTestStack(t)); code != http.StatusOK {
+			t.Errorf("status code = %d, want %d; failed: %v", code, http.StatusOK, failed)
+		}
+	})
+
+	t.Run("ServiceDown", func(t *testing.T) {
+		stack := newTestStack(t)
+		// The order service depends on the catalog service, so it stops
+		// serving too.
+		stack.listeners["catalog"].Close()
+
+		code, failed := readyz(stack)
+		if code != http.StatusServiceUnavailable {
+			t.Errorf("status code = %d, want %d", code, http.StatusServiceUnavailable)
+		}
+		if want := []string{"catalog", "order"}; !slices.Equal(failed, want) {
+			t.Errorf("failed = %v, want %v", failed, want)
+		}
+	})
+}
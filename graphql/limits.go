@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const (
+	// maxPageSize is the most items the services return for one page, and the
+	// page size assumed when a query does not ask for one.
+	maxPageSize = 100
+	// defaultSuggestionLimit matches the catalog service's default.
+	defaultSuggestionLimit = 10
+	// ordersPerAccount and productsPerOrder estimate the size of lists that
+	// cannot be paginated.
+	ordersPerAccount = 10
+	productsPerOrder = 5
+)
+
+// newComplexityRoot prices list fields by how many items they can return, so
+// the cost of a query grows with the pages it asks for.
+func newComplexityRoot() ComplexityRoot {
+	var c ComplexityRoot
+
+	c.Query.Accounts = func(childComplexity int, pagination *PaginationInput, id *string) int {
+		if id != nil {
+			return 1 + childComplexity
+		}
+		return 1 + pageSize(pagination)*childComplexity
+	}
+	c.Query.Products = func(childComplexity int, pagination *PaginationInput, query *string, id *string) int {
+		if id != nil {
+			return 1 + childComplexity
+		}
+		return 1 + pageSize(pagination)*childComplexity
+	}
+	c.Query.SearchProducts = func(childComplexity int, query string, pagination *PaginationInput) int {
+		return 1 + pageSize(pagination)*childComplexity
+	}
+	c.Query.ProductSuggestions = func(childComplexity int, prefix string, limit *int) int {
+		n := defaultSuggestionLimit
+		if limit != nil && *limit > 0 {
+			n = min(*limit, maxPageSize)
+		}
+		return 1 + n*childComplexity
+	}
+	c.Query.Nodes = func(childComplexity int, ids []string) int {
+		return 1 + len(ids)*childComplexity
+	}
+	c.Product.Orders = func(childComplexity int, pagination *PaginationInput) int {
+		return 1 + pageSize(pagination)*childComplexity
+	}
+	c.Account.Orders = func(childComplexity int) int {
+		return 1 + ordersPerAccount*childComplexity
+	}
+	c.Order.Products = func(childComplexity int) int {
+		return 1 + productsPerOrder*childComplexity
+	}
+
+	return c
+}
+
+// pageSize is the number of items a paginated field can return.
+func pageSize(p *PaginationInput) int {
+	if p == nil || p.Take == nil || *p.Take <= 0 || *p.Take > maxPageSize {
+		return maxPageSize
+	}
+	return *p.Take
+}
+
+// queryLimits rejects operations that are nested deeper than MaxDepth or cost
+// more than MaxComplexity before any resolver runs. A zero limit is not
+// enforced.
+type queryLimits struct {
+	MaxDepth      int
+	MaxComplexity int
+
+	es graphql.ExecutableSchema
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = &queryLimits{}
+
+func (l *queryLimits) ExtensionName() string {
+	return "QueryLimits"
+}
+
+func (l *queryLimits) Validate(schema graphql.ExecutableSchema) error {
+	l.es = schema
+	return nil
+}
+
+func (l *queryLimits) MutateOperationContext(ctx context.Context, opCtx *graphql.OperationContext) *gqlerror.Error {
+	op := opCtx.Operation
+
+	if depth := selectionDepth(op.SelectionSet); l.MaxDepth > 0 && depth > l.MaxDepth {
+		return &gqlerror.Error{
+			Message: fmt.Sprintf("operation has depth %d, which exceeds the limit of %d", depth, l.MaxDepth),
+			Extensions: map[string]any{
+				"code":     "DEPTH_LIMIT_EXCEEDED",
+				"depth":    depth,
+				"maxDepth": l.MaxDepth,
+			},
+		}
+	}
+
+	if cost := complexity.Calculate(l.es, op, opCtx.Variables); l.MaxComplexity > 0 && cost > l.MaxComplexity {
+		return &gqlerror.Error{
+			Message: fmt.Sprintf("operation has complexity %d, which exceeds the limit of %d", cost, l.MaxComplexity),
+			Extensions: map[string]any{
+				"code":          "COMPLEXITY_LIMIT_EXCEEDED",
+				"complexity":    cost,
+				"maxComplexity": l.MaxComplexity,
+			},
+		}
+	}
+
+	return nil
+}
+
+// selectionDepth counts the levels of fields in a selection set. Fragments
+// do not add a level of their own, and introspection fields are ignored so
+// tools can always load the schema.
+func selectionDepth(set ast.SelectionSet) int {
+	depth := 0
+	for _, selection := range set {
+		d := 0
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name, "__") {
+				continue
+			}
+			d = 1 + selectionDepth(s.SelectionSet)
+		case *ast.FragmentSpread:
+			d = selectionDepth(s.Definition.SelectionSet)
+		case *ast.InlineFragment:
+			d = selectionDepth(s.SelectionSet)
+		}
+		depth = max(depth, d)
+	}
+	return depth
+}
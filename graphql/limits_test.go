@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+)
+
+type responseError struct {
+	Message    string
+	Extensions map[string]any
+}
+
+func postErrors(t *testing.T, stack *testStack, query string, options ...client.Option) []responseError {
+	t.Helper()
+
+	res, err := stack.RawPost(query, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var errs []responseError
+	if len(res.Errors) > 0 {
+		if err := json.Unmarshal(res.Errors, &errs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return errs
+}
+
+func TestQueryDepthLimit(t *testing.T) {
+	stack := newTestStack(t, func(cfg *AppConfig) { cfg.MaxQueryDepth = 3 })
+
+	if errs := postErrors(t, stack, `{ accounts { orders { id } } }`); len(errs) != 0 {
+		t.Fatalf("depth 3 query failed: %+v", errs)
+	}
+
+	errs := postErrors(t, stack, `
+		query { ...A }
+		fragment A on Query { accounts { orders { products { id } } } }`)
+	if len(errs) != 1 {
+		t.Fatalf("errors = %+v, want one", errs)
+	}
+	ext := errs[0].Extensions
+	if ext["code"] != "DEPTH_LIMIT_EXCEEDED" || ext["depth"] != 4.0 || ext["maxDepth"] != 3.0 {
+		t.Errorf("extensions = %v", ext)
+	}
+}
+
+func TestQueryDepthLimitIgnoresIntrospection(t *testing.T) {
+	stack := newTestStack(t, func(cfg *AppConfig) {
+		cfg.MaxQueryDepth = 1
+		cfg.Introspection = true
+	})
+
+	if errs := postErrors(t, stack, `{ __schema { types { fields { type { ofType { name } } } } } }`); len(errs) != 0 {
+		t.Errorf("introspection failed: %+v", errs)
+	}
+}
+
+func TestIntrospectionIsOffByDefault(t *testing.T) {
+	stack := newTestStack(t)
+
+	errs := postErrors(t, stack, `{ __schema { queryType { name } } }`)
+	if len(errs) != 1 || !strings.Contains(errs[0].Message, "introspection disabled") {
+		t.Errorf("errors = %+v, want introspection refused", errs)
+	}
+}
+
+func TestQueryComplexityLimit(t *testing.T) {
+	stack := newTestStack(t, func(cfg *AppConfig) { cfg.MaxQueryComplexity = 500 })
+
+	tests := []struct {
+		name  string
+		query string
+		take  int
+		cost  float64
+	}{
+		// 1 + take * (id + name)
+		{"small page", `query($take: Int) { products(pagination: {take: $take}) { id name } }`, 10, 21},
+		// 1 + take * (id + orders(1 + 10 * (id + products(1 + 5 * id))))
+		{"nested lists", `query($take: Int) { accounts(pagination: {take: $take}) { id orders { id products { id } } } }`, 2, 145},
+		{"large page", `query($take: Int) { accounts(pagination: {take: $take}) { id orders { id products { id } } } }`, 20, 1441},
+		{"default page", `query($take: Int) { products(pagination: {take: $take}) { id name } }`, 0, 201},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := postErrors(t, stack, tt.query, client.Var("take", tt.take))
+
+			if tt.cost <= 500 {
+				if len(errs) != 0 {
+					t.Fatalf("errors = %+v, want none", errs)
+				}
+				return
+			}
+
+			if len(errs) != 1 {
+				t.Fatalf("errors = %+v, want one", errs)
+			}
+			ext := errs[0].Extensions
+			if ext["code"] != "COMPLEXITY_LIMIT_EXCEEDED" || ext["complexity"] != tt.cost || ext["maxComplexity"] != 500.0 {
+				t.Errorf("extensions = %v, want complexity %v", ext, tt.cost)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/valkyraycho/go-microservices/account"
+	"github.com/valkyraycho/go-microservices/catalog"
+	"github.com/valkyraycho/go-microservices/order"
+	"github.com/vikstrous/dataloadgen"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// loaderWait is how long a loader collects keys before it issues its batched
+// call.
+const loaderWait = 2 * time.Millisecond
+
+type loadersKey struct{}
+
+// loaders batch the lookups made while resolving one GraphQL response, so
+// sibling fields issue one call per service instead of one call each.
+type loaders struct {
+	ordersByAccount *dataloadgen.Loader[string, []order.Order]
+	accountsByID    *dataloadgen.Loader[string, *account.Account]
+	productsByID    *dataloadgen.Loader[string, *catalog.Product]
+	ordersByID      *dataloadgen.Loader[string, *order.Order]
+}
+
+func newLoaders(s *Server) *loaders {
+	return &loaders{
+		ordersByAccount: dataloadgen.NewLoader(s.fetchOrdersByAccount, dataloadgen.WithWait(loaderWait)),
+		accountsByID:    dataloadgen.NewLoader(s.fetchAccountsByID, dataloadgen.WithWait(loaderWait)),
+		productsByID:    dataloadgen.NewLoader(s.fetchProductsByID, dataloadgen.WithWait(loaderWait)),
+		ordersByID:      dataloadgen.NewLoader(s.fetchOrdersByID, dataloadgen.WithWait(loaderWait)),
+	}
+}
+
+// withLoaders gives every response its own loaders, so cached results never
+// outlive a request. A subscription sends a response for each event, so each
+// event sees the accounts and products as they are when it is sent.
+func withLoaders(s *Server) graphql.ResponseMiddleware {
+	return func(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+		return next(context.WithValue(ctx, loadersKey{}, newLoaders(s)))
+	}
+}
+
+func loadersFor(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+func (s *Server) fetchOrdersByAccount(ctx context.Context, accountIDs []string) ([][]order.Order, []error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	byAccount, err := s.orderClient.GetOrdersForAccounts(ctx, accountIDs)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	orders := make([][]order.Order, len(accountIDs))
+	for i, id := range accountIDs {
+		orders[i] = byAccount[id]
+	}
+	return orders, nil
+}
+
+func (s *Server) fetchAccountsByID(ctx context.Context, ids []string) ([]*account.Account, []error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	accountList, err := s.accountClient.GetAccountsByIDs(ctx, ids)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	byID := make(map[string]*account.Account, len(accountList))
+	for i := range accountList {
+		byID[accountList[i].ID] = &accountList[i]
+	}
+
+	accounts := make([]*account.Account, len(ids))
+	errs := make([]error, len(ids))
+	for i, id := range ids {
+		if accounts[i] = byID[id]; accounts[i] == nil {
+			errs[i] = status.Errorf(codes.NotFound, "account %s not found", id)
+		}
+	}
+	return accounts, errs
+}
+
+func (s *Server) fetchProductsByID(ctx context.Context, ids []string) ([]*catalog.Product, []error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	productList, err := s.catalogClient.GetProducts(ctx, 0, 0, ids, "")
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	byID := make(map[string]*catalog.Product, len(productList))
+	for i := range productList {
+		byID[productList[i].ID] = &productList[i]
+	}
+
+	products := make([]*catalog.Product, len(ids))
+	errs := make([]error, len(ids))
+	for i, id := range ids {
+		if products[i] = byID[id]; products[i] == nil {
+			errs[i] = status.Errorf(codes.NotFound, "product %s not found", id)
+		}
+	}
+	return products, errs
+}
+
+func (s *Server) fetchOrdersByID(ctx context.Context, ids []string) ([]*order.Order, []error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	orderList, err := s.orderClient.GetOrdersByIDs(ctx, ids)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	byID := make(map[string]*order.Order, len(orderList))
+	for i := range orderList {
+		byID[orderList[i].ID] = &orderList[i]
+	}
+
+	orders := make([]*order.Order, len(ids))
+	errs := make([]error, len(ids))
+	for i, id := range ids {
+		if orders[i] = byID[id]; orders[i] == nil {
+			errs[i] = status.Errorf(codes.NotFound, "order %s not found", id)
+		}
+	}
+	return orders, errs
+}
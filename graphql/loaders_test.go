@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+)
+
+func TestAccountOrdersAreBatched(t *testing.T) {
+	stack := newTestStack(t)
+
+	for _, name := range []string{"alice", "bob", "carol"} {
+		var created struct {
+			CreateAccount struct{ ID string }
+		}
+		stack.MustPost(`mutation($name: String!) { createAccount(account: {name: $name}) { id } }`, &created, client.Var("name", name))
+	}
+
+	var res struct {
+		Accounts []struct {
+			Orders []struct{ ID string }
+		}
+	}
+	stack.MustPost(`{ accounts(pagination: {take: 10}) { orders { id } } }`, &res)
+
+	if len(res.Accounts) != 3 {
+		t.Fatalf("accounts = %+v, want 3 accounts", res.Accounts)
+	}
+	if n := stack.Calls("/order_service.OrderService/GetOrdersForAccounts"); n != 1 {
+		t.Errorf("GetOrdersForAccounts called %d times, want 1", n)
+	}
+	if n := stack.Calls("/order_service.OrderService/GetOrdersForAccount"); n != 0 {
+		t.Errorf("GetOrdersForAccount called %d times, want 0", n)
+	}
+}
+
+func TestRootLookupsAreBatched(t *testing.T) {
+	stack := newTestStack(t)
+
+	var a, b struct {
+		CreateProduct struct{ ID string }
+	}
+	stack.MustPost(`mutation { createProduct(product: {name: "Keyboard", description: "", price: 1}) { id } }`, &a)
+	stack.MustPost(`mutation { createProduct(product: {name: "Mouse", description: "", price: 1}) { id } }`, &b)
+
+	var res struct {
+		A []struct{ Name string }
+		B []struct{ Name string }
+	}
+	stack.MustPost(`query($a: String, $b: String) { a: products(id: $a) { name } b: products(id: $b) { name } }`, &res,
+		client.Var("a", a.CreateProduct.ID), client.Var("b", b.CreateProduct.ID))
+
+	if len(res.A) != 1 || res.A[0].Name != "Keyboard" || len(res.B) != 1 || res.B[0].Name != "Mouse" {
+		t.Errorf("products = %+v", res)
+	}
+	if n := stack.Calls("/catalog_service.CatalogService/GetProducts"); n != 1 {
+		t.Errorf("GetProducts called %d times, want 1", n)
+	}
+}
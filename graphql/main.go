@@ -1,34 +1,194 @@
 package main
 
 import (
+	"context"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/kelseyhightower/envconfig"
+	"github.com/valkyraycho/go-microservices/catalog"
+	"github.com/valkyraycho/go-microservices/graceful"
+	"github.com/valkyraycho/go-microservices/health"
+	"github.com/valkyraycho/go-microservices/ratelimit"
+	"github.com/valkyraycho/go-microservices/resilience"
+	"github.com/valkyraycho/go-microservices/telemetry"
+	"github.com/valkyraycho/go-microservices/tlsconfig"
+	"github.com/vektah/gqlparser/v2/ast"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 type AppConfig struct {
 	AccountURL string `envconfig:"ACCOUNT_SERVICE_URL"`
 	CatalogURL string `envconfig:"CATALOG_SERVICE_URL"`
 	OrderURL   string `envconfig:"ORDER_SERVICE_URL"`
+
+	MaxQueryDepth      int `envconfig:"MAX_QUERY_DEPTH" default:"10"`
+	MaxQueryComplexity int `envconfig:"MAX_QUERY_COMPLEXITY" default:"10000"`
+	// Introspection lets clients query the schema, which the playground and
+	// code generators need. It is off by default so that production gateways
+	// do not hand out a map of the graph.
+	Introspection bool `envconfig:"INTROSPECTION"`
+
+	// QueryCacheSize bounds both the parsed query cache and the automatic
+	// persisted query cache.
+	QueryCacheSize int `envconfig:"QUERY_CACHE_SIZE" default:"1000"`
+	// PersistedQueriesPath is a manifest of the only queries the gateway
+	// accepts. When it is empty any query is accepted.
+	PersistedQueriesPath string `envconfig:"PERSISTED_QUERIES_PATH"`
+
+	// AdminToken is the bearer token that unlocks admin-only fields. When it
+	// is empty those fields are unavailable.
+	AdminToken string `envconfig:"ADMIN_TOKEN"`
+
+	// RateLimit bounds the requests of each client, and OperationLimits the
+	// uses of the root fields they name on top of it.
+	RateLimit       ratelimit.Limit            `envconfig:"RATE_LIMIT" default:"50/1s"`
+	OperationLimits map[string]ratelimit.Limit `envconfig:"OPERATION_LIMITS" default:"createOrder:10/1m,searchProducts:10/1s"`
+
+	MetricsPort int `envconfig:"METRICS_PORT" default:"9090"`
+	// ShutdownTimeout bounds how long the gateway waits for requests in
+	// flight once it is asked to stop.
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"10s"`
+
+	// HTTPTLS serves the gateway over HTTPS, and GRPCTLS secures its
+	// connections to the services with mutual TLS.
+	HTTPTLS tlsconfig.Config `envconfig:"HTTP_TLS"`
+	GRPCTLS tlsconfig.Config `envconfig:"GRPC_TLS"`
+
+	// Client sets the retries, deadlines, hedging and circuit breakers of the
+	// calls to the services.
+	Client resilience.Config `envconfig:"CLIENT"`
+
+	// ProductCache caches the products looked up in the catalog by id.
+	ProductCache catalog.CacheConfig `envconfig:"PRODUCT_CACHE"`
 }
 
 func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
 	var cfg AppConfig
-	err := envconfig.Process("", &cfg)
+	if err := envconfig.Process("", &cfg); err != nil {
+		return err
+	}
+
+	shutdown, err := telemetry.Setup(context.Background(), "graphql")
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	// Spans still buffered are sent once everything else has shut down.
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			slog.Error("flushing spans", "err", err)
+		}
+	}()
 
-	s, err := NewGraphQLServer(cfg.AccountURL, cfg.CatalogURL, cfg.OrderURL)
+	dialCreds, err := tlsconfig.DialOption(cfg.GRPCTLS)
+	if err != nil {
+		return err
+	}
 
+	s, err := NewGraphQLServer(cfg.AccountURL, cfg.CatalogURL, cfg.OrderURL, cfg.Client, dialCreds)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	defer s.Close()
+
+	cache, err := catalog.NewProductCache(cfg.ProductCache)
+	if err != nil {
+		return err
+	}
+	if cache != nil {
+		defer cache.Close()
+		s.catalogClient.UseCache(cache)
+	}
+
+	h, err := newHandler(s, cfg)
+	if err != nil {
+		return err
 	}
-	http.Handle("/graphql", handler.New(s.ToExecutableSchema()))
+	http.Handle("/graphql", h)
 	http.Handle("/playground", playground.Handler("valkyraycho", "/graphql"))
 
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	// /healthz only reports that the gateway is up, while /readyz also asks
+	// the services, so a gateway is not restarted because a service is down.
+	// They are served next to the metrics rather than to clients, since every
+	// readiness check calls each service and its stores.
+	go func() {
+		log.Fatal(telemetry.ListenMetrics(cfg.MetricsPort, map[string]http.Handler{
+			"/healthz": health.Handler(nil),
+			"/readyz":  health.Handler(s.checks()),
+		}))
+	}()
+
+	server := &http.Server{}
+	if cfg.HTTPTLS.Enabled() {
+		certs, err := tlsconfig.Load(cfg.HTTPTLS)
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = certs.ServerConfig()
+	}
+
+	lis, err := net.Listen("tcp", ":8080")
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := graceful.SignalContext()
+	defer stop()
+
+	slog.Info("listening", "port", 8080)
+	if err := graceful.ServeHTTP(ctx, server, lis, cfg.ShutdownTimeout); err != nil {
+		return err
+	}
+	slog.Info("shut down")
+	return nil
+}
+
+func newHandler(s *Server, cfg AppConfig) (http.Handler, error) {
+	h := handler.New(s.ToExecutableSchema())
+	h.AddTransport(transport.Websocket{KeepAlivePingInterval: 10 * time.Second})
+	h.AddTransport(transport.Options{})
+	h.AddTransport(transport.GET{})
+	h.AddTransport(transport.POST{})
+	h.SetErrorPresenter(presentError)
+	h.SetQueryCache(lru.New[*ast.QueryDocument](cfg.QueryCacheSize))
+	if cfg.Introspection {
+		h.Use(extension.Introspection{})
+	}
+
+	if cfg.PersistedQueriesPath != "" {
+		queries, err := loadAllowList(cfg.PersistedQueriesPath)
+		if err != nil {
+			return nil, err
+		}
+		h.Use(extension.AutomaticPersistedQuery{Cache: queries})
+		h.Use(queries)
+	} else {
+		h.Use(extension.AutomaticPersistedQuery{Cache: lru.New[string](cfg.QueryCacheSize)})
+	}
+
+	h.Use(&queryLimits{MaxDepth: cfg.MaxQueryDepth, MaxComplexity: cfg.MaxQueryComplexity})
+	h.Use(newOperationLimits(cfg.OperationLimits))
+	h.AroundResponses(withLoaders(s))
+	h.Use(newTracing())
+	h.Use(operationMetrics{})
+
+	// The HTTP span continues the trace of the caller, if it sent one. Requests
+	// are logged inside it, so their records carry its trace id.
+	return otelhttp.NewHandler(telemetry.LogRequests(withAdmin(cfg.AdminToken, withRateLimit(cfg.RateLimit, h))), "graphql"), nil
 }
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Operations are labeled by the root field they select, which the schema
+// bounds, rather than by their names, which clients choose freely.
+var (
+	operationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "graphql_operation_duration_seconds",
+		Help:    "Time taken to execute GraphQL operations.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"field", "type"})
+	operationErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "graphql_operation_errors_total",
+		Help: "Number of GraphQL operations that returned errors.",
+	}, []string{"field", "type"})
+)
+
+// operationMetrics times every operation and counts the ones that fail. Each
+// event of a subscription counts as one operation.
+type operationMetrics struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+} = operationMetrics{}
+
+func (operationMetrics) ExtensionName() string {
+	return "OperationMetrics"
+}
+
+func (operationMetrics) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (operationMetrics) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+	opCtx := graphql.GetOperationContext(ctx)
+	if opCtx.Operation == nil {
+		return next(ctx)
+	}
+
+	field := operationField(opCtx.Operation.SelectionSet)
+	opType := string(opCtx.Operation.Operation)
+
+	start := time.Now()
+	res := next(ctx)
+	operationDuration.WithLabelValues(field, opType).Observe(time.Since(start).Seconds())
+	if res != nil && len(res.Errors) > 0 {
+		operationErrors.WithLabelValues(field, opType).Inc()
+	}
+	return res
+}
+
+// operationField names the root field an operation selects, or returns
+// "multiple" when it selects several. Labeling each combination of fields
+// would multiply the series.
+func operationField(set ast.SelectionSet) string {
+	var field string
+	for _, f := range rootFields(set) {
+		if field != "" && f != field {
+			return "multiple"
+		}
+		field = f
+	}
+	return field
+}
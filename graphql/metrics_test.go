@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/valkyraycho/go-microservices/telemetry"
+)
+
+func TestMetrics(t *testing.T) {
+	stack := newTestStack(t)
+
+	failed := testutil.ToFloat64(operationErrors.WithLabelValues("multiple", "query"))
+	createTestOrder(t, stack)
+	stack.MustPost(`query MadeUpName { accounts { id } }`, &struct{ Accounts []struct{ ID string } }{})
+	postErrors(t, stack, `query Missing { node(id: "Order:missing") { ... on Order { account { id } } } accounts(id: "missing") { id } }`)
+
+	if got := testutil.ToFloat64(operationErrors.WithLabelValues("multiple", "query")); got != failed+1 {
+		t.Errorf("errors of operations on multiple fields = %v, want %v", got, failed+1)
+	}
+
+	rec := httptest.NewRecorder()
+	telemetry.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	if strings.Contains(body, "MadeUpName") {
+		t.Error("metrics are labeled by the name of an operation")
+	}
+
+	for _, series := range []string{
+		`graphql_operation_duration_seconds_count{field="createOrder",type="mutation"}`,
+		`grpc_server_handled_total{grpc_code="OK",grpc_method="PostOrder",grpc_service="order_service.OrderService",grpc_type="unary"}`,
+		`grpc_client_handled_total{grpc_code="OK",grpc_method="GetAccount",grpc_service="account_service.AccountService",grpc_type="unary"}`,
+		`grpc_server_handling_seconds_count{grpc_method="GetProducts",grpc_service="catalog_service.CatalogService",grpc_type="unary"}`,
+		`orders_placed_total`,
+		`order_value_count`,
+		`products_created_total`,
+	} {
+		if !strings.Contains(body, series) {
+			t.Errorf("metrics have no series %s", series)
+		}
+	}
+}
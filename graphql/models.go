@@ -1,7 +1,90 @@
 package main
 
+import (
+	"time"
+
+	"github.com/valkyraycho/go-microservices/catalog"
+	orderServ "github.com/valkyraycho/go-microservices/order"
+)
+
+// The models keep the ids the services use. Their id fields are resolved
+// with GlobalID, which adds the type prefix clients see.
+
 type Account struct {
 	ID     string  `json:"id"`
 	Name   string  `json:"name"`
 	Orders []Order `json:"orders"`
 }
+
+func (Account) IsNode()            {}
+func (a Account) GetID() string    { return a.GlobalID() }
+func (a Account) GlobalID() string { return globalID(nodeAccount, a.ID) }
+
+type Product struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Price       float64  `json:"price"`
+	Archived    bool     `json:"archived"`
+	Orders      []*Order `json:"orders"`
+}
+
+func (Product) IsNode()            {}
+func (p Product) GetID() string    { return p.GlobalID() }
+func (p Product) GlobalID() string { return globalID(nodeProduct, p.ID) }
+
+type Order struct {
+	ID         string            `json:"id"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	TotalPrice float64           `json:"totalPrice"`
+	Status     OrderStatus       `json:"status"`
+	Products   []*OrderedProduct `json:"products"`
+	AccountID  string            `json:"-"`
+}
+
+func (Order) IsNode()            {}
+func (o Order) GetID() string    { return o.GlobalID() }
+func (o Order) GlobalID() string { return globalID(nodeOrder, o.ID) }
+
+type OrderedProduct struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Price       float64  `json:"price"`
+	Quantity    int      `json:"quantity"`
+	Product     *Product `json:"product,omitempty"`
+}
+
+// GlobalID is the global id of the ordered product.
+func (p OrderedProduct) GlobalID() string { return globalID(nodeProduct, p.ID) }
+
+func newProduct(p catalog.Product) *Product {
+	return &Product{
+		ID:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       p.Price,
+		Archived:    p.Archived,
+	}
+}
+
+func newOrder(o orderServ.Order) *Order {
+	products := []*OrderedProduct{}
+	for _, p := range o.Products {
+		products = append(products, &OrderedProduct{
+			ID:          p.ID,
+			Name:        p.Name,
+			Description: p.Description,
+			Price:       p.Price,
+			Quantity:    int(p.Quantity),
+		})
+	}
+	return &Order{
+		ID:         o.ID,
+		CreatedAt:  o.CreatedAt,
+		TotalPrice: o.TotalPrice,
+		Status:     OrderStatus(o.Status),
+		Products:   products,
+		AccountID:  o.AccountID,
+	}
+}
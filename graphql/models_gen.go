@@ -3,21 +3,28 @@
 package main
 
 import (
-	"time"
+	"fmt"
+	"io"
+	"strconv"
 )
 
+// An object that can be refetched with the node query. Its id is global: it
+// starts with the type name, as in "Account:<id>".
+type Node interface {
+	IsNode()
+	GetID() string
+}
+
 type AccountInput struct {
 	Name string `json:"name"`
 }
 
-type Mutation struct {
+type HighlightSpan struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
 }
 
-type Order struct {
-	ID         string            `json:"id"`
-	CreatedAt  time.Time         `json:"createdAt"`
-	TotalPrice float64           `json:"totalPrice"`
-	Products   []*OrderedProduct `json:"products"`
+type Mutation struct {
 }
 
 type OrderInput struct {
@@ -30,31 +37,84 @@ type OrderProductInput struct {
 	Quantity int    `json:"quantity"`
 }
 
-type OrderedProduct struct {
-	ID          string  `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Quantity    int     `json:"quantity"`
-}
-
 type PaginationInput struct {
 	Skip *int `json:"skip,omitempty"`
 	Take *int `json:"take,omitempty"`
 }
 
-type Product struct {
-	ID          string  `json:"id"`
+type ProductInput struct {
 	Name        string  `json:"name"`
 	Description string  `json:"description"`
 	Price       float64 `json:"price"`
 }
 
-type ProductInput struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
+type ProductSearchResult struct {
+	Product    *Product           `json:"product"`
+	Score      float64            `json:"score"`
+	Highlights []*SearchHighlight `json:"highlights"`
+}
+
+type ProductSuggestion struct {
+	// The global id of the product.
+	ID         string           `json:"id"`
+	Name       string           `json:"name"`
+	Highlights []*HighlightSpan `json:"highlights"`
 }
 
 type Query struct {
 }
+
+type SearchHighlight struct {
+	Field     string   `json:"field"`
+	Fragments []string `json:"fragments"`
+}
+
+type Subscription struct {
+}
+
+type OrderStatus string
+
+const (
+	OrderStatusPending   OrderStatus = "PENDING"
+	OrderStatusPaid      OrderStatus = "PAID"
+	OrderStatusShipped   OrderStatus = "SHIPPED"
+	OrderStatusDelivered OrderStatus = "DELIVERED"
+	OrderStatusCancelled OrderStatus = "CANCELLED"
+)
+
+var AllOrderStatus = []OrderStatus{
+	OrderStatusPending,
+	OrderStatusPaid,
+	OrderStatusShipped,
+	OrderStatusDelivered,
+	OrderStatusCancelled,
+}
+
+func (e OrderStatus) IsValid() bool {
+	switch e {
+	case OrderStatusPending, OrderStatusPaid, OrderStatusShipped, OrderStatusDelivered, OrderStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func (e OrderStatus) String() string {
+	return string(e)
+}
+
+func (e *OrderStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OrderStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OrderStatus", str)
+	}
+	return nil
+}
+
+func (e OrderStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -2,18 +2,18 @@ package main
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"time"
 
 	orderServ "github.com/valkyraycho/go-microservices/order"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
 )
 
 type mutationResolver struct {
 	server *Server
 }
 
-var ErrInvalidParameter = errors.New("invalid parameter")
-
 func (r *mutationResolver) CreateAccount(ctx context.Context, account AccountInput) (*Account, error) {
 	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
@@ -37,12 +37,24 @@ func (r *mutationResolver) CreateProduct(ctx context.Context, product ProductInp
 		return nil, err
 	}
 
-	return &Product{
-		ID:          p.ID,
-		Name:        p.Name,
-		Description: p.Description,
-		Price:       p.Price,
-	}, nil
+	return newProduct(*p), nil
+}
+
+func (r *mutationResolver) ArchiveProduct(ctx context.Context, id string) (*Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	productID, err := localID(nodeProduct, id)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := r.server.catalogClient.ArchiveProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newProduct(*p), nil
 }
 
 func (r *mutationResolver) CreateOrder(ctx context.Context, order OrderInput) (*Order, error) {
@@ -50,26 +62,79 @@ func (r *mutationResolver) CreateOrder(ctx context.Context, order OrderInput) (*
 	defer cancel()
 
 	orderedProducts := []orderServ.OrderedProduct{}
+	violations := []*errdetails.BadRequest_FieldViolation{}
+
+	accountID, err := localID(nodeAccount, order.AccountID)
+	if err != nil {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       "accountId",
+			Description: status.Convert(err).Message(),
+		})
+	}
 
-	for _, p := range order.Products {
-		if p.Quantity <= 0 {
-			return nil, ErrInvalidParameter
+	for i, p := range order.Products {
+		productID, err := localID(nodeProduct, p.ID)
+		if err != nil {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       fmt.Sprintf("products[%d].id", i),
+				Description: status.Convert(err).Message(),
+			})
+			continue
+		}
+
+		// Quantities are unsigned 32-bit integers past the gateway, so
+		// negative ones and ones that would wrap around have to be caught
+		// here.
+		if p.Quantity < 0 {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       fmt.Sprintf("products[%d].quantity", i),
+				Description: "must be positive",
+			})
+			continue
+		}
+		if p.Quantity > orderServ.MaxQuantity {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       fmt.Sprintf("products[%d].quantity", i),
+				Description: fmt.Sprintf("must be at most %d", orderServ.MaxQuantity),
+			})
+			continue
 		}
 
 		orderedProducts = append(orderedProducts, orderServ.OrderedProduct{
-			ID:       p.ID,
+			ID:       productID,
 			Quantity: uint32(p.Quantity),
 		})
 	}
 
-	o, err := r.server.orderClient.PostOrder(ctx, order.AccountID, orderedProducts)
+	if len(violations) > 0 {
+		return nil, invalidFields(violations)
+	}
+
+	o, err := r.server.orderClient.PostOrder(ctx, accountID, orderedProducts)
+	if err != nil {
+		ids := map[string]sentID{}
+		for i, p := range orderedProducts {
+			ids[fmt.Sprintf("products[%d].id", i)] = sentID{local: p.ID, sent: order.Products[i].ID}
+		}
+		return nil, withSentIDs(err, ids)
+	}
+
+	return newOrder(*o), nil
+}
+
+func (r *mutationResolver) UpdateOrderStatus(ctx context.Context, orderID string, status OrderStatus) (*Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	id, err := localID(nodeOrder, orderID)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Order{
-		ID:         o.ID,
-		CreatedAt:  o.CreatedAt,
-		TotalPrice: o.TotalPrice,
-	}, nil
+	o, err := r.server.orderClient.UpdateOrderStatus(ctx, id, string(status))
+	if err != nil {
+		return nil, err
+	}
+
+	return newOrder(*o), nil
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+)
+
+func TestNodeRefetchesEachType(t *testing.T) {
+	stack := newTestStack(t)
+	accountID, orderID := createTestOrder(t, stack)
+
+	var order struct {
+		Node struct {
+			Typename string `json:"__typename"`
+			ID       string
+			Account  struct{ ID string }
+			Products []struct{ ID string }
+		}
+	}
+	stack.MustPost(`query($id: ID!) { node(id: $id) { __typename id ... on Order { account { id } products { id } } } }`, &order, client.Var("id", orderID))
+
+	if order.Node.Typename != "Order" || order.Node.ID != orderID || order.Node.Account.ID != accountID || len(order.Node.Products) != 1 {
+		t.Fatalf("node(order) = %+v", order.Node)
+	}
+	if !strings.HasPrefix(orderID, "Order:") || !strings.HasPrefix(accountID, "Account:") {
+		t.Errorf("ids %s and %s are missing their type prefix", orderID, accountID)
+	}
+
+	productID := order.Node.Products[0].ID
+	for _, tt := range []struct{ id, typename string }{
+		{accountID, "Account"},
+		{productID, "Product"},
+	} {
+		var res struct {
+			Node struct {
+				Typename string `json:"__typename"`
+				ID       string
+			}
+		}
+		stack.MustPost(`query($id: ID!) { node(id: $id) { __typename id } }`, &res, client.Var("id", tt.id))
+		if res.Node.Typename != tt.typename || res.Node.ID != tt.id {
+			t.Errorf("node(%s) = %+v, want %s", tt.id, res.Node, tt.typename)
+		}
+	}
+}
+
+func TestNodesKeepsOrderAndBatches(t *testing.T) {
+	stack := newTestStack(t)
+	accountID, orderID := createTestOrder(t, stack)
+
+	var res struct {
+		Nodes []*struct{ ID string }
+	}
+	ids := []string{orderID, "Order:missing", accountID, "Account:missing"}
+	stack.MustPost(`query($ids: [ID!]!) { nodes(ids: $ids) { id } }`, &res, client.Var("ids", ids))
+
+	if len(res.Nodes) != 4 || res.Nodes[0] == nil || res.Nodes[0].ID != orderID || res.Nodes[1] != nil ||
+		res.Nodes[2] == nil || res.Nodes[2].ID != accountID || res.Nodes[3] != nil {
+		t.Fatalf("nodes = %+v, want [%s null %s null]", res.Nodes, orderID, accountID)
+	}
+	if n := stack.Calls("/order_service.OrderService/GetOrdersByIDs"); n != 1 {
+		t.Errorf("GetOrdersByIDs called %d times, want 1", n)
+	}
+	if n := stack.Calls("/account_service.AccountService/GetAccountsByIDs"); n != 1 {
+		t.Errorf("GetAccountsByIDs called %d times, want 1", n)
+	}
+}
+
+func TestNodeRejectsInvalidIDs(t *testing.T) {
+	stack := newTestStack(t)
+
+	for _, id := range []string{"plain", "Order:", "Customer:1"} {
+		errs := postErrors(t, stack, `query($id: ID!) { node(id: $id) { id } }`, client.Var("id", id))
+		if len(errs) != 1 || errs[0].Extensions["code"] != codeInvalidArgument {
+			t.Errorf("node(%q) errors = %+v, want %s", id, errs, codeInvalidArgument)
+		}
+	}
+}
+
+func TestArgumentsAcceptGlobalAndPlainIDs(t *testing.T) {
+	stack := newTestStack(t)
+	accountID, _ := createTestOrder(t, stack)
+
+	for _, id := range []string{accountID, strings.TrimPrefix(accountID, "Account:")} {
+		var res struct {
+			Accounts []struct{ ID string }
+		}
+		stack.MustPost(`query($id: String) { accounts(id: $id) { id } }`, &res, client.Var("id", id))
+		if len(res.Accounts) != 1 || res.Accounts[0].ID != accountID {
+			t.Errorf("accounts(id: %s) = %+v, want %s", id, res.Accounts, accountID)
+		}
+	}
+
+	errs := postErrors(t, stack, `query($id: String) { accounts(id: $id) { id } }`, client.Var("id", "Product:"+strings.TrimPrefix(accountID, "Account:")))
+	if len(errs) != 1 || errs[0].Extensions["code"] != codeInvalidArgument {
+		t.Errorf("accounts(id: product id) errors = %+v, want %s", errs, codeInvalidArgument)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+)
+
+type orderResolver struct {
+	server *Server
+}
+
+func (r *orderResolver) Account(ctx context.Context, obj *Order) (*Account, error) {
+	a, err := loadersFor(ctx).accountsByID.Load(ctx, obj.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	return &Account{
+		ID:   a.ID,
+		Name: a.Name,
+	}, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// allowList holds the only queries the gateway runs in allow-list mode, keyed
+// by the hex SHA-256 hash of their text. It doubles as the persisted query
+// cache, so clients can send just the hash of a registered query, but it never
+// learns new queries.
+type allowList map[string]string
+
+var _ interface {
+	graphql.Cache[string]
+	graphql.HandlerExtension
+	graphql.OperationParameterMutator
+} = allowList{}
+
+// loadAllowList reads a manifest that maps query hashes to query text:
+//
+//	{"<sha256 of the query>": "query { ... }"}
+func loadAllowList(path string) (allowList, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	queries := allowList{}
+	if err := json.Unmarshal(b, &queries); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for hash, query := range queries {
+		if queryHash(query) != hash {
+			return nil, fmt.Errorf("%s: hash %s does not match its query", path, hash)
+		}
+	}
+	return queries, nil
+}
+
+func queryHash(query string) string {
+	b := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(b[:])
+}
+
+func (l allowList) Get(ctx context.Context, hash string) (string, bool) {
+	query, ok := l[hash]
+	return query, ok
+}
+
+// Add ignores queries registered by clients; only the manifest is trusted.
+func (l allowList) Add(ctx context.Context, hash string, query string) {}
+
+func (l allowList) ExtensionName() string {
+	return "AllowList"
+}
+
+func (l allowList) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// MutateOperationParameters runs after persisted queries are resolved, so it
+// sees the query text whether the client sent it or only its hash.
+func (l allowList) MutateOperationParameters(ctx context.Context, rawParams *graphql.RawParams) *gqlerror.Error {
+	if _, ok := l[queryHash(rawParams.Query)]; ok {
+		return nil
+	}
+	return &gqlerror.Error{
+		Message:    "query is not in the allow list",
+		Extensions: map[string]any{"code": "PERSISTED_QUERY_NOT_ALLOWED"},
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+)
+
+func persistedQuery(query string) client.Option {
+	return client.Extensions(map[string]any{
+		"persistedQuery": map[string]any{"version": 1, "sha256Hash": queryHash(query)},
+	})
+}
+
+func TestAutomaticPersistedQueries(t *testing.T) {
+	stack := newTestStack(t)
+	query := `{ products { id } }`
+
+	errs := postErrors(t, stack, "", persistedQuery(query))
+	if len(errs) != 1 || errs[0].Extensions["code"] != "PERSISTED_QUERY_NOT_FOUND" {
+		t.Fatalf("unknown hash: errors = %+v, want PERSISTED_QUERY_NOT_FOUND", errs)
+	}
+
+	if errs := postErrors(t, stack, query, persistedQuery(query)); len(errs) != 0 {
+		t.Fatalf("registering query: errors = %+v", errs)
+	}
+
+	if errs := postErrors(t, stack, "", persistedQuery(query)); len(errs) != 0 {
+		t.Errorf("known hash: errors = %+v", errs)
+	}
+}
+
+func writeManifest(t *testing.T, queries map[string]string) string {
+	t.Helper()
+
+	b, err := json.Marshal(queries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "queries.json")
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAllowList(t *testing.T) {
+	allowed := `{ products { id } }`
+	other := `{ products { name } }`
+	path := writeManifest(t, map[string]string{queryHash(allowed): allowed})
+
+	stack := newTestStack(t, func(cfg *AppConfig) { cfg.PersistedQueriesPath = path })
+
+	if errs := postErrors(t, stack, allowed); len(errs) != 0 {
+		t.Errorf("allowed query: errors = %+v", errs)
+	}
+	if errs := postErrors(t, stack, "", persistedQuery(allowed)); len(errs) != 0 {
+		t.Errorf("allowed hash: errors = %+v", errs)
+	}
+
+	for name, errs := range map[string][]responseError{
+		"ad-hoc query":   postErrors(t, stack, other),
+		"registration":   postErrors(t, stack, other, persistedQuery(other)),
+		"registered use": postErrors(t, stack, "", persistedQuery(other)),
+	} {
+		if len(errs) != 1 {
+			t.Errorf("%s: errors = %+v, want one", name, errs)
+			continue
+		}
+		if code := errs[0].Extensions["code"]; code != "PERSISTED_QUERY_NOT_ALLOWED" && code != "PERSISTED_QUERY_NOT_FOUND" {
+			t.Errorf("%s: code = %v", name, code)
+		}
+	}
+}
+
+func TestLoadAllowListRejectsWrongHash(t *testing.T) {
+	path := writeManifest(t, map[string]string{queryHash("{ a }"): "{ b }"})
+
+	if _, err := loadAllowList(path); err == nil {
+		t.Error("loadAllowList succeeded with a hash that does not match its query")
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type productResolver struct {
+	server *Server
+}
+
+func (r *productResolver) Orders(ctx context.Context, obj *Product, pagination *PaginationInput) ([]*Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	skip, take := uint64(0), uint64(0)
+	if pagination != nil {
+		skip, take = pagination.bounds()
+	}
+
+	orderList, err := r.server.orderClient.GetOrdersForProduct(ctx, obj.ID, skip, take)
+	if err != nil {
+		return nil, err
+	}
+
+	orders := []*Order{}
+	for _, o := range orderList {
+		orders = append(orders, newOrder(o))
+	}
+	return orders, nil
+}
+
+type orderedProductResolver struct {
+	server *Server
+}
+
+// Product returns the current catalog entry of an ordered product, or null if
+// it has been removed from the catalog.
+func (r *orderedProductResolver) Product(ctx context.Context, obj *OrderedProduct) (*Product, error) {
+	p, err := loadersFor(ctx).productsByID.Load(ctx, obj.ID)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newProduct(*p), nil
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"log"
 	"time"
 )
 
@@ -27,9 +26,12 @@ func (r *queryResolver) Accounts(ctx context.Context, pagination *PaginationInpu
 	defer cancel()
 
 	if id != nil {
-		a, err := r.server.accountClient.GetAccount(ctx, *id)
+		accountID, err := localID(nodeAccount, *id)
+		if err != nil {
+			return nil, err
+		}
+		a, err := loadersFor(ctx).accountsByID.Load(ctx, accountID)
 		if err != nil {
-			log.Println(err)
 			return nil, err
 		}
 		return []*Account{
@@ -64,18 +66,15 @@ func (r *queryResolver) Products(ctx context.Context, pagination *PaginationInpu
 	defer cancel()
 
 	if id != nil {
-		p, err := r.server.catalogClient.GetProduct(ctx, *id)
+		productID, err := localID(nodeProduct, *id)
 		if err != nil {
 			return nil, err
 		}
-		return []*Product{
-			{
-				ID:          p.ID,
-				Name:        p.Name,
-				Description: p.Description,
-				Price:       p.Price,
-			},
-		}, nil
+		p, err := loadersFor(ctx).productsByID.Load(ctx, productID)
+		if err != nil {
+			return nil, err
+		}
+		return []*Product{newProduct(*p)}, nil
 	}
 
 	skip, take := uint64(0), uint64(0)
@@ -95,13 +94,68 @@ func (r *queryResolver) Products(ctx context.Context, pagination *PaginationInpu
 
 	products := []*Product{}
 	for _, p := range productList {
-		products = append(products, &Product{
-			ID:          p.ID,
-			Name:        p.Name,
-			Description: p.Description,
-			Price:       p.Price,
-		})
+		products = append(products, newProduct(p))
 	}
 
 	return products, nil
 }
+
+func (r *queryResolver) SearchProducts(ctx context.Context, query string, pagination *PaginationInput) ([]*ProductSearchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	skip, take := uint64(0), uint64(0)
+	if pagination != nil {
+		skip, take = pagination.bounds()
+	}
+
+	resultList, err := r.server.catalogClient.SearchProducts(ctx, query, skip, take)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*ProductSearchResult{}
+	for _, res := range resultList {
+		highlights := []*SearchHighlight{}
+		for _, h := range res.Highlights {
+			highlights = append(highlights, &SearchHighlight{Field: h.Field, Fragments: h.Fragments})
+		}
+		results = append(results, &ProductSearchResult{
+			Product:    newProduct(res.Product),
+			Score:      res.Score,
+			Highlights: highlights,
+		})
+	}
+
+	return results, nil
+}
+
+func (r *queryResolver) ProductSuggestions(ctx context.Context, prefix string, limit *int) ([]*ProductSuggestion, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	take := uint64(0)
+	if limit != nil && *limit > 0 {
+		take = uint64(*limit)
+	}
+
+	suggestionList, err := r.server.catalogClient.SuggestProducts(ctx, prefix, take)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := []*ProductSuggestion{}
+	for _, s := range suggestionList {
+		highlights := []*HighlightSpan{}
+		for _, h := range s.Highlights {
+			highlights = append(highlights, &HighlightSpan{Start: h.Start, End: h.End})
+		}
+		suggestions = append(suggestions, &ProductSuggestion{
+			ID:         globalID(nodeProduct, s.ID),
+			Name:       s.Name,
+			Highlights: highlights,
+		})
+	}
+
+	return suggestions, nil
+}
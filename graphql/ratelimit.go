@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/valkyraycho/go-microservices/ratelimit"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const codeRateLimited = "RATE_LIMITED"
+
+type rateLimitKey struct{}
+
+// rateLimited is shared by withRateLimit and operationLimits, so that a
+// request turned down by the limit of an operation is answered with 429 too.
+type rateLimited struct {
+	client     string
+	retryAfter time.Duration
+}
+
+// withRateLimit answers 429 to clients that send more requests than limit
+// allows. Clients are told apart by IP address, except the admin, the only
+// client the gateway authenticates, which has a bucket of its own. The client
+// is named in the calls to the services, which limit each client of the
+// gateway on its own.
+func withRateLimit(limit ratelimit.Limit, next http.Handler) http.Handler {
+	limiter := ratelimit.NewLimiter(limit)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := clientKey(r)
+		if ok, retryAfter := limiter.Allow(client); !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", ratelimit.RetryAfter(retryAfter))
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(graphql.Response{
+				Errors: gqlerror.List{rateLimitError("too many requests", retryAfter)},
+			})
+			return
+		}
+
+		limited := &rateLimited{client: client}
+		ctx := context.WithValue(ratelimit.WithClient(r.Context(), client), rateLimitKey{}, limited)
+		next.ServeHTTP(&rateLimitedWriter{ResponseWriter: w, limited: limited}, r.WithContext(ctx))
+	})
+}
+
+func clientKey(r *http.Request) string {
+	if isAdmin(r.Context()) {
+		return "admin"
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+func rateLimitError(message string, retryAfter time.Duration) *gqlerror.Error {
+	return &gqlerror.Error{
+		Message: message,
+		Extensions: map[string]any{
+			"code":       codeRateLimited,
+			"retryAfter": ratelimit.RetryAfterSeconds(retryAfter),
+		},
+	}
+}
+
+// rateLimitedWriter changes the status of the response to 429, and adds a
+// Retry-After header, when an operation was turned down. It can still be
+// flushed and hijacked, which the subscription transports need.
+type rateLimitedWriter struct {
+	http.ResponseWriter
+	limited     *rateLimited
+	wroteHeader bool
+}
+
+func (w *rateLimitedWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if w.limited.retryAfter > 0 {
+			w.Header().Set("Retry-After", ratelimit.RetryAfter(w.limited.retryAfter))
+			code = http.StatusTooManyRequests
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *rateLimitedWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *rateLimitedWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *rateLimitedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+func (w *rateLimitedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// operationLimits limits how often each client may use the root fields it
+// has limits for, such as createOrder, on top of the limit of its requests.
+// Fields are limited rather than operation names, which clients choose
+// freely. Every use of a field counts, aliases included.
+type operationLimits struct {
+	limiters map[string]*ratelimit.Limiter
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = &operationLimits{}
+
+func newOperationLimits(limits map[string]ratelimit.Limit) *operationLimits {
+	l := &operationLimits{limiters: map[string]*ratelimit.Limiter{}}
+	for field, limit := range limits {
+		l.limiters[field] = ratelimit.NewLimiter(limit)
+	}
+	return l
+}
+
+func (l *operationLimits) ExtensionName() string {
+	return "OperationLimits"
+}
+
+func (l *operationLimits) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (l *operationLimits) MutateOperationContext(ctx context.Context, opCtx *graphql.OperationContext) *gqlerror.Error {
+	limited, ok := ctx.Value(rateLimitKey{}).(*rateLimited)
+	if !ok {
+		return nil
+	}
+	// Tokens are only taken when every field is allowed, so a refused
+	// operation does not use up the limits of the fields it was allowed.
+	var takes []ratelimit.Take
+	var fields []string
+	for _, field := range rootFields(opCtx.Operation.SelectionSet) {
+		if limiter, ok := l.limiters[field]; ok {
+			takes = append(takes, ratelimit.Take{Limiter: limiter, Key: limited.client})
+			fields = append(fields, field)
+		}
+	}
+	if ok, retryAfter, refused := ratelimit.AllowAll(takes...); !ok {
+		limited.retryAfter = retryAfter
+		return rateLimitError(fmt.Sprintf("too many uses of %s", fields[refused]), retryAfter)
+	}
+	return nil
+}
+
+// rootFields lists the names of the fields an operation selects at its root,
+// looking through fragments.
+func rootFields(set ast.SelectionSet) []string {
+	var fields []string
+	for _, selection := range set {
+		switch s := selection.(type) {
+		case *ast.Field:
+			fields = append(fields, s.Name)
+		case *ast.FragmentSpread:
+			fields = append(fields, rootFields(s.Definition.SelectionSet)...)
+		case *ast.InlineFragment:
+			fields = append(fields, rootFields(s.SelectionSet)...)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/valkyraycho/go-microservices/ratelimit"
+)
+
+type rateLimitResponse struct {
+	Errors []struct {
+		Message    string `json:"message"`
+		Extensions struct {
+			Code       string `json:"code"`
+			RetryAfter int    `json:"retryAfter"`
+		} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// post sends query to the gateway from addr, with token as bearer token if
+// it is not empty.
+func (s *testStack) post(t *testing.T, addr, token, query string) (*httptest.ResponseRecorder, rateLimitResponse) {
+	t.Helper()
+
+	body, _ := json.Marshal(map[string]string{"query": query})
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = addr
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	var res rateLimitResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+	return rec, res
+}
+
+func assertRateLimited(t *testing.T, rec *httptest.ResponseRecorder, res rateLimitResponse, message string) {
+	t.Helper()
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || retryAfter <= 0 {
+		t.Errorf("Retry-After = %q, want a number of seconds", rec.Header().Get("Retry-After"))
+	}
+	if len(res.Errors) != 1 {
+		t.Fatalf("errors = %+v, want one", res.Errors)
+	}
+	if e := res.Errors[0]; e.Message != message || e.Extensions.Code != codeRateLimited || e.Extensions.RetryAfter <= 0 {
+		t.Errorf("error = %+v, want %q with code %s and a retry delay", e, message, codeRateLimited)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	stack := newTestStack(t, func(cfg *AppConfig) {
+		cfg.RateLimit = ratelimit.Limit{Requests: 2, Period: time.Minute}
+		cfg.AdminToken = "secret"
+	})
+	const query = `{ accounts { id } }`
+
+	for range 2 {
+		if rec, res := stack.post(t, "192.0.2.1:1234", "", query); rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, errors = %+v; want 200", rec.Code, res.Errors)
+		}
+	}
+	rec, res := stack.post(t, "192.0.2.1:5678", "", query)
+	assertRateLimited(t, rec, res, "too many requests")
+
+	// Other clients, and the admin, have buckets of their own.
+	if rec, _ := stack.post(t, "192.0.2.2:1234", "", query); rec.Code != http.StatusOK {
+		t.Errorf("status for another client = %d, want 200", rec.Code)
+	}
+	if rec, _ := stack.post(t, "192.0.2.1:1234", "secret", query); rec.Code != http.StatusOK {
+		t.Errorf("status for the admin = %d, want 200", rec.Code)
+	}
+}
+
+func TestOperationLimits(t *testing.T) {
+	stack := newTestStack(t, func(cfg *AppConfig) {
+		cfg.RateLimit = ratelimit.Limit{}
+		cfg.OperationLimits = map[string]ratelimit.Limit{"createAccount": {Requests: 2, Period: time.Minute}}
+	})
+	const addr = "192.0.2.1:1234"
+
+	if rec, res := stack.post(t, addr, "", `mutation { createAccount(account: {name: "Ada"}) { id } }`); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, errors = %+v; want 200", rec.Code, res.Errors)
+	}
+	// Aliases count as uses of their field, so an operation cannot make up
+	// for fewer requests with more fields.
+	rec, res := stack.post(t, addr, "", `mutation {
+		a: createAccount(account: {name: "Grace"}) { id }
+		b: createAccount(account: {name: "Edsger"}) { id }
+	}`)
+	assertRateLimited(t, rec, res, "too many uses of createAccount")
+
+	// The refused operation took no tokens, so the one left can be used.
+	if rec, res := stack.post(t, addr, "", `mutation { createAccount(account: {name: "Barbara"}) { id } }`); rec.Code != http.StatusOK {
+		t.Errorf("status after a refused operation = %d, errors = %+v; want 200", rec.Code, res.Errors)
+	}
+
+	// Fields without a limit are not affected.
+	if rec, res := stack.post(t, addr, "", `{ accounts { id } }`); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, errors = %+v; want 200", rec.Code, res.Errors)
+	}
+}
+
+func TestRateLimitConfig(t *testing.T) {
+	t.Setenv("RATE_LIMIT", "20/1s")
+	t.Setenv("OPERATION_LIMITS", "createOrder:1/1m,searchProducts:5/1s")
+
+	var cfg AppConfig
+	if err := envconfig.Process("", &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if want := (ratelimit.Limit{Requests: 20, Period: time.Second}); cfg.RateLimit != want {
+		t.Errorf("RateLimit = %v, want %v", cfg.RateLimit, want)
+	}
+	want := map[string]ratelimit.Limit{
+		"createOrder":    {Requests: 1, Period: time.Minute},
+		"searchProducts": {Requests: 5, Period: time.Second},
+	}
+	if !maps.Equal(cfg.OperationLimits, want) {
+		t.Errorf("OperationLimits = %v, want %v", cfg.OperationLimits, want)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+)
+
+func TestOrderRelationships(t *testing.T) {
+	stack := newTestStack(t, withAdminToken)
+	accountID, orderID := createTestOrder(t, stack)
+
+	var res struct {
+		Accounts []struct {
+			Orders []struct {
+				Account  struct{ ID string }
+				Products []struct {
+					ID      string
+					Product struct {
+						ID     string
+						Orders []struct{ ID string }
+					}
+				}
+			}
+		}
+	}
+	stack.MustPost(`query($id: String) {
+		accounts(id: $id) {
+			orders {
+				account { id }
+				products { id product { id orders { id } } }
+			}
+		}
+	}`, &res, client.Var("id", accountID), asAdmin)
+
+	if len(res.Accounts) != 1 || len(res.Accounts[0].Orders) != 1 {
+		t.Fatalf("accounts(id) = %+v, want one account with one order", res.Accounts)
+	}
+	o := res.Accounts[0].Orders[0]
+	if o.Account.ID != accountID {
+		t.Errorf("order account = %s, want %s", o.Account.ID, accountID)
+	}
+	for _, p := range o.Products {
+		if p.Product.ID != p.ID {
+			t.Errorf("ordered product %s links to product %s", p.ID, p.Product.ID)
+		}
+		if len(p.Product.Orders) != 1 || p.Product.Orders[0].ID != orderID {
+			t.Errorf("product %s orders = %+v, want [%s]", p.ID, p.Product.Orders, orderID)
+		}
+	}
+}
+
+func TestProductOrdersRequiresAdmin(t *testing.T) {
+	stack := newTestStack(t, withAdminToken)
+
+	var created struct {
+		CreateProduct struct{ ID string }
+	}
+	stack.MustPost(`mutation { createProduct(product: {name: "Keyboard", description: "", price: 10}) { id } }`, &created)
+
+	query := `query($id: String) { products(id: $id) { orders { id } } }`
+	for name, options := range map[string][]client.Option{
+		"no token":    {client.Var("id", created.CreateProduct.ID)},
+		"wrong token": {client.Var("id", created.CreateProduct.ID), client.AddHeader("Authorization", "Bearer guess")},
+	} {
+		errs := postErrors(t, stack, query, options...)
+		if len(errs) != 1 || errs[0].Extensions["code"] != codeUnauthenticated {
+			t.Errorf("%s: errors = %+v, want UNAUTHENTICATED", name, errs)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+
+	orderServ "github.com/valkyraycho/go-microservices/order"
+)
+
+type subscriptionResolver struct {
+	server *Server
+}
+
+func (r *subscriptionResolver) OrderUpdated(ctx context.Context, orderID string) (<-chan *Order, error) {
+	id, err := localID(nodeOrder, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	watched, err := r.server.orderClient.WatchOrders(ctx, id, "")
+	if err != nil {
+		return nil, err
+	}
+	return forwardOrders(ctx, watched), nil
+}
+
+func (r *subscriptionResolver) OrdersForAccount(ctx context.Context, accountID string) (<-chan *Order, error) {
+	id, err := localID(nodeAccount, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	watched, err := r.server.orderClient.WatchOrders(ctx, "", id)
+	if err != nil {
+		return nil, err
+	}
+	return forwardOrders(ctx, watched), nil
+}
+
+// forwardOrders converts watched orders to their GraphQL form until the
+// subscription ends.
+func forwardOrders(ctx context.Context, watched <-chan orderServ.Order) <-chan *Order {
+	orders := make(chan *Order)
+
+	go func() {
+		defer close(orders)
+		for o := range watched {
+			select {
+			case orders <- newOrder(o):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return orders
+}
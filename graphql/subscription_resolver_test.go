@@ -0,0 +1,198 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/client"
+)
+
+// createTestOrder creates an account with a one-product order and returns the
+// account and order ids.
+func createTestOrder(t *testing.T, stack *testStack) (string, string) {
+	t.Helper()
+
+	var acc struct {
+		CreateAccount struct{ ID string }
+	}
+	stack.MustPost(`mutation { createAccount(account: {name: "alice"}) { id } }`, &acc)
+
+	var product struct {
+		CreateProduct struct{ ID string }
+	}
+	stack.MustPost(`mutation { createProduct(product: {name: "Keyboard", description: "", price: 10}) { id } }`, &product)
+
+	var created struct {
+		CreateOrder struct{ ID string }
+	}
+	stack.MustPost(`mutation($account: String!, $product: String!) { createOrder(order: {accountId: $account, products: [{id: $product, quantity: 1}]}) { id } }`, &created,
+		client.Var("account", acc.CreateAccount.ID), client.Var("product", product.CreateProduct.ID))
+
+	return acc.CreateAccount.ID, created.CreateOrder.ID
+}
+
+// nextOrder waits for the next event of sub while repeatedly marking orderID
+// paid, since the subscription may not be in place yet when the first update
+// is made. The stack must have been made with withAdminToken.
+func nextOrder(t *testing.T, stack *testStack, sub *client.Subscription, orderID string) (string, string) {
+	t.Helper()
+
+	var res map[string]struct{ ID, Status string }
+	nextEvent(t, stack, sub, orderID, &res)
+	for _, o := range res {
+		return o.ID, o.Status
+	}
+	t.Fatal("subscription event has no order")
+	return "", ""
+}
+
+// nextEvent is nextOrder decoding the event into res.
+func nextEvent(t *testing.T, stack *testStack, sub *client.Subscription, orderID string, res any) {
+	t.Helper()
+
+	received := make(chan error, 1)
+	go func() { received <- sub.Next(res) }()
+
+	tick := time.NewTicker(20 * time.Millisecond)
+	defer tick.Stop()
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case err := <-received:
+			if err != nil {
+				t.Fatal(err)
+			}
+			return
+		case <-tick.C:
+			var updated struct {
+				UpdateOrderStatus struct{ ID string }
+			}
+			stack.MustPost(`mutation($id: String!) { updateOrderStatus(orderId: $id, status: PAID) { id } }`, &updated, client.Var("id", orderID), asAdmin)
+		case <-timeout:
+			t.Fatal("no subscription event received")
+		}
+	}
+}
+
+func TestOrderUpdatedSubscription(t *testing.T) {
+	stack := newTestStack(t, withAdminToken)
+	_, orderID := createTestOrder(t, stack)
+
+	sub := stack.Websocket(`subscription($id: String!) { orderUpdated(orderId: $id) { id status } }`, client.Var("id", orderID))
+	defer sub.Close()
+
+	id, status := nextOrder(t, stack, sub, orderID)
+	if id != orderID || status != "PAID" {
+		t.Errorf("orderUpdated = %s %s, want %s PAID", id, status, orderID)
+	}
+}
+
+func TestOrdersForAccountSubscription(t *testing.T) {
+	stack := newTestStack(t, withAdminToken)
+	accountID, orderID := createTestOrder(t, stack)
+	_, otherOrderID := createTestOrder(t, stack)
+
+	sub := stack.Websocket(`subscription($id: String!) { ordersForAccount(accountId: $id) { id status } }`, client.Var("id", accountID))
+	defer sub.Close()
+
+	// Changes to other accounts' orders are not delivered.
+	var updated struct {
+		UpdateOrderStatus struct{ ID string }
+	}
+	stack.MustPost(`mutation($id: String!) { updateOrderStatus(orderId: $id, status: PAID) { id } }`, &updated, client.Var("id", otherOrderID), asAdmin)
+
+	if id, _ := nextOrder(t, stack, sub, orderID); id != orderID {
+		t.Errorf("ordersForAccount delivered order %s, want %s", id, orderID)
+	}
+}
+
+func TestSubscriptionEventsLoadCurrentProducts(t *testing.T) {
+	stack := newTestStack(t, withAdminToken)
+	_, orderID := createTestOrder(t, stack)
+
+	sub := stack.Websocket(`subscription($id: String!) { orderUpdated(orderId: $id) { status products { id product { archived } } } }`, client.Var("id", orderID))
+	defer sub.Close()
+
+	type event struct {
+		OrderUpdated struct {
+			Status   string
+			Products []struct {
+				ID      string
+				Product struct{ Archived bool }
+			}
+		}
+	}
+	var first event
+	nextEvent(t, stack, sub, orderID, &first)
+	if len(first.OrderUpdated.Products) != 1 || first.OrderUpdated.Products[0].Product.Archived {
+		t.Fatalf("first event = %+v, want one product, not archived", first.OrderUpdated)
+	}
+
+	var archived struct {
+		ArchiveProduct struct{ Archived bool }
+	}
+	stack.MustPost(`mutation($id: String!) { archiveProduct(id: $id) { archived } }`, &archived, client.Var("id", first.OrderUpdated.Products[0].ID), asAdmin)
+	var shipped struct {
+		UpdateOrderStatus struct{ ID string }
+	}
+	stack.MustPost(`mutation($id: String!) { updateOrderStatus(orderId: $id, status: SHIPPED) { id } }`, &shipped, client.Var("id", orderID), asAdmin)
+
+	// Events from before the product was archived may still be queued, so
+	// the shipping event is the one to check.
+	received := make(chan event)
+	go func() {
+		for {
+			var e event
+			if err := sub.Next(&e); err != nil {
+				return
+			}
+			if e.OrderUpdated.Status == "SHIPPED" {
+				received <- e
+				return
+			}
+		}
+	}()
+	select {
+	case e := <-received:
+		if len(e.OrderUpdated.Products) != 1 || !e.OrderUpdated.Products[0].Product.Archived {
+			t.Errorf("event after archiving = %+v, want the product archived", e.OrderUpdated)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event for the shipped order received")
+	}
+}
+
+func TestUpdateOrderStatusInvalid(t *testing.T) {
+	stack := newTestStack(t, withAdminToken)
+	_, orderID := createTestOrder(t, stack)
+
+	var res struct {
+		UpdateOrderStatus *struct{ ID string }
+	}
+	err := stack.Post(`mutation { updateOrderStatus(orderId: "missing", status: PAID) { id } }`, &res, asAdmin)
+	if err == nil {
+		t.Error("updateOrderStatus for an unknown order succeeded")
+	}
+
+	errs := postErrors(t, stack, `mutation($id: String!) { updateOrderStatus(orderId: $id, status: DELIVERED) { id } }`, client.Var("id", orderID), asAdmin)
+	if len(errs) != 1 || errs[0].Extensions["code"] != codeInvalidArgument {
+		t.Errorf("delivering a pending order: errors = %+v, want INVALID_ARGUMENT", errs)
+	}
+}
+
+func TestUpdateOrderStatusRequiresAdmin(t *testing.T) {
+	stack := newTestStack(t, withAdminToken)
+	_, orderID := createTestOrder(t, stack)
+
+	query := `mutation($id: String!) { updateOrderStatus(orderId: $id, status: CANCELLED) { id } }`
+	for name, options := range map[string][]client.Option{
+		"no token":    {client.Var("id", orderID)},
+		"wrong token": {client.Var("id", orderID), client.AddHeader("Authorization", "Bearer guess")},
+	} {
+		errs := postErrors(t, stack, query, options...)
+		if len(errs) != 1 || errs[0].Extensions["code"] != codeUnauthenticated {
+			t.Errorf("%s: errors = %+v, want UNAUTHENTICATED", name, errs)
+		}
+	}
+}
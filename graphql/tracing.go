@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/valkyraycho/go-microservices/graphql"
+
+// tracing records a span for every operation and for every field that calls a
+// resolver, so the service calls a field makes are nested under its span.
+type tracing struct {
+	tracer trace.Tracer
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+	graphql.FieldInterceptor
+} = tracing{}
+
+func newTracing() tracing {
+	return tracing{tracer: otel.Tracer(tracerName)}
+}
+
+func (t tracing) ExtensionName() string {
+	return "Tracing"
+}
+
+func (t tracing) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (t tracing) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+	opCtx := graphql.GetOperationContext(ctx)
+	if opCtx.Operation == nil {
+		return next(ctx)
+	}
+
+	opType := string(opCtx.Operation.Operation)
+	name := opType
+	if opCtx.Operation.Name != "" {
+		name += " " + opCtx.Operation.Name
+	}
+
+	ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("graphql.operation.type", opType),
+		attribute.String("graphql.operation.name", opCtx.Operation.Name),
+	))
+	defer span.End()
+
+	res := next(ctx)
+	if res != nil && len(res.Errors) > 0 {
+		span.SetStatus(codes.Error, res.Errors.Error())
+	}
+	return res
+}
+
+func (t tracing) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !fc.IsResolver {
+		return next(ctx)
+	}
+
+	ctx, span := t.tracer.Start(ctx, fc.Object+"."+fc.Field.Name, trace.WithAttributes(
+		attribute.String("graphql.field.path", fc.Path().String()),
+	))
+	defer span.End()
+
+	res, err := next(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return res, err
+}
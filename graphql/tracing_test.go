@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestCreateOrderIsTracedAcrossServices(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	stack := newTestStack(t)
+	createTestOrder(t, stack)
+
+	byName := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		if span.SpanKind() == trace.SpanKindServer || span.SpanKind() == trace.SpanKindInternal {
+			byName[span.Name()] = span
+		}
+	}
+
+	field, ok := byName["Mutation.createOrder"]
+	if !ok {
+		t.Fatalf("no span for Mutation.createOrder in %v", names(byName))
+	}
+	for _, name := range []string{
+		"mutation",
+		"order_service.OrderService/PostOrder",
+		"account_service.AccountService/GetAccount",
+		"catalog_service.CatalogService/GetProducts",
+	} {
+		span, ok := byName[name]
+		if !ok {
+			t.Errorf("no span for %s in %v", name, names(byName))
+			continue
+		}
+		if span.SpanContext().TraceID() != field.SpanContext().TraceID() {
+			t.Errorf("span %s is in trace %s, want %s", name, span.SpanContext().TraceID(), field.SpanContext().TraceID())
+		}
+	}
+}
+
+func names(spans map[string]sdktrace.ReadOnlySpan) []string {
+	var names []string
+	for name := range spans {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,92 @@
+// Package grpcerr reports the errors of the services as gRPC statuses, so
+// that callers can tell a missing resource or an invalid request from a
+// failure, and keeps the details of failures from reaching them.
+package grpcerr
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// Converter returns the status of an error a service knows about, such as
+// its ErrNotFound, and nil for any other error.
+type Converter func(err error) error
+
+// FromError converts an error returned by a service into a gRPC status.
+// Errors that already carry a status are returned as they are, and the errors
+// of a context get the status of their cause. convert handles the errors of
+// the service, and any other error becomes Internal.
+func FromError(err error, convert Converter) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	if convert != nil {
+		if s := convert(err); s != nil {
+			return s
+		}
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// UnaryServerInterceptor converts the errors of unary handlers with
+// FromError.
+func UnaryServerInterceptor(convert Converter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		res, err := handler(ctx, req)
+		if err != nil {
+			return nil, FromError(err, convert)
+		}
+		return res, nil
+	}
+}
+
+// StreamServerInterceptor converts the errors of stream handlers with
+// FromError.
+func StreamServerInterceptor(convert Converter) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := handler(srv, ss); err != nil {
+			return FromError(err, convert)
+		}
+		return nil
+	}
+}
+
+// NotFound returns a NotFound status with message, naming the missing
+// resource in a ResourceInfo detail. name may be empty.
+func NotFound(resourceType string, name string, message string) error {
+	return WithDetails(status.New(codes.NotFound, message), &errdetails.ResourceInfo{
+		ResourceType: resourceType,
+		ResourceName: name,
+		Description:  message,
+	})
+}
+
+// WithDetails returns s with details attached, or s alone if they cannot be.
+func WithDetails(s *status.Status, details ...protoadapt.MessageV1) error {
+	if detailed, err := s.WithDetails(details...); err == nil {
+		return detailed.Err()
+	}
+	return s.Err()
+}
+
+// Redact returns the error of a call to another service as the caller of this
+// one should see it. Statuses meant for callers, such as NotFound or
+// Unavailable, are kept. Failures of the other service, and of the connection
+// to it, become Internal with a generic message, since their messages may
+// name its stores and their errors. The original is for the logs.
+func Redact(err error) error {
+	switch status.Code(err) {
+	case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unauthenticated, codes.PermissionDenied, codes.Unimplemented:
+		return status.Error(codes.Internal, "internal error")
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+package grpcerr_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/valkyraycho/go-microservices/grpcerr"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errMissing = errors.New("widget not found")
+
+func convert(err error) error {
+	if errors.Is(err, errMissing) {
+		return grpcerr.NotFound("widget", "", err.Error())
+	}
+	return nil
+}
+
+func TestFromError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{
+		{"known", fmt.Errorf("getting: %w", errMissing), codes.NotFound},
+		{"status", status.Error(codes.InvalidArgument, "bad"), codes.InvalidArgument},
+		{"canceled", context.Canceled, codes.Canceled},
+		{"deadline", fmt.Errorf("querying: %w", context.DeadlineExceeded), codes.DeadlineExceeded},
+		{"other", errors.New("pq: connection refused"), codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(grpcerr.FromError(tt.err, convert)); got != tt.want {
+				t.Errorf("FromError(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	err := grpcerr.NotFound("widget", "w1", "widget not found")
+
+	s := status.Convert(err)
+	if s.Code() != codes.NotFound || s.Message() != "widget not found" {
+		t.Fatalf("NotFound = %v", err)
+	}
+	if len(s.Details()) != 1 {
+		t.Fatalf("details = %v, want a ResourceInfo", s.Details())
+	}
+	info, ok := s.Details()[0].(*errdetails.ResourceInfo)
+	if !ok || info.ResourceType != "widget" || info.ResourceName != "w1" {
+		t.Errorf("detail = %v, want the widget w1", s.Details()[0])
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{status.Error(codes.Internal, "pq: relation \"accounts\" does not exist"), status.Error(codes.Internal, "internal error")},
+		{status.Error(codes.Unknown, "elastic: Error 500"), status.Error(codes.Internal, "internal error")},
+		{status.Error(codes.Unauthenticated, "tls: bad certificate"), status.Error(codes.Internal, "internal error")},
+		{status.Error(codes.NotFound, "account not found"), status.Error(codes.NotFound, "account not found")},
+		{status.Error(codes.Unavailable, "connection refused"), status.Error(codes.Unavailable, "connection refused")},
+		{status.Error(codes.DeadlineExceeded, "deadline exceeded"), status.Error(codes.DeadlineExceeded, "deadline exceeded")},
+	}
+
+	for _, tt := range tests {
+		got := status.Convert(grpcerr.Redact(tt.err))
+		want := status.Convert(tt.want)
+		if got.Code() != want.Code() || got.Message() != want.Message() {
+			t.Errorf("Redact(%v) = %v, want %v", tt.err, got.Err(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+// Package grpcerrtest helps tests check the statuses built with grpcerr.
+package grpcerrtest
+
+import (
+	"testing"
+
+	"github.com/valkyraycho/go-microservices/grpcerr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ViolatedFields fails the test unless err is an InvalidArgument status, and
+// returns the fields it lists as invalid.
+func ViolatedFields(t testing.TB, err error) []string {
+	t.Helper()
+
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Fatalf("error = %v, want InvalidArgument", err)
+	}
+
+	fields := []string{}
+	for _, v := range grpcerr.FieldViolations(err) {
+		fields = append(fields, v.Field)
+	}
+	return fields
+}
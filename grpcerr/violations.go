@@ -0,0 +1,46 @@
+package grpcerr
+
+import (
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Violations collects the invalid fields of a request.
+type Violations []*errdetails.BadRequest_FieldViolation
+
+// Add records that field is invalid, as description says.
+func (v *Violations) Add(field string, description string) {
+	*v = append(*v, &errdetails.BadRequest_FieldViolation{Field: field, Description: description})
+}
+
+// Err returns an InvalidArgument status that lists every violation, or nil if
+// there are none.
+func (v Violations) Err() error {
+	if len(v) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(v))
+	for i, fv := range v {
+		messages[i] = fv.Field + " " + fv.Description
+	}
+	return WithDetails(
+		status.New(codes.InvalidArgument, "invalid argument: "+strings.Join(messages, "; ")),
+		&errdetails.BadRequest{FieldViolations: v},
+	)
+}
+
+// FieldViolations returns the violations listed in the BadRequest details of
+// err.
+func FieldViolations(err error) Violations {
+	var v Violations
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			v = append(v, badRequest.FieldViolations...)
+		}
+	}
+	return v
+}
@@ -0,0 +1,28 @@
+package grpcerr_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/valkyraycho/go-microservices/grpcerr"
+	"github.com/valkyraycho/go-microservices/grpcerr/grpcerrtest"
+	"google.golang.org/grpc/status"
+)
+
+func TestViolations(t *testing.T) {
+	var v grpcerr.Violations
+	if err := v.Err(); err != nil {
+		t.Fatalf("Err without violations = %v, want nil", err)
+	}
+
+	v.Add("name", "must not be empty")
+	v.Add("price", "must not be negative")
+	err := v.Err()
+
+	if got := grpcerrtest.ViolatedFields(t, err); !reflect.DeepEqual(got, []string{"name", "price"}) {
+		t.Errorf("violated fields = %v, want name and price", got)
+	}
+	if want := "invalid argument: name must not be empty; price must not be negative"; status.Convert(err).Message() != want {
+		t.Errorf("message = %q, want %q", status.Convert(err).Message(), want)
+	}
+}
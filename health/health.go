@@ -0,0 +1,187 @@
+// Package health reports whether a service can reach the stores and services
+// it depends on, over the gRPC health protocol and over HTTP.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Timeout bounds one round of checks, so a dependency that hangs is reported
+// as unreachable instead of stalling the caller.
+const Timeout = 2 * time.Second
+
+// WatchInterval is how often a Watch call runs the checks again.
+var WatchInterval = 5 * time.Second
+
+// Check returns an error when a dependency cannot be reached.
+type Check func(ctx context.Context) error
+
+// Checks are the dependencies of a service, keyed by name.
+type Checks map[string]Check
+
+// Run runs every check concurrently and returns the errors of those that
+// failed, keyed by name. It returns nil when every check passes.
+func (c Checks) Run(ctx context.Context) map[string]error {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		failed map[string]error
+	)
+	for name, check := range c {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := check(ctx); err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				if failed == nil {
+					failed = map[string]error{}
+				}
+				failed[name] = err
+			}
+		}()
+	}
+	wg.Wait()
+	return failed
+}
+
+type server struct {
+	healthpb.UnimplementedHealthServer
+	checks   Checks
+	services map[string]bool
+}
+
+// Register adds the grpc.health.v1 service to s. The server as a whole, named
+// "", and every service registered on s so far are SERVING while all checks
+// pass and NOT_SERVING otherwise. Checks run on every Check call rather than
+// in the background, so the answer is never stale.
+func Register(s *grpc.Server, checks Checks) {
+	services := map[string]bool{"": true}
+	for name := range s.GetServiceInfo() {
+		services[name] = true
+	}
+	healthpb.RegisterHealthServer(s, &server{checks: checks, services: services})
+}
+
+func (s *server) Check(ctx context.Context, r *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if !s.services[r.Service] {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", r.Service)
+	}
+	return &healthpb.HealthCheckResponse{Status: s.status(ctx)}, nil
+}
+
+// Watch sends the status of the service, and then every change to it, until
+// the client goes away.
+func (s *server) Watch(r *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	if !s.services[r.Service] {
+		return stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVICE_UNKNOWN})
+	}
+
+	ticker := time.NewTicker(WatchInterval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_UNKNOWN
+	for {
+		if current := s.status(stream.Context()); current != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: current}); err != nil {
+				return err
+			}
+			last = current
+		}
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *server) status(ctx context.Context) healthpb.HealthCheckResponse_ServingStatus {
+	failed := s.checks.Run(ctx)
+	if len(failed) == 0 {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	for name, err := range failed {
+		slog.WarnContext(ctx, "dependency is unreachable", "dependency", name, "err", err)
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
+
+// Ping asks the health service on conn about service and returns an error
+// unless it is SERVING.
+func Ping(ctx context.Context, conn grpc.ClientConnInterface, service string) error {
+	res, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		return err
+	}
+	if res.Status != healthpb.HealthCheckResponse_SERVING {
+		return status.Errorf(codes.Unavailable, "%s is %s", serviceName(service), res.Status)
+	}
+	return nil
+}
+
+// Probe checks the server listening on addr as a whole. Services run it from
+// their healthcheck subcommand, since their images have no other client. The
+// options are applied after the default plaintext transport, so they can
+// replace it.
+func Probe(addr string, opts ...grpc.DialOption) error {
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	conn, err := grpc.NewClient(addr, opts...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	return Ping(ctx, conn, "")
+}
+
+func serviceName(service string) string {
+	if service == "" {
+		return "server"
+	}
+	return service
+}
+
+// Handler answers 200 while every check passes and 503 otherwise. The body
+// names the checks that failed, and their errors are logged rather than
+// returned. Without checks it only reports that the process is up.
+func Handler(checks Checks) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := struct {
+			Status string   `json:"status"`
+			Failed []string `json:"failed,omitempty"`
+		}{Status: "ok"}
+
+		code := http.StatusOK
+		if failed := checks.Run(r.Context()); len(failed) > 0 {
+			code = http.StatusServiceUnavailable
+			res.Status = "unavailable"
+			for name, err := range failed {
+				slog.WarnContext(r.Context(), "dependency is unreachable", "dependency", name, "err", err)
+				res.Failed = append(res.Failed, name)
+			}
+			slices.Sort(res.Failed)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(res)
+	})
+}
@@ -0,0 +1,200 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valkyraycho/go-microservices/health"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newHealthConn serves the health service with checks over bufconn and
+// returns a connection to it.
+func newHealthConn(t *testing.T, checks health.Checks) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	health.Register(server, checks)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///health",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestCheck(t *testing.T) {
+	var down atomic.Bool
+	client := healthpb.NewHealthClient(newHealthConn(t, health.Checks{
+		"ok": func(ctx context.Context) error { return nil },
+		"store": func(ctx context.Context) error {
+			if down.Load() {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+	}))
+	ctx := context.Background()
+
+	check := func() healthpb.HealthCheckResponse_ServingStatus {
+		t.Helper()
+		res, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Fatalf("Check: %v", err)
+		}
+		return res.Status
+	}
+
+	if got := check(); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("status = %v, want SERVING", got)
+	}
+
+	down.Store(true)
+	if got := check(); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("status with the store down = %v, want NOT_SERVING", got)
+	}
+
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown.Service"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Check of an unknown service: error = %v, want NotFound", err)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	client := healthpb.NewHealthClient(newHealthConn(t, health.Checks{
+		"hang": func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}))
+
+	res, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if res.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("status = %v, want NOT_SERVING", res.Status)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	prev := health.WatchInterval
+	health.WatchInterval = 10 * time.Millisecond
+	t.Cleanup(func() { health.WatchInterval = prev })
+
+	var down atomic.Bool
+	client := healthpb.NewHealthClient(newHealthConn(t, health.Checks{
+		"store": func(ctx context.Context) error {
+			if down.Load() {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []healthpb.HealthCheckResponse_ServingStatus{
+		healthpb.HealthCheckResponse_SERVING,
+		healthpb.HealthCheckResponse_NOT_SERVING,
+	} {
+		res, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if res.Status != want {
+			t.Errorf("status = %v, want %v", res.Status, want)
+		}
+		down.Store(true)
+	}
+}
+
+func TestPing(t *testing.T) {
+	conn := newHealthConn(t, health.Checks{
+		"store": func(ctx context.Context) error { return errors.New("connection refused") },
+	})
+
+	if err := health.Ping(context.Background(), conn, ""); status.Code(err) != codes.Unavailable {
+		t.Errorf("Ping error = %v, want Unavailable", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		checks     health.Checks
+		wantCode   int
+		wantFailed []string
+	}{
+		{name: "NoChecks", wantCode: http.StatusOK},
+		{
+			name:     "Passing",
+			checks:   health.Checks{"order": func(ctx context.Context) error { return nil }},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "Failing",
+			checks: health.Checks{
+				"account": func(ctx context.Context) error { return nil },
+				"order":   func(ctx context.Context) error { return errors.New("connection refused") },
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantFailed: []string{"order"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			health.Handler(tt.checks).ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", rec.Code, tt.wantCode)
+			}
+
+			// Only the names of the failed checks are returned, never their
+			// errors.
+			if strings.Contains(rec.Body.String(), "connection refused") {
+				t.Errorf("body = %s, want no errors in it", rec.Body)
+			}
+			var body struct {
+				Failed []string `json:"failed"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(body.Failed, tt.wantFailed) {
+				t.Errorf("failed = %v, want %v", body.Failed, tt.wantFailed)
+			}
+		})
+	}
+}
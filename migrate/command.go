@@ -0,0 +1,89 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Usage describes the arguments Command takes.
+const Usage = "migrate up | down [steps] | status"
+
+// Command runs the migrate subcommand of a service with args, the arguments
+// that follow it: up applies the pending migrations, down rolls back the last
+// steps migrations, one by default, and status lists them all. It reports to
+// w.
+func Command(ctx context.Context, m *Migrator, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s", Usage)
+	}
+
+	switch command, args := args[0], args[1:]; command {
+	case "up":
+		if len(args) > 0 {
+			return fmt.Errorf("usage: %s", Usage)
+		}
+		applied, err := m.Up(ctx)
+		for _, migration := range applied {
+			fmt.Fprintf(w, "applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(w, "no pending migrations")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		switch len(args) {
+		case 0:
+		case 1:
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("migrate: steps must be a positive number, not %q", args[0])
+			}
+			steps = n
+		default:
+			return fmt.Errorf("usage: %s", Usage)
+		}
+		rolledBack, err := m.Down(ctx, steps)
+		for _, migration := range rolledBack {
+			fmt.Fprintf(w, "rolled back %d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(rolledBack) == 0 {
+			fmt.Fprintln(w, "no migrations to roll back")
+		}
+		return err
+
+	case "status":
+		if len(args) > 0 {
+			return fmt.Errorf("usage: %s", Usage)
+		}
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return printStatus(w, statuses)
+
+	default:
+		return fmt.Errorf("migrate: unknown command %q, usage: %s", command, Usage)
+	}
+}
+
+func printStatus(w io.Writer, statuses []Status) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if !s.AppliedAt.IsZero() {
+			appliedAt = s.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		if s.Unknown {
+			appliedAt += " (unknown to this build)"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,319 @@
+// Package migrate keeps the schema of a Postgres database up to date with
+// versioned SQL migrations, which the services embed and apply on startup.
+// The versions applied are recorded in the schema_migrations table.
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// lockID keys the advisory lock held while migrating, so replicas that start
+// together apply each migration once.
+const lockID = 7_231_965_203
+
+// Migration changes the schema from the previous version to Version. It is
+// read from the files <version>_<name>.up.sql and <version>_<name>.down.sql,
+// such as 0001_create_accounts.up.sql. The down file is optional, but a
+// migration without one cannot be rolled back.
+type Migration struct {
+	Version int64
+	Name    string
+	up      string
+	down    string
+}
+
+// Status is the state of a migration in a database.
+type Status struct {
+	Version int64
+	Name    string
+	// AppliedAt is zero when the migration is pending.
+	AppliedAt time.Time
+	// Unknown is set when the migration was applied but is not among those
+	// the Migrator has, such as after running an older build.
+	Unknown bool
+}
+
+// Load reads the migrations at the root of fsys, sorted by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, file := range files {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migrate: %s is neither an up nor a down migration", file)
+		}
+		prefix, name, ok := strings.Cut(base, "_")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("migrate: %s is not named <version>_<name>.%s.sql", file, direction)
+		}
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migrate: %s has an invalid version", file)
+		}
+
+		b, err := fs.ReadFile(fsys, path.Clean(file))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migrate: version %d is used by both %s and %s", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.up = string(b)
+		} else {
+			m.down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migrate: migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return migrations, nil
+}
+
+// Migrator applies and rolls back the migrations of a database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// Open connects to the Postgres database at url and loads the migrations at
+// the root of fsys.
+func Open(url string, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func (m *Migrator) Close() {
+	m.db.Close()
+}
+
+// Up applies the pending migrations in order, each in a transaction of its
+// own, and returns those it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			err := inTx(ctx, conn, migration.up,
+				"INSERT INTO schema_migrations(version, name) VALUES($1, $2)", migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("migrate: applying %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			slog.Info("applied migration", "version", migration.Version, "name", migration.Name)
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the last steps migrations applied, newest first, and
+// returns those it rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var rolledBack []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		latest := slices.Sorted(maps.Keys(versions))
+		slices.Reverse(latest)
+		if steps < len(latest) {
+			latest = latest[:steps]
+		}
+
+		for _, version := range latest {
+			i := slices.IndexFunc(m.migrations, func(m Migration) bool { return m.Version == version })
+			if i < 0 {
+				return fmt.Errorf("migrate: migration %d_%s is unknown to this build", version, versions[version])
+			}
+			migration := m.migrations[i]
+			if migration.down == "" {
+				return fmt.Errorf("migrate: migration %d_%s cannot be rolled back", migration.Version, migration.Name)
+			}
+			err := inTx(ctx, conn, migration.down,
+				"DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+			if err != nil {
+				return fmt.Errorf("migrate: rolling back %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			slog.Info("rolled back migration", "version", migration.Version, "name", migration.Name)
+			rolledBack = append(rolledBack, migration)
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// Status returns the state of every migration, known or applied, sorted by
+// version.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		rows, err := conn.QueryContext(ctx, "SELECT version, name, applied_at FROM schema_migrations")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		applied := map[int64]Status{}
+		for rows.Next() {
+			s := Status{Unknown: true}
+			if err := rows.Scan(&s.Version, &s.Name, &s.AppliedAt); err != nil {
+				return err
+			}
+			applied[s.Version] = s
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			s, ok := applied[migration.Version]
+			if !ok {
+				s = Status{Version: migration.Version, Name: migration.Name}
+			}
+			s.Unknown = false
+			applied[migration.Version] = s
+		}
+		for _, s := range applied {
+			statuses = append(statuses, s)
+		}
+		slices.SortFunc(statuses, func(a, b Status) int {
+			return cmp.Compare(a.Version, b.Version)
+		})
+		return nil
+	})
+	return statuses, err
+}
+
+// locked runs f on a connection that holds the migration lock, once the
+// schema_migrations table exists.
+func (m *Migrator) locked(ctx context.Context, f func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return err
+	}
+	defer func() {
+		// The lock goes with the session, so the connection is not reused
+		// when it cannot be released.
+		if _, unlockErr := conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", lockID); unlockErr != nil {
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+			err = errors.Join(err, unlockErr)
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return err
+	}
+	return f(conn)
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]string, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, name FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[int64]string{}
+	for rows.Next() {
+		var version int64
+		var name string
+		if err := rows.Scan(&version, &name); err != nil {
+			return nil, err
+		}
+		versions[version] = name
+	}
+	return versions, rows.Err()
+}
+
+// inTx runs script and records the change with query in one transaction, so a
+// migration that fails leaves nothing behind.
+func inTx(ctx context.Context, conn *sql.Conn, script string, query string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Up applies the pending migrations in fsys to the database at url, as the
+// services do on startup.
+func Up(ctx context.Context, url string, fsys fs.FS) error {
+	m, err := Open(url, fsys)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	_, err = m.Up(ctx)
+	return err
+}
@@ -0,0 +1,169 @@
+package migrate_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/valkyraycho/go-microservices/migrate"
+)
+
+func file(s string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(s)}
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := migrate.Load(fstest.MapFS{
+		"0010_add_email.up.sql":          file("ALTER TABLE users ADD COLUMN email TEXT"),
+		"0002_create_users.up.sql":       file("CREATE TABLE users (id TEXT)"),
+		"0002_create_users.down.sql":     file("DROP TABLE users"),
+		"README.md":                      file("not a migration"),
+		"0001_create_extension.up.sql":   file("SELECT 1"),
+		"0001_create_extension.down.sql": file("SELECT 1"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, m := range migrations {
+		got = append(got, m.Name)
+	}
+	if want := "create_extension create_users add_email"; strings.Join(got, " ") != want {
+		t.Errorf("Load = %v, want %s", got, want)
+	}
+	if migrations[2].Version != 10 {
+		t.Errorf("add_email has version %d, want 10", migrations[2].Version)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		fsys  fstest.MapFS
+		error string
+	}{
+		{"no direction", fstest.MapFS{"0001_init.sql": file("SELECT 1")}, "neither an up nor a down"},
+		{"no name", fstest.MapFS{"0001.up.sql": file("SELECT 1")}, "is not named"},
+		{"invalid version", fstest.MapFS{"v1_init.up.sql": file("SELECT 1")}, "invalid version"},
+		{"zero version", fstest.MapFS{"0_init.up.sql": file("SELECT 1")}, "invalid version"},
+		{"shared version", fstest.MapFS{
+			"0001_init.up.sql":  file("SELECT 1"),
+			"0001_other.up.sql": file("SELECT 1"),
+		}, "is used by both"},
+		{"down only", fstest.MapFS{"0001_init.down.sql": file("SELECT 1")}, "has no up file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := migrate.Load(tt.fsys)
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("Load error = %v, want one containing %q", err, tt.error)
+			}
+		})
+	}
+}
+
+func TestCommandUsage(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"sideways"},
+		{"up", "2"},
+		{"down", "0"},
+		{"down", "x"},
+		{"down", "1", "2"},
+		{"status", "all"},
+	} {
+		// Invalid arguments are turned down before the database is used.
+		if err := migrate.Command(context.Background(), nil, args, &bytes.Buffer{}); err == nil {
+			t.Errorf("Command(%q) accepted invalid arguments", args)
+		}
+	}
+}
+
+// TestMigrator runs against the database in TEST_DATABASE_URL and drops the
+// tables it creates, so it must not point at real data.
+func TestMigrator(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	drop := func() {
+		if _, err := db.Exec("DROP TABLE IF EXISTS migrate_test_notes, migrate_test_tags, schema_migrations"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	drop()
+	t.Cleanup(drop)
+
+	fsys := fstest.MapFS{
+		"0001_create_notes.up.sql":   file("CREATE TABLE migrate_test_notes (id TEXT PRIMARY KEY)"),
+		"0001_create_notes.down.sql": file("DROP TABLE migrate_test_notes"),
+		"0002_create_tags.up.sql":    file("CREATE TABLE migrate_test_tags (id TEXT PRIMARY KEY)"),
+		"0002_create_tags.down.sql":  file("DROP TABLE migrate_test_tags"),
+	}
+	m, err := migrate.Open(url, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	applied, err := m.Up(ctx)
+	if err != nil || len(applied) != 2 {
+		t.Fatalf("Up = %v, %v; want both migrations applied", applied, err)
+	}
+	if applied, err := m.Up(ctx); err != nil || len(applied) != 0 {
+		t.Errorf("Up again = %v, %v; want nothing applied", applied, err)
+	}
+	if _, err := db.Exec("INSERT INTO migrate_test_tags(id) VALUES('go')"); err != nil {
+		t.Errorf("the migrated table cannot be used: %v", err)
+	}
+
+	rolledBack, err := m.Down(ctx, 1)
+	if err != nil || len(rolledBack) != 1 || rolledBack[0].Name != "create_tags" {
+		t.Fatalf("Down = %v, %v; want create_tags rolled back", rolledBack, err)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || statuses[0].AppliedAt.IsZero() || !statuses[1].AppliedAt.IsZero() {
+		t.Errorf("Status = %+v, want create_notes applied and create_tags pending", statuses)
+	}
+
+	// A migration that fails leaves neither its changes nor its version.
+	broken, err := migrate.Open(url, fstest.MapFS{
+		"0001_create_notes.up.sql": fsys["0001_create_notes.up.sql"],
+		"0002_create_tags.up.sql":  file("CREATE TABLE migrate_test_tags (id TEXT PRIMARY KEY); SELECT nonsense"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer broken.Close()
+	if _, err := broken.Up(ctx); err == nil {
+		t.Error("Up applied a broken migration")
+	}
+	if _, err := db.Exec("SELECT 1 FROM migrate_test_tags"); err == nil {
+		t.Error("a failed migration left its table behind")
+	}
+
+	var out bytes.Buffer
+	if err := migrate.Command(ctx, m, []string{"status"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "create_tags") || !strings.Contains(out.String(), "pending") {
+		t.Errorf("status printed %q, want create_tags pending", out.String())
+	}
+}
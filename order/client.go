@@ -4,7 +4,12 @@ import (
 	"context"
 	"time"
 
+	"github.com/valkyraycho/go-microservices/health"
 	pb "github.com/valkyraycho/go-microservices/order/proto"
+	"github.com/valkyraycho/go-microservices/ratelimit"
+	"github.com/valkyraycho/go-microservices/resilience"
+	"github.com/valkyraycho/go-microservices/telemetry"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
@@ -14,8 +19,25 @@ type Client struct {
 	service pb.OrderServiceClient
 }
 
-func NewClient(url string) (*Client, error) {
-	conn, err := grpc.NewClient(url, grpc.WithTransportCredentials(insecure.NewCredentials()))
+// ReadMethods are the OrderService methods that only read, so they are safe to
+// retry and hedge.
+var ReadMethods = []string{"GetOrdersForAccount", "GetOrdersForAccounts", "GetOrdersForProduct", "GetOrdersByIDs"}
+
+// NewClient connects to the service at url. Calls are traced and measured,
+// and made resilient to failures as set by policy. Extra options are applied
+// after the defaults, so they can override the transport.
+func NewClient(url string, policy resilience.Config, opts ...grpc.DialOption) (*Client, error) {
+	resilient, err := policy.DialOptions(pb.OrderService_ServiceDesc, ReadMethods...)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(telemetry.ClientMetrics.UnaryClientInterceptor(), telemetry.UnaryClientRequestID, ratelimit.UnaryClientInterceptor),
+		grpc.WithChainStreamInterceptor(telemetry.ClientMetrics.StreamClientInterceptor(), telemetry.StreamClientRequestID, ratelimit.StreamClientInterceptor),
+	}, resilient...), opts...)
+	conn, err := grpc.NewClient(url, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -26,6 +48,12 @@ func (c *Client) Close() {
 	c.conn.Close()
 }
 
+// Ping returns an error unless the order service reports that it is serving,
+// which it only does while its own dependencies are reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	return health.Ping(ctx, c.conn, pb.OrderService_ServiceDesc.ServiceName)
+}
+
 func (c *Client) PostOrder(ctx context.Context, accountID string, products []OrderedProduct) (*Order, error) {
 	pbProducts := []*pb.PostOrderRequest_OrderProduct{}
 
@@ -43,16 +71,11 @@ func (c *Client) PostOrder(ctx context.Context, accountID string, products []Ord
 	if err != nil {
 		return nil, err
 	}
-	newOrderCreatedAt := time.Time{}
-	newOrderCreatedAt.UnmarshalBinary(res.Order.CreatedAt)
 
-	return &Order{
-		ID:         res.Order.Id,
-		TotalPrice: res.Order.TotalPrice,
-		AccountID:  res.Order.AccountId,
-		Products:   products,
-		CreatedAt:  newOrderCreatedAt,
-	}, nil
+	// The service merges repeated products, so return the products it
+	// stored rather than the ones requested.
+	o := orderFromProto(res.Order)
+	return &o, nil
 }
 
 func (c *Client) GetOrdersForAccount(ctx context.Context, accountID string) ([]Order, error) {
@@ -64,25 +87,120 @@ func (c *Client) GetOrdersForAccount(ctx context.Context, accountID string) ([]O
 	orders := []Order{}
 
 	for _, pbOrder := range res.Orders {
-		newOrder := Order{
-			ID:         pbOrder.Id,
-			AccountID:  pbOrder.AccountId,
-			TotalPrice: pbOrder.TotalPrice,
-		}
-		newOrder.CreatedAt = time.Time{}
-		newOrder.CreatedAt.UnmarshalBinary(pbOrder.CreatedAt)
-		products := []OrderedProduct{}
-		for _, p := range pbOrder.Products {
-			products = append(products, OrderedProduct{
-				ID:          p.Id,
-				Name:        p.Name,
-				Description: p.Description,
-				Price:       p.Price,
-				Quantity:    p.Quantity,
-			})
-		}
-		newOrder.Products = products
-		orders = append(orders, newOrder)
+		orders = append(orders, orderFromProto(pbOrder))
 	}
 	return orders, err
 }
+
+// GetOrdersForAccounts returns the orders of every given account in a single
+// call, keyed by account id. Accounts without orders have no entry.
+func (c *Client) GetOrdersForAccounts(ctx context.Context, accountIDs []string) (map[string][]Order, error) {
+	res, err := c.service.GetOrdersForAccounts(ctx, &pb.GetOrdersForAccountsRequest{AccountIds: accountIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	orders := map[string][]Order{}
+
+	for _, pbOrder := range res.Orders {
+		orders[pbOrder.AccountId] = append(orders[pbOrder.AccountId], orderFromProto(pbOrder))
+	}
+	return orders, nil
+}
+
+// GetOrdersByIDs returns the orders with the given ids, sorted by id. Ids
+// that match no order are left out.
+func (c *Client) GetOrdersByIDs(ctx context.Context, ids []string) ([]Order, error) {
+	res, err := c.service.GetOrdersByIDs(ctx, &pb.GetOrdersByIDsRequest{Ids: ids})
+	if err != nil {
+		return nil, err
+	}
+
+	orders := []Order{}
+	for _, pbOrder := range res.Orders {
+		orders = append(orders, orderFromProto(pbOrder))
+	}
+	return orders, nil
+}
+
+// GetOrdersForProduct returns a page of the orders that contain the product,
+// sorted by id.
+func (c *Client) GetOrdersForProduct(ctx context.Context, productID string, skip uint64, take uint64) ([]Order, error) {
+	res, err := c.service.GetOrdersForProduct(ctx, &pb.GetOrdersForProductRequest{ProductId: productID, Skip: skip, Take: take})
+	if err != nil {
+		return nil, err
+	}
+
+	orders := []Order{}
+	for _, pbOrder := range res.Orders {
+		orders = append(orders, orderFromProto(pbOrder))
+	}
+	return orders, nil
+}
+
+func orderFromProto(pbOrder *pb.Order) Order {
+	newOrder := Order{
+		ID:         pbOrder.Id,
+		AccountID:  pbOrder.AccountId,
+		TotalPrice: pbOrder.TotalPrice,
+		Status:     pbOrder.Status,
+	}
+	newOrder.CreatedAt = time.Time{}
+	newOrder.CreatedAt.UnmarshalBinary(pbOrder.CreatedAt)
+	products := []OrderedProduct{}
+	for _, p := range pbOrder.Products {
+		products = append(products, OrderedProduct{
+			ID:          p.Id,
+			Name:        p.Name,
+			Description: p.Description,
+			Price:       p.Price,
+			Quantity:    p.Quantity,
+		})
+	}
+	newOrder.Products = products
+	return newOrder
+}
+
+func (c *Client) UpdateOrderStatus(ctx context.Context, id string, status string) (*Order, error) {
+	res, err := c.service.UpdateOrderStatus(ctx, &pb.UpdateOrderStatusRequest{Id: id, Status: status})
+	if err != nil {
+		return nil, err
+	}
+
+	o := orderFromProto(res.Order)
+	return &o, nil
+}
+
+// WatchOrders streams orders as they are created or updated, limited to one
+// order or one account when orderID or accountID is set. The channel is
+// closed when ctx is done or the stream fails.
+func (c *Client) WatchOrders(ctx context.Context, orderID string, accountID string) (<-chan Order, error) {
+	stream, err := c.service.WatchOrders(ctx, &pb.WatchOrdersRequest{OrderId: orderID, AccountId: accountID})
+	if err != nil {
+		return nil, err
+	}
+
+	// The server sends headers once it is watching.
+	if _, err := stream.Header(); err != nil {
+		return nil, err
+	}
+
+	orders := make(chan Order)
+
+	go func() {
+		defer close(orders)
+		for {
+			pbOrder, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			select {
+			case orders <- orderFromProto(pbOrder):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return orders, nil
+}
@@ -1,39 +1,191 @@
 package main
 
 import (
+	"context"
 	"log"
+	"log/slog"
+	"os"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/tinrab/retry"
+	"github.com/valkyraycho/go-microservices/account"
+	"github.com/valkyraycho/go-microservices/catalog"
+	"github.com/valkyraycho/go-microservices/graceful"
+	"github.com/valkyraycho/go-microservices/health"
+	"github.com/valkyraycho/go-microservices/migrate"
 	"github.com/valkyraycho/go-microservices/order"
+	"github.com/valkyraycho/go-microservices/ratelimit"
+	"github.com/valkyraycho/go-microservices/resilience"
+	"github.com/valkyraycho/go-microservices/telemetry"
+	"github.com/valkyraycho/go-microservices/tlsconfig"
 )
 
 type Config struct {
 	DatabaseURL       string `envconfig:"DATABASE_URL"`
 	AccountServiceURL string `envconfig:"ACCOUNT_SERVICE_URL"`
 	CatalogServiceURL string `envconfig:"CATALOG_SERVICE_URL"`
+	// MigrateOnStart applies the pending migrations before serving. Turn it
+	// off to run them on their own with the migrate subcommand instead.
+	MigrateOnStart bool `envconfig:"MIGRATE_ON_START" default:"true"`
+
+	MetricsPort int `envconfig:"METRICS_PORT" default:"9090"`
+	// ShutdownTimeout bounds how long the server waits for calls in flight
+	// once it is asked to stop.
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"10s"`
+
+	// GRPCTLS secures the server, and the clients it opens, with mutual TLS.
+	GRPCTLS tlsconfig.Config `envconfig:"GRPC_TLS"`
+
+	// RateLimit bounds the calls of each caller, unless CallerQuotas sets a
+	// limit of its own for it. Callers are named by the common name of their
+	// certificate under mutual TLS, and by IP address otherwise.
+	RateLimit    ratelimit.Limit            `envconfig:"RATE_LIMIT" default:"500/1s"`
+	CallerQuotas map[string]ratelimit.Limit `envconfig:"CALLER_QUOTAS"`
+	// ClientRateLimit bounds the calls of each client that ClientForwarders
+	// name in their calls. Forwarders are only trusted under mutual TLS.
+	ClientRateLimit  ratelimit.Limit `envconfig:"CLIENT_RATE_LIMIT" default:"100/1s"`
+	ClientForwarders []string        `envconfig:"CLIENT_FORWARDERS" default:"graphql-gateway,rest-gateway,order-service"`
+
+	// Client sets the retries, deadlines, hedging and circuit breakers of the
+	// calls to the services.
+	Client resilience.Config `envconfig:"CLIENT"`
+
+	// ProductCache caches the products looked up in the catalog by id.
+	ProductCache catalog.CacheConfig `envconfig:"PRODUCT_CACHE"`
 }
 
 func main() {
+	// The image has no gRPC client, so the compose healthcheck runs the
+	// binary itself to ask the server whether it is serving.
+	if len(os.Args) > 1 && os.Args[1] == "healthcheck" {
+		if err := probe(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrateCommand(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// probe connects to the server with the same credentials its clients use.
+func probe() error {
 	var cfg Config
-	err := envconfig.Process("", &cfg)
+	if err := envconfig.Process("", &cfg); err != nil {
+		return err
+	}
+	creds, err := tlsconfig.DialOption(cfg.GRPCTLS)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	return health.Probe("localhost:8080", creds)
+}
+
+// migrateCommand applies, rolls back or lists the migrations of the database
+// as args ask.
+func migrateCommand(args []string) error {
+	var cfg Config
+	if err := envconfig.Process("", &cfg); err != nil {
+		return err
+	}
+	m, err := migrate.Open(cfg.DatabaseURL, order.Migrations)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	return migrate.Command(context.Background(), m, args, os.Stdout)
+}
+
+func run() error {
+	var cfg Config
+	if err := envconfig.Process("", &cfg); err != nil {
+		return err
+	}
+
+	shutdown, err := telemetry.Setup(context.Background(), "order")
+	if err != nil {
+		return err
+	}
+	// Spans still buffered are sent once everything else has shut down.
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			slog.Error("flushing spans", "err", err)
+		}
+	}()
+
+	go func() {
+		log.Fatal(telemetry.ListenMetrics(cfg.MetricsPort, nil))
+	}()
+
+	serverCreds, err := tlsconfig.ServerOption(cfg.GRPCTLS)
+	if err != nil {
+		return err
+	}
+	dialCreds, err := tlsconfig.DialOption(cfg.GRPCTLS)
+	if err != nil {
+		return err
+	}
+
+	accountClient, err := account.NewClient(cfg.AccountServiceURL, cfg.Client, dialCreds)
+	if err != nil {
+		return err
+	}
+	defer accountClient.Close()
+
+	catalogClient, err := catalog.NewClient(cfg.CatalogServiceURL, cfg.Client, dialCreds)
+	if err != nil {
+		return err
+	}
+	defer catalogClient.Close()
+
+	cache, err := catalog.NewProductCache(cfg.ProductCache)
+	if err != nil {
+		return err
+	}
+	if cache != nil {
+		defer cache.Close()
+		catalogClient.UseCache(cache)
 	}
 
 	var r order.Repository
 	retry.ForeverSleep(2*time.Second, func(_ int) error {
 		r, err = order.NewPostgresRepository(cfg.DatabaseURL)
 		if err != nil {
-			log.Fatal(err)
+			slog.Error("connecting to the database", "err", err)
 			return err
 		}
 		return nil
 	})
 	defer r.Close()
 
-	log.Println("Listening on port 8080...")
+	if cfg.MigrateOnStart {
+		if err := migrate.Up(context.Background(), cfg.DatabaseURL, order.Migrations); err != nil {
+			return err
+		}
+	}
+
+	// Signals are only handled once the store is connected; before that there
+	// is nothing to drain.
+	ctx, stop := graceful.SignalContext()
+	defer stop()
+
+	slog.Info("listening", "port", 8080)
 	s := order.NewService(r)
-	log.Fatal(order.ListenGRPC(s, cfg.AccountServiceURL, cfg.CatalogServiceURL, 8080))
+	quotas := ratelimit.NewQuotas(cfg.RateLimit, cfg.CallerQuotas)
+	quotas.LimitClients(cfg.ClientRateLimit, cfg.ClientForwarders)
+	if err := order.ListenGRPC(ctx, s, accountClient, catalogClient, 8080, cfg.ShutdownTimeout, append(quotas.ServerOptions(), serverCreds)...); err != nil {
+		return err
+	}
+	slog.Info("shut down")
+	return nil
 }
@@ -0,0 +1,26 @@
+package order
+
+import (
+	"errors"
+
+	"github.com/valkyraycho/go-microservices/grpcerr"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// statusError reports a missing order as NotFound, and a bad status or status
+// change as an invalid argument.
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return grpcerr.NotFound("order", "", err.Error())
+	case errors.Is(err, ErrInvalidStatus), errors.Is(err, ErrInvalidTransition):
+		return grpcerr.WithDetails(status.New(codes.InvalidArgument, err.Error()), &errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "status", Description: err.Error()},
+			},
+		})
+	}
+	return nil
+}
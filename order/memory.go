@@ -0,0 +1,139 @@
+package order
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+type memoryRepository struct {
+	mu     sync.RWMutex
+	orders []Order
+}
+
+// NewMemoryRepository returns a Repository that keeps orders in memory. It is
+// meant for tests and local runs without Postgres.
+func NewMemoryRepository() Repository {
+	return &memoryRepository{}
+}
+
+func (r *memoryRepository) Close() {}
+
+func (r *memoryRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (r *memoryRepository) CreateOrder(ctx context.Context, o Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	o.Products = append([]OrderedProduct(nil), o.Products...)
+	r.orders = append(r.orders, o)
+	return nil
+}
+
+func (r *memoryRepository) GetOrdersForAccount(ctx context.Context, accountID string) ([]Order, error) {
+	return r.GetOrdersForAccounts(ctx, []string{accountID})
+}
+
+func (r *memoryRepository) GetOrdersForAccounts(ctx context.Context, accountIDs []string) ([]Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	accounts := make(map[string]bool, len(accountIDs))
+	for _, id := range accountIDs {
+		accounts[id] = true
+	}
+
+	orders := []Order{}
+	for _, o := range r.orders {
+		if !accounts[o.AccountID] {
+			continue
+		}
+		o.Products = productIDsAndQuantities(o.Products)
+		orders = append(orders, o)
+	}
+
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+	return orders, nil
+}
+
+func (r *memoryRepository) GetOrder(ctx context.Context, id string) (*Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, o := range r.orders {
+		if o.ID == id {
+			o.Products = productIDsAndQuantities(o.Products)
+			return &o, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryRepository) ListOrdersByIDs(ctx context.Context, ids []string) ([]Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	orders := []Order{}
+	for _, o := range r.orders {
+		if wanted[o.ID] {
+			o.Products = productIDsAndQuantities(o.Products)
+			orders = append(orders, o)
+		}
+	}
+
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+	return orders, nil
+}
+
+func (r *memoryRepository) GetOrdersForProduct(ctx context.Context, productID string, skip uint64, take uint64) ([]Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := []Order{}
+	for _, o := range r.orders {
+		for _, p := range o.Products {
+			if p.ID == productID {
+				o.Products = productIDsAndQuantities(o.Products)
+				all = append(all, o)
+				break
+			}
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+
+	orders := []Order{}
+	for i := skip; i < uint64(len(all)) && i-skip < take; i++ {
+		orders = append(orders, all[i])
+	}
+	return orders, nil
+}
+
+func (r *memoryRepository) UpdateOrderStatus(ctx context.Context, id string, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.orders {
+		if r.orders[i].ID == id {
+			r.orders[i].Status = status
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// productIDsAndQuantities strips products down to what the Postgres
+// repository stores; details are filled in from the catalog by the server.
+func productIDsAndQuantities(products []OrderedProduct) []OrderedProduct {
+	stripped := make([]OrderedProduct, len(products))
+	for i, p := range products {
+		stripped[i] = OrderedProduct{ID: p.ID, Quantity: p.Quantity}
+	}
+	return stripped
+}
@@ -0,0 +1,14 @@
+package order_test
+
+import (
+	"testing"
+
+	"github.com/valkyraycho/go-microservices/order"
+	"github.com/valkyraycho/go-microservices/order/ordertest"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	ordertest.RunRepositoryTests(t, func(t *testing.T) order.Repository {
+		return order.NewMemoryRepository()
+	})
+}
@@ -0,0 +1,18 @@
+package order
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	ordersPlaced = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "orders_placed_total",
+		Help: "Number of orders placed.",
+	})
+	orderValue = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "order_value",
+		Help:    "Total price of the orders placed.",
+		Buckets: prometheus.ExponentialBuckets(10, 2, 12),
+	})
+)
@@ -0,0 +1,13 @@
+package order
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrations holds the versioned schema of the Postgres repository, which
+// the service applies with the migrate package.
+var Migrations, _ = fs.Sub(migrations, "migrations")
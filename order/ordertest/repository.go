@@ -0,0 +1,246 @@
+// Package ordertest provides a contract test suite that every
+// order.Repository implementation must pass.
+package ordertest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/valkyraycho/go-microservices/order"
+)
+
+// RunRepositoryTests runs the contract suite against repositories returned by
+// newRepository, which must return an empty repository on every call.
+func RunRepositoryTests(t *testing.T, newRepository func(t *testing.T) order.Repository) {
+	t.Run("Ping", func(t *testing.T) {
+		r := newRepository(t)
+
+		if err := r.Ping(context.Background()); err != nil {
+			t.Errorf("Ping: %v", err)
+		}
+	})
+
+	t.Run("GetOrdersForAccount", func(t *testing.T) {
+		r := newRepository(t)
+		ctx := context.Background()
+
+		accountID := ksuid.New().String()
+		first := newOrder(accountID, 2)
+		second := newOrder(accountID, 1)
+		other := newOrder(ksuid.New().String(), 1)
+
+		for _, o := range []order.Order{second, other, first} {
+			if err := r.CreateOrder(ctx, o); err != nil {
+				t.Fatalf("CreateOrder: %v", err)
+			}
+		}
+
+		orders, err := r.GetOrdersForAccount(ctx, accountID)
+		if err != nil {
+			t.Fatalf("GetOrdersForAccount: %v", err)
+		}
+
+		// Orders of other accounts are left out and the rest are sorted by id.
+		want := []order.Order{first, second}
+		slices.SortFunc(want, func(a, b order.Order) int { return strings.Compare(a.ID, b.ID) })
+
+		if len(orders) != len(want) {
+			t.Fatalf("GetOrdersForAccount returned %d orders, want %d", len(orders), len(want))
+		}
+		for i := range want {
+			assertOrder(t, orders[i], want[i])
+		}
+	})
+
+	t.Run("GetOrdersForAccounts", func(t *testing.T) {
+		r := newRepository(t)
+		ctx := context.Background()
+
+		first, second := ksuid.New().String(), ksuid.New().String()
+		orders := []order.Order{newOrder(first, 1), newOrder(second, 2), newOrder(first, 1), newOrder(ksuid.New().String(), 1)}
+
+		for _, o := range orders {
+			if err := r.CreateOrder(ctx, o); err != nil {
+				t.Fatalf("CreateOrder: %v", err)
+			}
+		}
+
+		got, err := r.GetOrdersForAccounts(ctx, []string{first, second, ksuid.New().String()})
+		if err != nil {
+			t.Fatalf("GetOrdersForAccounts: %v", err)
+		}
+
+		want := slices.Clone(orders[:3])
+		slices.SortFunc(want, func(a, b order.Order) int { return strings.Compare(a.ID, b.ID) })
+
+		if len(got) != len(want) {
+			t.Fatalf("GetOrdersForAccounts returned %d orders, want %d", len(got), len(want))
+		}
+		for i := range want {
+			assertOrder(t, got[i], want[i])
+		}
+	})
+
+	t.Run("GetOrder", func(t *testing.T) {
+		r := newRepository(t)
+		ctx := context.Background()
+
+		want := newOrder(ksuid.New().String(), 2)
+		if err := r.CreateOrder(ctx, want); err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+
+		got, err := r.GetOrder(ctx, want.ID)
+		if err != nil {
+			t.Fatalf("GetOrder: %v", err)
+		}
+		assertOrder(t, *got, want)
+
+		if _, err := r.GetOrder(ctx, ksuid.New().String()); !errors.Is(err, order.ErrNotFound) {
+			t.Errorf("GetOrder error = %v, want %v", err, order.ErrNotFound)
+		}
+	})
+
+	t.Run("ListOrdersByIDs", func(t *testing.T) {
+		r := newRepository(t)
+		ctx := context.Background()
+
+		orders := []order.Order{newOrder(ksuid.New().String(), 1), newOrder(ksuid.New().String(), 2), newOrder(ksuid.New().String(), 1)}
+		for _, o := range orders {
+			if err := r.CreateOrder(ctx, o); err != nil {
+				t.Fatalf("CreateOrder: %v", err)
+			}
+		}
+
+		got, err := r.ListOrdersByIDs(ctx, []string{orders[2].ID, ksuid.New().String(), orders[0].ID})
+		if err != nil {
+			t.Fatalf("ListOrdersByIDs: %v", err)
+		}
+
+		want := []order.Order{orders[0], orders[2]}
+		slices.SortFunc(want, func(a, b order.Order) int { return strings.Compare(a.ID, b.ID) })
+
+		if len(got) != len(want) {
+			t.Fatalf("ListOrdersByIDs returned %d orders, want %d", len(got), len(want))
+		}
+		for i := range want {
+			assertOrder(t, got[i], want[i])
+		}
+	})
+
+	t.Run("UpdateOrderStatus", func(t *testing.T) {
+		r := newRepository(t)
+		ctx := context.Background()
+
+		want := newOrder(ksuid.New().String(), 1)
+		if err := r.CreateOrder(ctx, want); err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+
+		if err := r.UpdateOrderStatus(ctx, want.ID, order.StatusShipped); err != nil {
+			t.Fatalf("UpdateOrderStatus: %v", err)
+		}
+		want.Status = order.StatusShipped
+
+		got, err := r.GetOrder(ctx, want.ID)
+		if err != nil {
+			t.Fatalf("GetOrder: %v", err)
+		}
+		assertOrder(t, *got, want)
+
+		if err := r.UpdateOrderStatus(ctx, ksuid.New().String(), order.StatusShipped); !errors.Is(err, order.ErrNotFound) {
+			t.Errorf("UpdateOrderStatus error = %v, want %v", err, order.ErrNotFound)
+		}
+	})
+
+	t.Run("GetOrdersForProduct", func(t *testing.T) {
+		r := newRepository(t)
+		ctx := context.Background()
+
+		productID := ksuid.New().String()
+		orders := []order.Order{}
+		for i := 0; i < 4; i++ {
+			o := newOrder(ksuid.New().String(), 2)
+			if i != 2 {
+				o.Products[1].ID = productID
+				orders = append(orders, o)
+			}
+			if err := r.CreateOrder(ctx, o); err != nil {
+				t.Fatalf("CreateOrder: %v", err)
+			}
+		}
+		slices.SortFunc(orders, func(a, b order.Order) int { return strings.Compare(a.ID, b.ID) })
+
+		for _, page := range []struct{ skip, take uint64 }{{0, 10}, {1, 1}, {3, 10}} {
+			got, err := r.GetOrdersForProduct(ctx, productID, page.skip, page.take)
+			if err != nil {
+				t.Fatalf("GetOrdersForProduct: %v", err)
+			}
+
+			want := orders[min(page.skip, 3):min(page.skip+page.take, 3)]
+			if len(got) != len(want) {
+				t.Fatalf("GetOrdersForProduct(%d, %d) returned %d orders, want %d", page.skip, page.take, len(got), len(want))
+			}
+			for i := range want {
+				assertOrder(t, got[i], want[i])
+			}
+		}
+	})
+
+	t.Run("GetOrdersForAccountEmpty", func(t *testing.T) {
+		r := newRepository(t)
+
+		orders, err := r.GetOrdersForAccount(context.Background(), ksuid.New().String())
+		if err != nil {
+			t.Fatalf("GetOrdersForAccount: %v", err)
+		}
+		if orders == nil || len(orders) != 0 {
+			t.Errorf("GetOrdersForAccount = %v, want an empty slice", orders)
+		}
+	})
+}
+
+func newOrder(accountID string, products int) order.Order {
+	o := order.Order{
+		ID:         ksuid.New().String(),
+		CreatedAt:  time.Now().UTC().Truncate(time.Microsecond),
+		AccountID:  accountID,
+		TotalPrice: 12.5,
+		Status:     order.StatusPending,
+	}
+	for i := 0; i < products; i++ {
+		o.Products = append(o.Products, order.OrderedProduct{ID: ksuid.New().String(), Quantity: uint32(i + 1)})
+	}
+	return o
+}
+
+// assertOrder compares the fields a repository stores; product details are
+// filled in from the catalog and not expected back.
+func assertOrder(t *testing.T, got order.Order, want order.Order) {
+	t.Helper()
+
+	if got.ID != want.ID || got.AccountID != want.AccountID || got.TotalPrice != want.TotalPrice || got.Status != want.Status {
+		t.Errorf("order = %+v, want %+v", got, want)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) {
+		t.Errorf("order %s created at %v, want %v", want.ID, got.CreatedAt, want.CreatedAt)
+	}
+
+	quantities := map[string]uint32{}
+	for _, p := range got.Products {
+		quantities[p.ID] = p.Quantity
+	}
+	if len(quantities) != len(want.Products) {
+		t.Errorf("order %s has %d products, want %d", want.ID, len(got.Products), len(want.Products))
+	}
+	for _, p := range want.Products {
+		if quantities[p.ID] != p.Quantity {
+			t.Errorf("order %s product %s quantity = %d, want %d", want.ID, p.ID, quantities[p.ID], p.Quantity)
+		}
+	}
+}
@@ -19,8 +19,13 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	OrderService_PostOrder_FullMethodName           = "/order_service.OrderService/PostOrder"
-	OrderService_GetOrdersForAccount_FullMethodName = "/order_service.OrderService/GetOrdersForAccount"
+	OrderService_PostOrder_FullMethodName            = "/order_service.OrderService/PostOrder"
+	OrderService_GetOrdersForAccount_FullMethodName  = "/order_service.OrderService/GetOrdersForAccount"
+	OrderService_GetOrdersForAccounts_FullMethodName = "/order_service.OrderService/GetOrdersForAccounts"
+	OrderService_UpdateOrderStatus_FullMethodName    = "/order_service.OrderService/UpdateOrderStatus"
+	OrderService_WatchOrders_FullMethodName          = "/order_service.OrderService/WatchOrders"
+	OrderService_GetOrdersForProduct_FullMethodName  = "/order_service.OrderService/GetOrdersForProduct"
+	OrderService_GetOrdersByIDs_FullMethodName       = "/order_service.OrderService/GetOrdersByIDs"
 )
 
 // OrderServiceClient is the client API for OrderService service.
@@ -29,6 +34,18 @@ const (
 type OrderServiceClient interface {
 	PostOrder(ctx context.Context, in *PostOrderRequest, opts ...grpc.CallOption) (*PostOrderResponse, error)
 	GetOrdersForAccount(ctx context.Context, in *GetOrdersForAccountRequest, opts ...grpc.CallOption) (*GetOrdersForAccountResponse, error)
+	// GetOrdersForAccounts is only used to batch lookups and has no REST
+	// mapping.
+	GetOrdersForAccounts(ctx context.Context, in *GetOrdersForAccountsRequest, opts ...grpc.CallOption) (*GetOrdersForAccountsResponse, error)
+	// UpdateOrderStatus and WatchOrders reach the orders of every account,
+	// and the REST gateway authenticates nobody, so they are not published
+	// over REST.
+	UpdateOrderStatus(ctx context.Context, in *UpdateOrderStatusRequest, opts ...grpc.CallOption) (*UpdateOrderStatusResponse, error)
+	WatchOrders(ctx context.Context, in *WatchOrdersRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Order], error)
+	// GetOrdersForProduct is admin-only in the GraphQL API, so it is not
+	// published over REST.
+	GetOrdersForProduct(ctx context.Context, in *GetOrdersForProductRequest, opts ...grpc.CallOption) (*GetOrdersForProductResponse, error)
+	GetOrdersByIDs(ctx context.Context, in *GetOrdersByIDsRequest, opts ...grpc.CallOption) (*GetOrdersByIDsResponse, error)
 }
 
 type orderServiceClient struct {
@@ -59,12 +76,83 @@ func (c *orderServiceClient) GetOrdersForAccount(ctx context.Context, in *GetOrd
 	return out, nil
 }
 
+func (c *orderServiceClient) GetOrdersForAccounts(ctx context.Context, in *GetOrdersForAccountsRequest, opts ...grpc.CallOption) (*GetOrdersForAccountsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetOrdersForAccountsResponse)
+	err := c.cc.Invoke(ctx, OrderService_GetOrdersForAccounts_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderServiceClient) UpdateOrderStatus(ctx context.Context, in *UpdateOrderStatusRequest, opts ...grpc.CallOption) (*UpdateOrderStatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateOrderStatusResponse)
+	err := c.cc.Invoke(ctx, OrderService_UpdateOrderStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderServiceClient) WatchOrders(ctx context.Context, in *WatchOrdersRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Order], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &OrderService_ServiceDesc.Streams[0], OrderService_WatchOrders_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchOrdersRequest, Order]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type OrderService_WatchOrdersClient = grpc.ServerStreamingClient[Order]
+
+func (c *orderServiceClient) GetOrdersForProduct(ctx context.Context, in *GetOrdersForProductRequest, opts ...grpc.CallOption) (*GetOrdersForProductResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetOrdersForProductResponse)
+	err := c.cc.Invoke(ctx, OrderService_GetOrdersForProduct_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orderServiceClient) GetOrdersByIDs(ctx context.Context, in *GetOrdersByIDsRequest, opts ...grpc.CallOption) (*GetOrdersByIDsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetOrdersByIDsResponse)
+	err := c.cc.Invoke(ctx, OrderService_GetOrdersByIDs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // OrderServiceServer is the server API for OrderService service.
 // All implementations must embed UnimplementedOrderServiceServer
 // for forward compatibility.
 type OrderServiceServer interface {
 	PostOrder(context.Context, *PostOrderRequest) (*PostOrderResponse, error)
 	GetOrdersForAccount(context.Context, *GetOrdersForAccountRequest) (*GetOrdersForAccountResponse, error)
+	// GetOrdersForAccounts is only used to batch lookups and has no REST
+	// mapping.
+	GetOrdersForAccounts(context.Context, *GetOrdersForAccountsRequest) (*GetOrdersForAccountsResponse, error)
+	// UpdateOrderStatus and WatchOrders reach the orders of every account,
+	// and the REST gateway authenticates nobody, so they are not published
+	// over REST.
+	UpdateOrderStatus(context.Context, *UpdateOrderStatusRequest) (*UpdateOrderStatusResponse, error)
+	WatchOrders(*WatchOrdersRequest, grpc.ServerStreamingServer[Order]) error
+	// GetOrdersForProduct is admin-only in the GraphQL API, so it is not
+	// published over REST.
+	GetOrdersForProduct(context.Context, *GetOrdersForProductRequest) (*GetOrdersForProductResponse, error)
+	GetOrdersByIDs(context.Context, *GetOrdersByIDsRequest) (*GetOrdersByIDsResponse, error)
 	mustEmbedUnimplementedOrderServiceServer()
 }
 
@@ -81,6 +169,21 @@ func (UnimplementedOrderServiceServer) PostOrder(context.Context, *PostOrderRequ
 func (UnimplementedOrderServiceServer) GetOrdersForAccount(context.Context, *GetOrdersForAccountRequest) (*GetOrdersForAccountResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetOrdersForAccount not implemented")
 }
+func (UnimplementedOrderServiceServer) GetOrdersForAccounts(context.Context, *GetOrdersForAccountsRequest) (*GetOrdersForAccountsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOrdersForAccounts not implemented")
+}
+func (UnimplementedOrderServiceServer) UpdateOrderStatus(context.Context, *UpdateOrderStatusRequest) (*UpdateOrderStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateOrderStatus not implemented")
+}
+func (UnimplementedOrderServiceServer) WatchOrders(*WatchOrdersRequest, grpc.ServerStreamingServer[Order]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchOrders not implemented")
+}
+func (UnimplementedOrderServiceServer) GetOrdersForProduct(context.Context, *GetOrdersForProductRequest) (*GetOrdersForProductResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOrdersForProduct not implemented")
+}
+func (UnimplementedOrderServiceServer) GetOrdersByIDs(context.Context, *GetOrdersByIDsRequest) (*GetOrdersByIDsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOrdersByIDs not implemented")
+}
 func (UnimplementedOrderServiceServer) mustEmbedUnimplementedOrderServiceServer() {}
 func (UnimplementedOrderServiceServer) testEmbeddedByValue()                      {}
 
@@ -138,6 +241,89 @@ func _OrderService_GetOrdersForAccount_Handler(srv interface{}, ctx context.Cont
 	return interceptor(ctx, in, info, handler)
 }
 
+func _OrderService_GetOrdersForAccounts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrdersForAccountsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderServiceServer).GetOrdersForAccounts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderService_GetOrdersForAccounts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderServiceServer).GetOrdersForAccounts(ctx, req.(*GetOrdersForAccountsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderService_UpdateOrderStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateOrderStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderServiceServer).UpdateOrderStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderService_UpdateOrderStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderServiceServer).UpdateOrderStatus(ctx, req.(*UpdateOrderStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderService_WatchOrders_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchOrdersRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OrderServiceServer).WatchOrders(m, &grpc.GenericServerStream[WatchOrdersRequest, Order]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type OrderService_WatchOrdersServer = grpc.ServerStreamingServer[Order]
+
+func _OrderService_GetOrdersForProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrdersForProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderServiceServer).GetOrdersForProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderService_GetOrdersForProduct_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderServiceServer).GetOrdersForProduct(ctx, req.(*GetOrdersForProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrderService_GetOrdersByIDs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrdersByIDsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrderServiceServer).GetOrdersByIDs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrderService_GetOrdersByIDs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrderServiceServer).GetOrdersByIDs(ctx, req.(*GetOrdersByIDsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // OrderService_ServiceDesc is the grpc.ServiceDesc for OrderService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -153,7 +339,29 @@ var OrderService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetOrdersForAccount",
 			Handler:    _OrderService_GetOrdersForAccount_Handler,
 		},
+		{
+			MethodName: "GetOrdersForAccounts",
+			Handler:    _OrderService_GetOrdersForAccounts_Handler,
+		},
+		{
+			MethodName: "UpdateOrderStatus",
+			Handler:    _OrderService_UpdateOrderStatus_Handler,
+		},
+		{
+			MethodName: "GetOrdersForProduct",
+			Handler:    _OrderService_GetOrdersForProduct_Handler,
+		},
+		{
+			MethodName: "GetOrdersByIDs",
+			Handler:    _OrderService_GetOrdersByIDs_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchOrders",
+			Handler:       _OrderService_WatchOrders_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/order.proto",
 }
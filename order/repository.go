@@ -3,22 +3,38 @@ package order
 import (
 	"context"
 	"database/sql"
+	"errors"
 
+	"github.com/XSAM/otelsql"
 	"github.com/lib/pq"
+	"github.com/valkyraycho/go-microservices/telemetry"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 type Repository interface {
 	Close()
+	// Ping returns an error when the store cannot be reached.
+	Ping(ctx context.Context) error
 	CreateOrder(ctx context.Context, o Order) error
 	GetOrdersForAccount(ctx context.Context, accoundID string) ([]Order, error)
+	GetOrdersForAccounts(ctx context.Context, accountIDs []string) ([]Order, error)
+	GetOrder(ctx context.Context, id string) (*Order, error)
+	ListOrdersByIDs(ctx context.Context, ids []string) ([]Order, error)
+	// GetOrdersForProduct returns a page of the orders that contain the
+	// product, sorted by id.
+	GetOrdersForProduct(ctx context.Context, productID string, skip uint64, take uint64) ([]Order, error)
+	UpdateOrderStatus(ctx context.Context, id string, status string) error
 }
 
+var ErrNotFound = errors.New("order not found")
+
 type postgresRepository struct {
-	db *sql.DB
+	db                *sql.DB
+	unregisterDBStats func()
 }
 
 func NewPostgresRepository(url string) (Repository, error) {
-	db, err := sql.Open("postgres", url)
+	db, err := otelsql.Open("postgres", url, otelsql.WithAttributes(semconv.DBSystemPostgreSQL))
 	if err != nil {
 		return nil, err
 	}
@@ -28,15 +44,16 @@ func NewPostgresRepository(url string) (Repository, error) {
 		return nil, err
 	}
 
-	return &postgresRepository{db}, nil
+	return &postgresRepository{db, telemetry.RegisterDBStats(db, "order")}, nil
 }
 
 func (r *postgresRepository) Close() {
+	r.unregisterDBStats()
 	r.db.Close()
 }
 
-func (r *postgresRepository) Ping() error {
-	return r.db.Ping()
+func (r *postgresRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
 }
 
 func (r *postgresRepository) CreateOrder(ctx context.Context, o Order) error {
@@ -56,11 +73,12 @@ func (r *postgresRepository) CreateOrder(ctx context.Context, o Order) error {
 
 	_, err = tx.ExecContext(
 		ctx,
-		"INSERT INTO orders(id, created_at, account_id, total_price) VALUES($1, $2, $3, $4)",
+		"INSERT INTO orders(id, created_at, account_id, total_price, status) VALUES($1, $2, $3, $4, $5)",
 		o.ID,
 		o.CreatedAt,
 		o.AccountID,
 		o.TotalPrice,
+		o.Status,
 	)
 
 	if err != nil {
@@ -89,19 +107,71 @@ func (r *postgresRepository) CreateOrder(ctx context.Context, o Order) error {
 }
 
 func (r *postgresRepository) GetOrdersForAccount(ctx context.Context, accoundID string) ([]Order, error) {
+	return r.GetOrdersForAccounts(ctx, []string{accoundID})
+}
+
+func (r *postgresRepository) GetOrdersForAccounts(ctx context.Context, accountIDs []string) ([]Order, error) {
+	return r.queryOrders(ctx, "o.account_id = ANY($1)", pq.Array(accountIDs))
+}
+
+func (r *postgresRepository) GetOrder(ctx context.Context, id string) (*Order, error) {
+	orders, err := r.queryOrders(ctx, "o.id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, ErrNotFound
+	}
+	return &orders[0], nil
+}
+
+func (r *postgresRepository) ListOrdersByIDs(ctx context.Context, ids []string) ([]Order, error) {
+	return r.queryOrders(ctx, "o.id = ANY($1)", pq.Array(ids))
+}
+
+func (r *postgresRepository) GetOrdersForProduct(ctx context.Context, productID string, skip uint64, take uint64) ([]Order, error) {
+	return r.queryOrders(ctx, `o.id IN (
+		SELECT order_id FROM order_products
+		WHERE product_id = $1
+		ORDER BY order_id
+		OFFSET $2 LIMIT $3)`,
+		productID, skip, take,
+	)
+}
+
+func (r *postgresRepository) UpdateOrderStatus(ctx context.Context, id string, status string) error {
+	res, err := r.db.ExecContext(ctx, "UPDATE orders SET status = $2 WHERE id = $1", id, status)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// queryOrders returns the orders matching where, which is a condition on the
+// orders table aliased as o, along with their products.
+func (r *postgresRepository) queryOrders(ctx context.Context, where string, args ...any) ([]Order, error) {
 	rows, err := r.db.QueryContext(ctx, `
 		SELECT 
 		o.id, 
 		o.created_at, 
 		o.account_id, 
 		o.total_price::money::numeric::float8,	   
+		o.status,
 		op.product_id, 
 		op.quantity
 		FROM orders o
 		JOIN order_products op ON o.id = op.order_id
-		WHERE o.account_id = $1
+		WHERE `+where+`
 		ORDER BY o.id`,
-		accoundID,
+		args...,
 	)
 	if err != nil {
 		return nil, err
@@ -121,6 +191,7 @@ func (r *postgresRepository) GetOrdersForAccount(ctx context.Context, accoundID
 			&order.CreatedAt,
 			&order.AccountID,
 			&order.TotalPrice,
+			&order.Status,
 			&orderedProduct.ID,
 			&orderedProduct.Quantity,
 		); err != nil {
@@ -133,6 +204,7 @@ func (r *postgresRepository) GetOrdersForAccount(ctx context.Context, accoundID
 				CreatedAt:  lastOrder.CreatedAt,
 				TotalPrice: lastOrder.TotalPrice,
 				AccountID:  lastOrder.AccountID,
+				Status:     lastOrder.Status,
 				Products:   products,
 			})
 			products = []OrderedProduct{}
@@ -148,6 +220,7 @@ func (r *postgresRepository) GetOrdersForAccount(ctx context.Context, accoundID
 			CreatedAt:  lastOrder.CreatedAt,
 			TotalPrice: lastOrder.TotalPrice,
 			AccountID:  lastOrder.AccountID,
+			Status:     lastOrder.Status,
 			Products:   products,
 		})
 	}
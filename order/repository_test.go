@@ -0,0 +1,109 @@
+package order_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/valkyraycho/go-microservices/migrate"
+	"github.com/valkyraycho/go-microservices/order"
+	"github.com/valkyraycho/go-microservices/order/ordertest"
+)
+
+// TestPostgresRepository runs against the database in TEST_DATABASE_URL and
+// truncates the order tables, so it must not point at real data.
+func TestPostgresRepository(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := migrate.Up(context.Background(), url, order.Migrations); err != nil {
+		t.Fatal(err)
+	}
+
+	ordertest.RunRepositoryTests(t, func(t *testing.T) order.Repository {
+		if _, err := db.Exec("TRUNCATE orders, order_products"); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := order.NewPostgresRepository(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(r.Close)
+		return r
+	})
+}
+
+// baselineSchema is the order schema databases were created with before
+// there were migrations, when they were only set up by up.sql.
+const baselineSchema = `
+CREATE TABLE orders (
+  id CHAR(27) PRIMARY KEY,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  account_id CHAR(27) NOT NULL,
+  total_price MONEY NOT NULL
+);
+
+CREATE TABLE order_products (
+  order_id CHAR(27) REFERENCES orders (id) ON DELETE CASCADE,
+  product_id CHAR(27),
+  quantity INT NOT NULL,
+  PRIMARY KEY (product_id, order_id)
+);`
+
+// TestMigrationsUpgradeBaseline migrates a database that has the baseline
+// schema but no migration history, as existing volumes do. It drops the order
+// tables in TEST_DATABASE_URL.
+func TestMigrationsUpgradeBaseline(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("DROP TABLE IF EXISTS order_products, orders, schema_migrations"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(baselineSchema); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO orders(id, created_at, account_id, total_price) VALUES('2aVHnrnzIkOZqYLs0tcvF1PGWAv', now(), '2aVHnrnzIkOZqYLs0tcvF1PGWAw', 10)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrate.Up(context.Background(), url, order.Migrations); err != nil {
+		t.Fatal(err)
+	}
+
+	var status string
+	if err := db.QueryRow("SELECT status FROM orders").Scan(&status); err != nil {
+		t.Fatalf("reading the status of an existing order: %v", err)
+	}
+	if status != "PENDING" {
+		t.Errorf("status of an existing order = %q, want PENDING", status)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	migrations, err := migrate.Load(order.Migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Error("no migrations are embedded")
+	}
+}
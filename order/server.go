@@ -2,16 +2,24 @@ package order
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
+	"time"
 
 	"github.com/valkyraycho/go-microservices/account"
 	"github.com/valkyraycho/go-microservices/catalog"
+	"github.com/valkyraycho/go-microservices/graceful"
+	"github.com/valkyraycho/go-microservices/grpcerr"
+	"github.com/valkyraycho/go-microservices/health"
 	pb "github.com/valkyraycho/go-microservices/order/proto"
+	"github.com/valkyraycho/go-microservices/telemetry"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
 type orderServer struct {
@@ -21,68 +29,97 @@ type orderServer struct {
 	catalogClient *catalog.Client
 }
 
-func ListenGRPC(s Service, accountServiceURL string, catalogServiceURL string, port int) error {
-	accountClient, err := account.NewClient(accountServiceURL)
-	if err != nil {
-		return err
-	}
-
-	catalogClient, err := catalog.NewClient(catalogServiceURL)
-	if err != nil {
-		accountClient.Close()
-		return err
-	}
-
+// ListenGRPC serves s on port until ctx is done, then waits up to
+// shutdownTimeout for the calls in flight to finish. The clients and options
+// are passed to NewGRPCServer.
+func ListenGRPC(ctx context.Context, s Service, accountClient *account.Client, catalogClient *catalog.Client, port int, shutdownTimeout time.Duration, opts ...grpc.ServerOption) error {
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
-		accountClient.Close()
-		catalogClient.Close()
 		return err
 	}
 
-	server := grpc.NewServer()
+	return graceful.ServeGRPC(ctx, NewGRPCServer(s, accountClient, catalogClient, opts...), lis, shutdownTimeout)
+}
+
+// NewGRPCServer returns a gRPC server with the order service registered, ready
+// to serve on any listener. The clients are used to look up accounts and
+// products and are not closed by the server. Its health service reports
+// SERVING while the store and both services can be reached. Extra options,
+// such as the transport credentials, are applied after the defaults.
+func NewGRPCServer(s Service, accountClient *account.Client, catalogClient *catalog.Client, opts ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(append([]grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(telemetry.ServerMetrics.UnaryServerInterceptor(), telemetry.UnaryServerLogging, grpcerr.UnaryServerInterceptor(statusError)),
+		grpc.ChainStreamInterceptor(telemetry.ServerMetrics.StreamServerInterceptor(), telemetry.StreamServerLogging, grpcerr.StreamServerInterceptor(statusError)),
+	}, opts...)...)
 	pb.RegisterOrderServiceServer(server, &orderServer{service: s, accountClient: accountClient, catalogClient: catalogClient})
+	health.Register(server, health.Checks{
+		"store":   s.Ping,
+		"account": accountClient.Ping,
+		"catalog": catalogClient.Ping,
+	})
 	reflection.Register(server)
-	return server.Serve(lis)
+	telemetry.ServerMetrics.InitializeMetrics(server)
+	return server
 }
 
 func (s *orderServer) PostOrder(ctx context.Context, r *pb.PostOrderRequest) (*pb.PostOrderResponse, error) {
-	_, err := s.accountClient.GetAccount(ctx, r.AccountId)
-	if err != nil {
-		log.Println("Error while finding account: ", err)
-		return nil, errors.New("account not found")
-	}
-
+	orderedProducts := make([]OrderedProduct, len(r.Products))
 	productIDs := make([]string, len(r.Products))
-	quantities := make(map[string]uint32, len(r.Products))
-
 	for i, p := range r.Products {
+		orderedProducts[i] = OrderedProduct{ID: p.ProductId, Quantity: p.Quantity}
 		productIDs[i] = p.ProductId
-		quantities[p.ProductId] = p.Quantity
 	}
 
-	products, err := s.catalogClient.GetProducts(ctx, 0, 0, productIDs, "")
+	// Check the request itself before asking other services about it.
+	if err := validateOrder(r.AccountId, orderedProducts).Err(); err != nil {
+		return nil, err
+	}
+
+	_, err := s.accountClient.GetAccount(ctx, r.AccountId)
+	if status.Code(err) == codes.NotFound {
+		return nil, grpcerr.NotFound("account", r.AccountId, "account not found")
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "finding the account", "err", err)
+		return nil, grpcerr.Redact(err)
+	}
+
+	// A cached product may have been archived since, so the products are
+	// fetched from the catalog.
+	products, err := s.catalogClient.FetchProducts(ctx, productIDs)
 	if err != nil {
-		log.Println("Error while getting products: ", err)
-		return nil, errors.New("products not found")
+		slog.ErrorContext(ctx, "getting the products", "err", err)
+		return nil, grpcerr.Redact(err)
 	}
 
-	orderedProducts := make([]OrderedProduct, 0, len(products))
+	byID := make(map[string]catalog.Product, len(products))
 	for _, p := range products {
-		if quantity := quantities[p.ID]; quantity > 0 {
-			orderedProducts = append(orderedProducts, OrderedProduct{
-				ID:          p.ID,
-				Quantity:    quantity,
-				Name:        p.Name,
-				Description: p.Description,
-				Price:       p.Price,
-			})
+		byID[p.ID] = p
+	}
+
+	var v grpcerr.Violations
+	for i, op := range orderedProducts {
+		p, ok := byID[op.ID]
+		if !ok {
+			v.Add(fmt.Sprintf("products[%d].id", i), fmt.Sprintf("product %s not found", op.ID))
+			continue
+		}
+		if p.Archived {
+			v.Add(fmt.Sprintf("products[%d].id", i), fmt.Sprintf("product %s is archived", op.ID))
+			continue
 		}
+		orderedProducts[i].Name = p.Name
+		orderedProducts[i].Description = p.Description
+		orderedProducts[i].Price = p.Price
 	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
 	order, err := s.service.PostOrder(ctx, r.AccountId, orderedProducts)
 	if err != nil {
-		log.Println("Error posting order: ", err)
-		return nil, errors.New("could not post order")
+		return nil, err
 	}
 
 	pbOrderedProducts := make([]*pb.Order_OrderProduct, len(order.Products))
@@ -97,7 +134,7 @@ func (s *orderServer) PostOrder(ctx context.Context, r *pb.PostOrderRequest) (*p
 	}
 	createdAt, err := order.CreatedAt.MarshalBinary()
 	if err != nil {
-		log.Printf("Error marshaling timestamp: %v", err)
+		slog.ErrorContext(ctx, "marshaling the timestamp", "err", err)
 		return nil, fmt.Errorf("invalid timestamp: %w", err)
 	}
 	return &pb.PostOrderResponse{
@@ -107,6 +144,7 @@ func (s *orderServer) PostOrder(ctx context.Context, r *pb.PostOrderRequest) (*p
 			TotalPrice: order.TotalPrice,
 			CreatedAt:  createdAt,
 			Products:   pbOrderedProducts,
+			Status:     order.Status,
 		},
 	}, nil
 }
@@ -114,9 +152,93 @@ func (s *orderServer) PostOrder(ctx context.Context, r *pb.PostOrderRequest) (*p
 func (s *orderServer) GetOrdersForAccount(ctx context.Context, r *pb.GetOrdersForAccountRequest) (*pb.GetOrdersForAccountResponse, error) {
 	accountOrders, err := s.service.GetOrdersForAccount(ctx, r.AccountId)
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
+
+	orders, err := s.ordersWithProducts(ctx, accountOrders)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetOrdersForAccountResponse{Orders: orders}, nil
+}
+
+func (s *orderServer) GetOrdersForAccounts(ctx context.Context, r *pb.GetOrdersForAccountsRequest) (*pb.GetOrdersForAccountsResponse, error) {
+	accountOrders, err := s.service.GetOrdersForAccounts(ctx, r.AccountIds)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := s.ordersWithProducts(ctx, accountOrders)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetOrdersForAccountsResponse{Orders: orders}, nil
+}
+
+func (s *orderServer) GetOrdersByIDs(ctx context.Context, r *pb.GetOrdersByIDsRequest) (*pb.GetOrdersByIDsResponse, error) {
+	found, err := s.service.GetOrdersByIDs(ctx, r.Ids)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := s.ordersWithProducts(ctx, found)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetOrdersByIDsResponse{Orders: orders}, nil
+}
+
+func (s *orderServer) GetOrdersForProduct(ctx context.Context, r *pb.GetOrdersForProductRequest) (*pb.GetOrdersForProductResponse, error) {
+	productOrders, err := s.service.GetOrdersForProduct(ctx, r.ProductId, r.Skip, r.Take)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := s.ordersWithProducts(ctx, productOrders)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetOrdersForProductResponse{Orders: orders}, nil
+}
+
+func (s *orderServer) UpdateOrderStatus(ctx context.Context, r *pb.UpdateOrderStatusRequest) (*pb.UpdateOrderStatusResponse, error) {
+	o, err := s.service.UpdateOrderStatus(ctx, r.Id, r.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := s.ordersWithProducts(ctx, []Order{*o})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.UpdateOrderStatusResponse{Order: orders[0]}, nil
+}
+
+func (s *orderServer) WatchOrders(r *pb.WatchOrdersRequest, stream grpc.ServerStreamingServer[pb.Order]) error {
+	ctx := stream.Context()
+	watched := s.service.WatchOrders(ctx, r.OrderId, r.AccountId)
+
+	// Headers tell the client the watch is in place, so it does not miss
+	// changes made right after it subscribed.
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for o := range watched {
+		orders, err := s.ordersWithProducts(ctx, []Order{o})
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(orders[0]); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// ordersWithProducts converts orders to their protobuf form, filling in the
+// product details from the catalog with a single lookup.
+func (s *orderServer) ordersWithProducts(ctx context.Context, accountOrders []Order) ([]*pb.Order, error) {
 	productIDMap := map[string]bool{}
 	for _, o := range accountOrders {
 		for _, p := range o.Products {
@@ -128,10 +250,15 @@ func (s *orderServer) GetOrdersForAccount(ctx context.Context, r *pb.GetOrdersFo
 		productIDs = append(productIDs, id)
 	}
 
-	products, err := s.catalogClient.GetProducts(ctx, 0, 0, productIDs, "")
-	if err != nil {
-		log.Println("Error getting account products: ", err)
-		return nil, err
+	// The details are only shown, so they may come from the cache.
+	products := []catalog.Product{}
+	if len(productIDs) > 0 {
+		var err error
+		products, err = s.catalogClient.GetProducts(ctx, 0, 0, productIDs, "")
+		if err != nil {
+			slog.ErrorContext(ctx, "getting the products of the orders", "err", err)
+			return nil, grpcerr.Redact(err)
+		}
 	}
 
 	orders := []*pb.Order{}
@@ -143,6 +270,7 @@ func (s *orderServer) GetOrdersForAccount(ctx context.Context, r *pb.GetOrdersFo
 			TotalPrice: o.TotalPrice,
 			CreatedAt:  createdAt,
 			Products:   []*pb.Order_OrderProduct{},
+			Status:     o.Status,
 		}
 
 		for _, orderProduct := range o.Products {
@@ -164,5 +292,5 @@ func (s *orderServer) GetOrdersForAccount(ctx context.Context, r *pb.GetOrdersFo
 		}
 		orders = append(orders, pbOrder)
 	}
-	return &pb.GetOrdersForAccountResponse{Orders: orders}, nil
+	return orders, nil
 }
@@ -0,0 +1,89 @@
+package order_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/valkyraycho/go-microservices/account"
+	"github.com/valkyraycho/go-microservices/catalog"
+	"github.com/valkyraycho/go-microservices/grpcerr/grpcerrtest"
+	"github.com/valkyraycho/go-microservices/order"
+	"github.com/valkyraycho/go-microservices/resilience"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// TestPostOrderRejectsProductsArchivedElsewhere archives a product through
+// another client of the catalog than the order service's, which keeps the
+// product cached unarchived.
+func TestPostOrderRejectsProductsArchivedElsewhere(t *testing.T) {
+	ctx := context.Background()
+
+	listeners := map[string]*bufconn.Listener{}
+	dialer := grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return listeners[addr].DialContext(ctx)
+	})
+	serve := func(name string, server *grpc.Server) {
+		lis := bufconn.Listen(1 << 20)
+		listeners[name] = lis
+		go server.Serve(lis)
+		t.Cleanup(server.Stop)
+	}
+
+	serve("account", account.NewGRPCServer(account.NewService(account.NewMemoryRepository())))
+	serve("catalog", catalog.NewGRPCServer(catalog.NewService(catalog.NewMemoryRepository())))
+
+	accounts, err := account.NewClient("passthrough:///account", resilience.Config{}, dialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(accounts.Close)
+
+	cached, err := catalog.NewClient("passthrough:///catalog", resilience.Config{}, dialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cached.Close)
+	cached.UseCache(catalog.NewMemoryCache(10, time.Minute))
+
+	serve("order", order.NewGRPCServer(order.NewService(order.NewMemoryRepository()), accounts, cached))
+
+	orders, err := order.NewClient("passthrough:///order", resilience.Config{}, dialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(orders.Close)
+
+	other, err := catalog.NewClient("passthrough:///catalog", resilience.Config{}, dialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(other.Close)
+
+	a, err := accounts.PostAccount(ctx, "Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := other.PostProduct(ctx, "Keyboard", "", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	products := []order.OrderedProduct{{ID: p.ID, Quantity: 1}}
+	if _, err := orders.PostOrder(ctx, a.ID, products); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := cached.GetProduct(ctx, p.ID); err != nil || got.Archived {
+		t.Fatalf("cached product = %v, %v; want it cached unarchived", got, err)
+	}
+
+	if _, err := other.ArchiveProduct(ctx, p.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = orders.PostOrder(ctx, a.ID, products)
+	if got := grpcerrtest.ViolatedFields(t, err); len(got) != 1 || got[0] != "products[0].id" {
+		t.Errorf("PostOrder of an archived product: violated fields = %v, err = %v; want products[0].id", got, err)
+	}
+}
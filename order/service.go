@@ -2,9 +2,15 @@ package order
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/segmentio/ksuid"
+	"github.com/valkyraycho/go-microservices/grpcerr"
 )
 
 type Order struct {
@@ -12,9 +18,50 @@ type Order struct {
 	CreatedAt  time.Time
 	TotalPrice float64
 	AccountID  string
+	Status     string
 	Products   []OrderedProduct
 }
 
+const (
+	StatusPending   = "PENDING"
+	StatusPaid      = "PAID"
+	StatusShipped   = "SHIPPED"
+	StatusDelivered = "DELIVERED"
+	StatusCancelled = "CANCELLED"
+)
+
+var (
+	ErrInvalidStatus     = errors.New("invalid order status")
+	ErrInvalidTransition = errors.New("invalid order status change")
+)
+
+// MaxQuantity is the largest quantity of one product an order can hold, as
+// stored in the order_products table.
+const MaxQuantity = math.MaxInt32
+
+func validStatus(status string) bool {
+	switch status {
+	case StatusPending, StatusPaid, StatusShipped, StatusDelivered, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// transitions lists the statuses an order in each status can move to. Orders
+// only move forward, and delivered or cancelled orders are final.
+var transitions = map[string][]string{
+	StatusPending: {StatusPaid, StatusCancelled},
+	StatusPaid:    {StatusShipped, StatusCancelled},
+	StatusShipped: {StatusDelivered},
+}
+
+// canTransition reports whether an order in status from can be set to status
+// to. Setting the status an order already has is allowed, so that retries
+// succeed.
+func canTransition(from string, to string) bool {
+	return from == to || slices.Contains(transitions[from], to)
+}
+
 type OrderedProduct struct {
 	ID          string
 	Name        string
@@ -24,19 +71,39 @@ type OrderedProduct struct {
 }
 
 type Service interface {
+	// PostOrder creates a pending order. Products listed more than once are
+	// merged into one line with the summed quantity, and an order needs at
+	// least one product.
 	PostOrder(ctx context.Context, accountID string, products []OrderedProduct) (*Order, error)
 	GetOrdersForAccount(ctx context.Context, accountID string) ([]Order, error)
+	GetOrdersForAccounts(ctx context.Context, accountIDs []string) ([]Order, error)
+	GetOrdersByIDs(ctx context.Context, ids []string) ([]Order, error)
+	GetOrdersForProduct(ctx context.Context, productID string, skip uint64, take uint64) ([]Order, error)
+	UpdateOrderStatus(ctx context.Context, id string, status string) (*Order, error)
+	// WatchOrders streams orders as they are created or updated, limited to
+	// one order or one account when orderID or accountID is set. The
+	// channel is closed once ctx is done.
+	WatchOrders(ctx context.Context, orderID string, accountID string) <-chan Order
+	// Ping returns an error when the store behind the service cannot be
+	// reached.
+	Ping(ctx context.Context) error
 }
 
 type orderService struct {
 	repository Repository
+	broker     *broker
 }
 
 func NewService(r Repository) Service {
-	return &orderService{r}
+	return &orderService{r, newBroker()}
 }
 
 func (s *orderService) PostOrder(ctx context.Context, accountID string, products []OrderedProduct) (*Order, error) {
+	if err := validateOrder(accountID, products).Err(); err != nil {
+		return nil, err
+	}
+	products = mergeProducts(products)
+
 	totalPrice := 0.0
 
 	for _, p := range products {
@@ -47,6 +114,7 @@ func (s *orderService) PostOrder(ctx context.Context, accountID string, products
 		ID:         ksuid.New().String(),
 		CreatedAt:  time.Now().UTC(),
 		AccountID:  accountID,
+		Status:     StatusPending,
 		Products:   products,
 		TotalPrice: totalPrice,
 	}
@@ -54,8 +122,108 @@ func (s *orderService) PostOrder(ctx context.Context, accountID string, products
 	if err := s.repository.CreateOrder(ctx, order); err != nil {
 		return nil, err
 	}
+	s.broker.publish(order)
+
+	ordersPlaced.Inc()
+	orderValue.Observe(order.TotalPrice)
 	return &order, nil
 }
+
+// validateOrder checks the fields of an order that do not depend on other
+// services.
+func validateOrder(accountID string, products []OrderedProduct) grpcerr.Violations {
+	var v grpcerr.Violations
+	if strings.TrimSpace(accountID) == "" {
+		v.Add("accountId", "must not be empty")
+	}
+	if len(products) == 0 {
+		v.Add("products", "must not be empty")
+	}
+
+	quantities := map[string]uint64{}
+	for i, p := range products {
+		if strings.TrimSpace(p.ID) == "" {
+			v.Add(fmt.Sprintf("products[%d].id", i), "must not be empty")
+		}
+		if p.Quantity == 0 {
+			v.Add(fmt.Sprintf("products[%d].quantity", i), "must be positive")
+		}
+
+		quantities[p.ID] += uint64(p.Quantity)
+		if quantities[p.ID] > MaxQuantity {
+			v.Add(fmt.Sprintf("products[%d].quantity", i), fmt.Sprintf("brings the total for product %s over %d", p.ID, MaxQuantity))
+		}
+	}
+	return v
+}
+
+// mergeProducts combines the lines that name the same product into one,
+// summing their quantities. Each product keeps the position of its first
+// line.
+func mergeProducts(products []OrderedProduct) []OrderedProduct {
+	merged := []OrderedProduct{}
+	index := map[string]int{}
+	for _, p := range products {
+		if i, ok := index[p.ID]; ok {
+			merged[i].Quantity += p.Quantity
+			continue
+		}
+		index[p.ID] = len(merged)
+		merged = append(merged, p)
+	}
+	return merged
+}
+
 func (s *orderService) GetOrdersForAccount(ctx context.Context, accountID string) ([]Order, error) {
 	return s.repository.GetOrdersForAccount(ctx, accountID)
 }
+func (s *orderService) GetOrdersForAccounts(ctx context.Context, accountIDs []string) ([]Order, error) {
+	if len(accountIDs) == 0 {
+		return []Order{}, nil
+	}
+	return s.repository.GetOrdersForAccounts(ctx, accountIDs)
+}
+func (s *orderService) GetOrdersByIDs(ctx context.Context, ids []string) ([]Order, error) {
+	if len(ids) == 0 {
+		return []Order{}, nil
+	}
+	return s.repository.ListOrdersByIDs(ctx, ids)
+}
+
+func (s *orderService) GetOrdersForProduct(ctx context.Context, productID string, skip uint64, take uint64) ([]Order, error) {
+	if take > 100 || (take == 0 && skip == 0) {
+		take = 100
+	}
+	return s.repository.GetOrdersForProduct(ctx, productID, skip, take)
+}
+
+func (s *orderService) UpdateOrderStatus(ctx context.Context, id string, status string) (*Order, error) {
+	if !validStatus(status) {
+		return nil, ErrInvalidStatus
+	}
+
+	order, err := s.repository.GetOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !canTransition(order.Status, status) {
+		return nil, fmt.Errorf("%w: a %s order cannot become %s", ErrInvalidTransition, order.Status, status)
+	}
+
+	if err := s.repository.UpdateOrderStatus(ctx, id, status); err != nil {
+		return nil, err
+	}
+	order.Status = status
+	s.broker.publish(*order)
+	return order, nil
+}
+
+func (s *orderService) WatchOrders(ctx context.Context, orderID string, accountID string) <-chan Order {
+	return s.broker.watch(ctx, func(o Order) bool {
+		return (orderID == "" || o.ID == orderID) && (accountID == "" || o.AccountID == accountID)
+	})
+}
+
+func (s *orderService) Ping(ctx context.Context) error {
+	return s.repository.Ping(ctx)
+}
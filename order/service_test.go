@@ -0,0 +1,100 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/valkyraycho/go-microservices/grpcerr/grpcerrtest"
+	"github.com/valkyraycho/go-microservices/order"
+)
+
+func TestPostOrderValidation(t *testing.T) {
+	s := order.NewService(order.NewMemoryRepository())
+
+	_, err := s.PostOrder(context.Background(), "", []order.OrderedProduct{
+		{ID: "keyboard", Quantity: 1},
+		{ID: "", Quantity: 0},
+	})
+
+	want := []string{"accountId", "products[1].id", "products[1].quantity"}
+	if got := grpcerrtest.ViolatedFields(t, err); !reflect.DeepEqual(got, want) {
+		t.Errorf("PostOrder violated %v, want %v", got, want)
+	}
+}
+
+func TestPostOrderMergesDuplicateProducts(t *testing.T) {
+	s := order.NewService(order.NewMemoryRepository())
+
+	o, err := s.PostOrder(context.Background(), "account", []order.OrderedProduct{
+		{ID: "keyboard", Price: 10, Quantity: 1},
+		{ID: "mouse", Price: 5, Quantity: 1},
+		{ID: "keyboard", Price: 10, Quantity: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []order.OrderedProduct{{ID: "keyboard", Price: 10, Quantity: 3}, {ID: "mouse", Price: 5, Quantity: 1}}
+	if !reflect.DeepEqual(o.Products, want) {
+		t.Errorf("Products = %+v, want %+v", o.Products, want)
+	}
+	if o.TotalPrice != 35 {
+		t.Errorf("TotalPrice = %v, want 35", o.TotalPrice)
+	}
+}
+
+func TestPostOrderRejectsEmptyOrders(t *testing.T) {
+	s := order.NewService(order.NewMemoryRepository())
+
+	_, err := s.PostOrder(context.Background(), "account", nil)
+	if got := grpcerrtest.ViolatedFields(t, err); !reflect.DeepEqual(got, []string{"products"}) {
+		t.Errorf("PostOrder violated %v, want [products]", got)
+	}
+}
+
+func TestPostOrderRejectsQuantityOverflow(t *testing.T) {
+	s := order.NewService(order.NewMemoryRepository())
+
+	_, err := s.PostOrder(context.Background(), "account", []order.OrderedProduct{
+		{ID: "keyboard", Quantity: order.MaxQuantity},
+		{ID: "keyboard", Quantity: 1},
+	})
+	if got := grpcerrtest.ViolatedFields(t, err); !reflect.DeepEqual(got, []string{"products[1].quantity"}) {
+		t.Errorf("PostOrder violated %v, want [products[1].quantity]", got)
+	}
+}
+
+func TestUpdateOrderStatusTransitions(t *testing.T) {
+	s := order.NewService(order.NewMemoryRepository())
+	ctx := context.Background()
+
+	o, err := s.PostOrder(ctx, "account", []order.OrderedProduct{{ID: "keyboard", Quantity: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		status string
+		ok     bool
+	}{
+		{order.StatusShipped, false},
+		{order.StatusPaid, true},
+		{order.StatusPaid, true},
+		{order.StatusPending, false},
+		{order.StatusShipped, true},
+		{order.StatusCancelled, false},
+		{order.StatusDelivered, true},
+		{order.StatusPending, false},
+	}
+	for _, step := range steps {
+		updated, err := s.UpdateOrderStatus(ctx, o.ID, step.status)
+		if step.ok && (err != nil || updated.Status != step.status) {
+			t.Fatalf("UpdateOrderStatus(%s) = %v, %v; want it %s", step.status, updated, err, step.status)
+		}
+		if !step.ok && !errors.Is(err, order.ErrInvalidTransition) {
+			t.Fatalf("UpdateOrderStatus(%s) error = %v, want %v", step.status, err, order.ErrInvalidTransition)
+		}
+	}
+}
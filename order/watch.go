@@ -0,0 +1,62 @@
+package order
+
+import (
+	"context"
+	"sync"
+)
+
+// watchBuffer is how many events a slow watcher can fall behind before
+// further events are dropped for it.
+const watchBuffer = 16
+
+// broker fans order changes out to watchers. It only sees changes made
+// through this process, so every replica serves the orders it handled.
+type broker struct {
+	mu       sync.Mutex
+	watchers map[*watcher]struct{}
+}
+
+type watcher struct {
+	match func(Order) bool
+	ch    chan Order
+}
+
+func newBroker() *broker {
+	return &broker{watchers: map[*watcher]struct{}{}}
+}
+
+// watch returns a channel of the orders accepted by match. The channel is
+// closed once ctx is done.
+func (b *broker) watch(ctx context.Context, match func(Order) bool) <-chan Order {
+	w := &watcher{match: match, ch: make(chan Order, watchBuffer)}
+
+	b.mu.Lock()
+	b.watchers[w] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		b.mu.Lock()
+		delete(b.watchers, w)
+		close(w.ch)
+		b.mu.Unlock()
+	}()
+
+	return w.ch
+}
+
+func (b *broker) publish(o Order) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for w := range b.watchers {
+		if !w.match(o) {
+			continue
+		}
+		select {
+		case w.ch <- o:
+		default:
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// clientMetadata is the gRPC metadata key the client a call is made for
+// travels in, from the gateways to the services and on between them.
+const clientMetadata = "x-client-id"
+
+type clientKey struct{}
+
+// WithClient returns a copy of ctx that carries the name of the client the
+// calls made with it are for, such as the address of a gateway's client.
+// Calls made with it send the name along, so that the services limit each
+// client of a gateway on its own, as well as the gateway as a whole.
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// Client returns the client name ctx carries, or "" if it has none.
+func Client(ctx context.Context) string {
+	client, _ := ctx.Value(clientKey{}).(string)
+	return client
+}
+
+// UnaryClientInterceptor sends the client name in the context, if any, to
+// the service called.
+func UnaryClientInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(outgoingClient(ctx), method, req, reply, cc, opts...)
+}
+
+// StreamClientInterceptor is the stream counterpart of UnaryClientInterceptor.
+func StreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(outgoingClient(ctx), desc, cc, method, opts...)
+}
+
+func outgoingClient(ctx context.Context) context.Context {
+	if client := Client(ctx); client != "" {
+		return metadata.AppendToOutgoingContext(ctx, clientMetadata, client)
+	}
+	return ctx
+}
+
+// maxClientLength bounds the client names accepted from callers.
+const maxClientLength = 128
+
+// Quotas limits the calls made to a gRPC server. Every caller has a quota,
+// which bounds all its calls. Callers trusted to forward calls for clients of
+// their own, such as the gateways, may name the client of each call, and each
+// such client is limited on its own as well, so that a single one cannot use
+// up the quota of the others. Health checks are not limited, so probes keep
+// working while a caller is throttled.
+type Quotas struct {
+	limiter *Limiter
+	callers map[string]*Limiter
+
+	clients    *Limiter
+	forwarders map[string]bool
+}
+
+// NewQuotas applies limit to every caller, unless callers sets a quota of its
+// own for it, keyed by name as Caller returns it.
+func NewQuotas(limit Limit, callers map[string]Limit) *Quotas {
+	q := &Quotas{limiter: NewLimiter(limit), callers: map[string]*Limiter{}, clients: NewLimiter(Limit{})}
+	for name, limit := range callers {
+		q.callers[name] = NewLimiter(limit)
+	}
+	return q
+}
+
+// LimitClients applies limit to each client that forwarders name in their
+// calls, on top of their own quotas. Forwarders are named by the common name
+// of their certificate, and only trusted once it was verified under mutual
+// TLS, since any caller could name a new client in every call. It must be
+// called before the server is started.
+func (q *Quotas) LimitClients(limit Limit, forwarders []string) {
+	q.clients = NewLimiter(limit)
+	q.forwarders = map[string]bool{}
+	for _, name := range forwarders {
+		q.forwarders[name] = true
+	}
+}
+
+// incomingClient returns ctx with the client the call names, if it comes
+// from a forwarder.
+func (q *Quotas) incomingClient(ctx context.Context) context.Context {
+	if caller, ok := authenticatedCaller(ctx); !ok || !q.forwarders[caller] {
+		return ctx
+	}
+	if clients := metadata.ValueFromIncomingContext(ctx, clientMetadata); len(clients) > 0 && clients[0] != "" && len(clients[0]) <= maxClientLength {
+		return WithClient(ctx, clients[0])
+	}
+	return ctx
+}
+
+// ServerOptions returns the options that enforce q on a server.
+func (q *Quotas) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(q.unary),
+		grpc.ChainStreamInterceptor(q.stream),
+	}
+}
+
+func (q *Quotas) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx = q.incomingClient(ctx)
+	if err := q.allow(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// stream limits opening streams. The messages sent on them are not counted.
+func (q *Quotas) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := q.incomingClient(ss.Context())
+	if err := q.allow(ctx, info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, &clientStream{ServerStream: ss, ctx: ctx})
+}
+
+type clientStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *clientStream) Context() context.Context {
+	return s.ctx
+}
+
+func (q *Quotas) allow(ctx context.Context, method string) error {
+	if strings.HasPrefix(method, "/grpc.health.v1.Health/") {
+		return nil
+	}
+
+	caller := Caller(ctx)
+	quota, ok := q.callers[caller]
+	if !ok {
+		quota = q.limiter
+	}
+	takes := []Take{{Limiter: quota, Key: caller}}
+	if client := Client(ctx); client != "" {
+		// Clients are only told apart within a caller, which names them.
+		takes = append(takes, Take{Limiter: q.clients, Key: caller + " " + client})
+	}
+	if ok, retryAfter, _ := AllowAll(takes...); !ok {
+		return Exhausted(retryAfter)
+	}
+	return nil
+}
+
+// Caller names the peer of a call: the common name of its certificate when
+// it connected with mutual TLS, and its IP address otherwise.
+func Caller(ctx context.Context) string {
+	if name, ok := authenticatedCaller(ctx); ok {
+		return name
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
+
+// authenticatedCaller returns the common name of the certificate the peer of
+// a call presented under mutual TLS, which the server verified.
+func authenticatedCaller(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.PeerCertificates) > 0 {
+		return info.State.PeerCertificates[0].Subject.CommonName, true
+	}
+	return "", false
+}
+
+// Exhausted returns the RESOURCE_EXHAUSTED error of a throttled call, with a
+// RetryInfo detail that tells the caller when to try again.
+func Exhausted(retryAfter time.Duration) error {
+	s := status.Newf(codes.ResourceExhausted, "rate limit exceeded, retry in %s", retryAfter.Round(time.Millisecond))
+	if detailed, err := s.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}); err == nil {
+		s = detailed
+	}
+	return s.Err()
+}
+
+// RetryDelay returns the delay of the RetryInfo detail of err, if it has one.
+func RetryDelay(err error) (time.Duration, bool) {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			return info.RetryDelay.AsDuration(), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,248 @@
+package ratelimit_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/valkyraycho/go-microservices/health"
+	"github.com/valkyraycho/go-microservices/ratelimit"
+	"github.com/valkyraycho/go-microservices/tlsconfig"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// echoDesc describes a service whose Caller method answers with the name
+// ratelimit.Caller gives the caller.
+var echoDesc = grpc.ServiceDesc{
+	ServiceName: "test.Echo",
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Caller",
+		Handler: func(_ any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			if err := dec(&emptypb.Empty{}); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, _ any) (any, error) {
+				return wrapperspb.String(ratelimit.Caller(ctx)), nil
+			}
+			if interceptor == nil {
+				return handler(ctx, nil)
+			}
+			return interceptor(ctx, &emptypb.Empty{}, &grpc.UnaryServerInfo{FullMethod: "/test.Echo/Caller"}, handler)
+		},
+	}},
+}
+
+// serve runs the echo and health services with opts over bufconn and
+// connects to them with dialOpts.
+func serve(t *testing.T, opts []grpc.ServerOption, dialOpts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+
+	server := grpc.NewServer(opts...)
+	server.RegisterService(&echoDesc, struct{}{})
+	health.Register(server, nil)
+	lis := bufconn.Listen(1 << 20)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	dialOpts = append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+	}, dialOpts...)
+	conn, err := grpc.NewClient("passthrough:///account-service", dialOpts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func callerOf(conn *grpc.ClientConn) (string, error) {
+	var res wrapperspb.StringValue
+	err := conn.Invoke(context.Background(), "/test.Echo/Caller", &emptypb.Empty{}, &res)
+	return res.Value, err
+}
+
+func TestQuotas(t *testing.T) {
+	quotas := ratelimit.NewQuotas(ratelimit.Limit{Requests: 1, Period: time.Minute}, nil)
+	conn := serve(t, quotas.ServerOptions())
+
+	if _, err := callerOf(conn); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	_, err := callerOf(conn)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("call over the quota error = %v, want RESOURCE_EXHAUSTED", err)
+	}
+	if delay, ok := ratelimit.RetryDelay(err); !ok || delay <= 0 || delay > time.Minute {
+		t.Errorf("retry delay = %v, %v; want up to a minute", delay, ok)
+	}
+
+	// Health checks are not counted, so a throttled caller can still probe.
+	if err := health.Ping(context.Background(), conn, ""); err != nil {
+		t.Errorf("health check over the quota: %v", err)
+	}
+}
+
+func TestCallerQuotas(t *testing.T) {
+	quotas := ratelimit.NewQuotas(
+		ratelimit.Limit{Requests: 1, Period: time.Minute},
+		// Connections over bufconn have no IP address, so the caller is
+		// named after the address of the listener.
+		map[string]ratelimit.Limit{"bufconn": {Requests: 3, Period: time.Minute}},
+	)
+	conn := serve(t, quotas.ServerOptions())
+
+	for i := range 3 {
+		if _, err := callerOf(conn); err != nil {
+			t.Fatalf("call %d within the caller's quota: %v", i+1, err)
+		}
+	}
+	if _, err := callerOf(conn); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("call over the caller's quota error = %v, want RESOURCE_EXHAUSTED", err)
+	}
+}
+
+// mutualTLS issues certificates from a new CA and returns the configuration
+// of each name it is given.
+func mutualTLS(t *testing.T) func(name string) tlsconfig.Config {
+	t.Helper()
+
+	dir := t.TempDir()
+	ca, err := tlsconfig.NewCA(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return func(name string) tlsconfig.Config {
+		t.Helper()
+
+		cert, key, err := ca.Issue(name, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := tlsconfig.Config{
+			CertFile: filepath.Join(dir, name+".pem"),
+			KeyFile:  filepath.Join(dir, name+"-key.pem"),
+			CAFile:   filepath.Join(dir, "ca.pem"),
+		}
+		for file, data := range map[string][]byte{cfg.CertFile: cert, cfg.KeyFile: key, cfg.CAFile: ca.CertPEM} {
+			if err := os.WriteFile(file, data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return cfg
+	}
+}
+
+// serveTLS is serve with the server authenticated as account-service and the
+// client as caller, under mutual TLS. The client sends the client in the
+// context of its calls along, as the clients of the gateways do.
+func serveTLS(t *testing.T, opts []grpc.ServerOption, caller string) *grpc.ClientConn {
+	t.Helper()
+
+	config := mutualTLS(t)
+	serverCreds, err := tlsconfig.ServerOption(config("account-service"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialCreds, err := tlsconfig.DialOption(config(caller))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serve(t, append(opts, serverCreds), dialCreds, grpc.WithChainUnaryInterceptor(ratelimit.UnaryClientInterceptor))
+}
+
+// callFor calls the echo service for client.
+func callFor(conn *grpc.ClientConn, client string) error {
+	ctx := ratelimit.WithClient(context.Background(), client)
+	return conn.Invoke(ctx, "/test.Echo/Caller", &emptypb.Empty{}, &wrapperspb.StringValue{})
+}
+
+func TestQuotasPerClient(t *testing.T) {
+	quotas := ratelimit.NewQuotas(ratelimit.Limit{Requests: 3, Period: time.Minute}, nil)
+	quotas.LimitClients(ratelimit.Limit{Requests: 1, Period: time.Minute}, []string{"graphql-gateway"})
+	conn := serveTLS(t, quotas.ServerOptions(), "graphql-gateway")
+
+	// Each client of the forwarder has a bucket of its own.
+	for _, client := range []string{"192.0.2.1", "192.0.2.2"} {
+		if err := callFor(conn, client); err != nil {
+			t.Fatalf("first call for %s: %v", client, err)
+		}
+	}
+	if err := callFor(conn, "192.0.2.1"); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("second call for 192.0.2.1 error = %v, want RESOURCE_EXHAUSTED", err)
+	}
+
+	// The quota of the forwarder bounds the calls of all its clients, and a
+	// client turned down above did not use it up.
+	if err := callFor(conn, "192.0.2.3"); err != nil {
+		t.Fatalf("call for a third client: %v", err)
+	}
+	if err := callFor(conn, "192.0.2.4"); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("call over the forwarder's quota error = %v, want RESOURCE_EXHAUSTED", err)
+	}
+}
+
+func TestQuotasIgnoreClientsOfOtherCallers(t *testing.T) {
+	tests := []struct {
+		name string
+		conn func(t *testing.T, quotas *ratelimit.Quotas) *grpc.ClientConn
+	}{
+		{"plaintext", func(t *testing.T, quotas *ratelimit.Quotas) *grpc.ClientConn {
+			// Connections over bufconn are named after the listener, but
+			// names that were not verified are never trusted.
+			return serve(t, quotas.ServerOptions(), grpc.WithChainUnaryInterceptor(ratelimit.UnaryClientInterceptor))
+		}},
+		{"not a forwarder", func(t *testing.T, quotas *ratelimit.Quotas) *grpc.ClientConn {
+			return serveTLS(t, quotas.ServerOptions(), "order-service")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quotas := ratelimit.NewQuotas(ratelimit.Limit{Requests: 2, Period: time.Minute}, nil)
+			quotas.LimitClients(ratelimit.Limit{Requests: 1, Period: time.Minute}, []string{"bufconn", "graphql-gateway"})
+			conn := tt.conn(t, quotas)
+
+			// A caller that names a new client in every call still uses
+			// up its own quota.
+			for i := range 2 {
+				if err := callFor(conn, fmt.Sprintf("client-%d", i)); err != nil {
+					t.Fatalf("call %d within the quota: %v", i+1, err)
+				}
+			}
+			if err := callFor(conn, "client-2"); status.Code(err) != codes.ResourceExhausted {
+				t.Errorf("call over the quota error = %v, want RESOURCE_EXHAUSTED", err)
+			}
+		})
+	}
+}
+
+func TestCallerUnderMutualTLS(t *testing.T) {
+	config := mutualTLS(t)
+	serverCreds, err := tlsconfig.ServerOption(config("account-service"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialCreds, err := tlsconfig.DialOption(config("order-service"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := serve(t, []grpc.ServerOption{serverCreds}, dialCreds)
+
+	if caller, err := callerOf(conn); err != nil || caller != "order-service" {
+		t.Errorf("Caller = %q, %v; want order-service", caller, err)
+	}
+}
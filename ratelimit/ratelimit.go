@@ -0,0 +1,175 @@
+// Package ratelimit keeps a token bucket for every client of a gateway or
+// caller of a service, so a single one cannot use up the capacity of the
+// others.
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limit allows Requests per Period, in bursts of up to Requests. The zero
+// Limit allows everything.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Enabled reports whether l limits anything.
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "0"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// UnmarshalText parses limits written as requests/period, such as 10/1m for
+// ten requests a minute. 0 or an empty string is no limit.
+func (l *Limit) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	if s == "" || s == "0" {
+		*l = Limit{}
+		return nil
+	}
+
+	requests, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return fmt.Errorf("ratelimit: limit %q is not requests/period", s)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 0 {
+		return fmt.Errorf("ratelimit: limit %q has an invalid number of requests", s)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("ratelimit: limit %q has an invalid period", s)
+	}
+	*l = Limit{Requests: n, Period: d}
+	return nil
+}
+
+// Limiter keeps a token bucket per key, such as a client's address.
+type Limiter struct {
+	limit Limit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limiter *rate.Limiter
+	used    time.Time
+}
+
+// NewLimiter returns a limiter that applies limit to every key.
+func NewLimiter(limit Limit) *Limiter {
+	return &Limiter{limit: limit, buckets: map[string]*bucket{}, lastSweep: time.Now()}
+}
+
+// Allow takes a token from the bucket of key. When the bucket is empty it
+// returns false and how long it takes until a token is available.
+func (l *Limiter) Allow(key string) (ok bool, retryAfter time.Duration) {
+	ok, retryAfter, _ = AllowAll(Take{Limiter: l, Key: key})
+	return ok, retryAfter
+}
+
+// Take names the bucket of Key in Limiter.
+type Take struct {
+	Limiter *Limiter
+	Key     string
+}
+
+// AllowAll takes a token from every bucket in takes, or from none of them
+// when one is short. Then it returns false, how long it takes until that
+// bucket has enough tokens, and the index in takes of its first take.
+func AllowAll(takes ...Take) (ok bool, retryAfter time.Duration, refused int) {
+	now := time.Now()
+
+	// A bucket named more than once gives a token for each of its takes.
+	type want struct {
+		bucket *rate.Limiter
+		tokens int
+		first  int
+	}
+	var wants []*want
+	seen := map[*rate.Limiter]*want{}
+	for i, t := range takes {
+		b := t.Limiter.bucket(t.Key, now)
+		if b == nil {
+			continue
+		}
+		if w, ok := seen[b]; ok {
+			w.tokens++
+			continue
+		}
+		w := &want{bucket: b, tokens: 1, first: i}
+		seen[b] = w
+		wants = append(wants, w)
+	}
+
+	for _, w := range wants {
+		if missing := float64(w.tokens) - w.bucket.TokensAt(now); missing > 0 {
+			return false, time.Duration(missing / float64(w.bucket.Limit()) * float64(time.Second)), w.first
+		}
+	}
+	for _, w := range wants {
+		w.bucket.AllowN(now, w.tokens)
+	}
+	return true, 0, 0
+}
+
+// bucket returns the bucket of key, or nil when l limits nothing.
+func (l *Limiter) bucket(key string, now time.Time) *rate.Limiter {
+	if !l.limit.Enabled() {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		every := rate.Every(l.limit.Period / time.Duration(l.limit.Requests))
+		b = &bucket{limiter: rate.NewLimiter(every, l.limit.Requests)}
+		l.buckets[key] = b
+	}
+	b.used = now
+	return b.limiter
+}
+
+// sweep drops the buckets that were not used for a whole period. They have
+// filled up again since, so they are no different from new ones, and keeping
+// them would let the number of buckets grow with every client ever seen.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.limit.Period {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.used) >= l.limit.Period {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RetryAfterSeconds rounds d up to whole seconds, as Retry-After headers
+// count them, so clients that wait as long are not turned down again.
+func RetryAfterSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// RetryAfter formats d as the value of a Retry-After header.
+func RetryAfter(d time.Duration) string {
+	return strconv.Itoa(RetryAfterSeconds(d))
+}
@@ -0,0 +1,136 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/valkyraycho/go-microservices/ratelimit"
+)
+
+func TestUnmarshalLimit(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    ratelimit.Limit
+		wantErr bool
+	}{
+		{text: "10/1m", want: ratelimit.Limit{Requests: 10, Period: time.Minute}},
+		{text: " 500/1s ", want: ratelimit.Limit{Requests: 500, Period: time.Second}},
+		{text: "0"},
+		{text: ""},
+		{text: "10", wantErr: true},
+		{text: "ten/1s", wantErr: true},
+		{text: "-1/1s", wantErr: true},
+		{text: "10/forever", wantErr: true},
+		{text: "10/0s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var got ratelimit.Limit
+		err := got.UnmarshalText([]byte(tt.text))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("UnmarshalText(%q) error = %v, want error: %v", tt.text, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("UnmarshalText(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := ratelimit.NewLimiter(ratelimit.Limit{Requests: 3, Period: 3 * time.Minute})
+
+	for i := range 3 {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("request %d of the burst was turned down", i+1)
+		}
+	}
+	ok, retryAfter := l.Allow("a")
+	if ok {
+		t.Fatal("request over the limit was allowed")
+	}
+	// A token comes back every minute.
+	if retryAfter <= 59*time.Second || retryAfter > time.Minute {
+		t.Errorf("retry after %v, want about a minute", retryAfter)
+	}
+
+	if ok, _ := l.Allow("b"); !ok {
+		t.Error("another key was turned down")
+	}
+}
+
+func TestAllowAll(t *testing.T) {
+	one := ratelimit.NewLimiter(ratelimit.Limit{Requests: 1, Period: time.Minute})
+	two := ratelimit.NewLimiter(ratelimit.Limit{Requests: 2, Period: time.Minute})
+	none := ratelimit.NewLimiter(ratelimit.Limit{})
+
+	takes := []ratelimit.Take{{Limiter: two, Key: "a"}, {Limiter: none, Key: "a"}, {Limiter: one, Key: "a"}}
+	if ok, _, _ := ratelimit.AllowAll(takes...); !ok {
+		t.Fatal("first take was turned down")
+	}
+	ok, retryAfter, refused := ratelimit.AllowAll(takes...)
+	if ok || refused != 2 || retryAfter <= 0 {
+		t.Fatalf("AllowAll = %v, %v, %d; want the third take refused", ok, retryAfter, refused)
+	}
+
+	// The refused take gave back the token it took from two.
+	if ok, _ := two.Allow("a"); !ok {
+		t.Error("the refused take used up a token")
+	}
+	if ok, _ := two.Allow("a"); ok {
+		t.Error("two allowed more than its limit")
+	}
+
+	// Takes from the same bucket each need a token.
+	two.Allow("b")
+	same := []ratelimit.Take{{Limiter: two, Key: "b"}, {Limiter: two, Key: "b"}}
+	if ok, _, refused := ratelimit.AllowAll(same...); ok || refused != 0 {
+		t.Fatalf("AllowAll = %v, refused %d; want the bucket refused", ok, refused)
+	}
+	if ok, _ := two.Allow("b"); !ok {
+		t.Error("the refused takes used up a token")
+	}
+}
+
+func TestLimiterRefills(t *testing.T) {
+	l := ratelimit.NewLimiter(ratelimit.Limit{Requests: 2, Period: 40 * time.Millisecond})
+
+	for range 2 {
+		l.Allow("a")
+	}
+	if ok, _ := l.Allow("a"); ok {
+		t.Fatal("request over the limit was allowed")
+	}
+
+	// After a whole period the bucket is full again, whether or not it was
+	// swept in the meantime.
+	time.Sleep(50 * time.Millisecond)
+	l.Allow("b")
+	for i := range 2 {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Errorf("request %d after the period was turned down", i+1)
+		}
+	}
+}
+
+func TestNoLimit(t *testing.T) {
+	l := ratelimit.NewLimiter(ratelimit.Limit{})
+	for range 1000 {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatal("request was turned down without a limit")
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		time.Millisecond:        "1",
+		time.Second:             "1",
+		1001 * time.Millisecond: "2",
+		time.Minute:             "60",
+	} {
+		if got := ratelimit.RetryAfter(d); got != want {
+			t.Errorf("RetryAfter(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
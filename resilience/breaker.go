@@ -0,0 +1,105 @@
+package resilience
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// breaker is the circuit breaker of the connection to one service.
+type breaker struct {
+	service  string
+	failures int
+	cooldown time.Duration
+
+	mu sync.Mutex
+	// failed counts the calls in a row that failed because of the service.
+	failed int
+	// openedAt is when the breaker opened, and is zero while it is closed.
+	openedAt time.Time
+	// probing is set while the single call let through after the cooldown
+	// is in flight.
+	probing bool
+}
+
+func newBreaker(service string, failures int, cooldown time.Duration) *breaker {
+	return &breaker{service: service, failures: failures, cooldown: cooldown}
+}
+
+func (b *breaker) unary(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	probe, err := b.allow()
+	if err != nil {
+		return err
+	}
+	err = invoker(ctx, method, req, reply, cc, opts...)
+	b.record(probe, err)
+	return err
+}
+
+// stream only guards opening a stream. What happens later on it is up to the
+// caller, and a stream that ends is no sign that the service failed.
+func (b *breaker) stream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	probe, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+	s, err := streamer(ctx, desc, cc, method, opts...)
+	b.record(probe, err)
+	return s, err
+}
+
+// allow returns an error while the breaker is open. Once the cooldown is over
+// it lets a single call through as a probe.
+func (b *breaker) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openedAt.IsZero() {
+		return false, nil
+	}
+	if b.probing || time.Since(b.openedAt) < b.cooldown {
+		return false, status.Errorf(codes.Unavailable, "circuit breaker for %s is open", b.service)
+	}
+	b.probing = true
+	return true, nil
+}
+
+func (b *breaker) record(probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+	if !failure(err) {
+		if !b.openedAt.IsZero() {
+			slog.Info("circuit breaker closed", "service", b.service)
+		}
+		b.failed, b.openedAt = 0, time.Time{}
+		return
+	}
+
+	b.failed++
+	if probe || (b.openedAt.IsZero() && b.failed >= b.failures) {
+		if b.openedAt.IsZero() {
+			slog.Warn("circuit breaker opened", "service", b.service, "failures", b.failed, "err", err)
+		}
+		b.openedAt = time.Now()
+	}
+}
+
+// failure reports whether err means that the service failed, rather than
+// that the call was wrong or canceled by the caller.
+func failure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Internal, codes.Unavailable, codes.DataLoss:
+		return true
+	default:
+		return false
+	}
+}
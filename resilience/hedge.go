@@ -0,0 +1,72 @@
+package resilience
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// hedge sends a read again when it has not been answered after delay, up to
+// hedges more times, and returns the first answer. An attempt that fails with
+// UNAVAILABLE has already been retried, so it only counts as an answer when
+// every other attempt fails too. The other attempts are canceled once there
+// is an answer.
+func hedge(delay time.Duration, hedges int, reads map[string]bool) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		out, ok := reply.(proto.Message)
+		if !reads[method] || !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		type result struct {
+			reply proto.Message
+			err   error
+		}
+		// The channel is buffered so that attempts still running when the
+		// call returns do not block.
+		results := make(chan result, hedges+1)
+		send := func() {
+			reply := out.ProtoReflect().New().Interface()
+			go func() {
+				results <- result{reply, invoker(ctx, method, req, reply, cc, opts...)}
+			}()
+		}
+
+		send()
+		sent, pending := 1, 1
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		var err error
+		for pending > 0 {
+			select {
+			case r := <-results:
+				pending--
+				if status.Code(r.err) == codes.Unavailable {
+					err = r.err
+					continue
+				}
+				if r.err == nil {
+					proto.Reset(out)
+					proto.Merge(out, r.reply)
+				}
+				return r.err
+			case <-timer.C:
+				if sent <= hedges {
+					send()
+					sent++
+					pending++
+					timer.Reset(delay)
+				}
+			}
+		}
+		return err
+	}
+}
@@ -0,0 +1,154 @@
+// Package resilience makes the calls between processes tolerate slow and
+// failing services: reads are retried and hedged, every call gets a default
+// deadline, and a circuit breaker stops calling a service that keeps failing.
+package resilience
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Config sets how the clients of a process call the services. Processes read
+// it from variables with the CLIENT_ prefix, such as CLIENT_MAX_ATTEMPTS. The
+// zero Config turns everything off.
+type Config struct {
+	// MaxAttempts bounds the attempts of a read that fails with UNAVAILABLE,
+	// including the first. Writes are never retried, since they may have
+	// taken effect before the failure. gRPC allows at most 5.
+	MaxAttempts    int           `envconfig:"MAX_ATTEMPTS" default:"3"`
+	InitialBackoff time.Duration `envconfig:"INITIAL_BACKOFF" default:"100ms"`
+	MaxBackoff     time.Duration `envconfig:"MAX_BACKOFF" default:"1s"`
+
+	// ReadTimeout and WriteTimeout are the deadlines of each attempt of a
+	// read and of a write, unless the caller's context ends earlier. Streams
+	// have none, since they stay open for as long as the caller wants.
+	ReadTimeout  time.Duration `envconfig:"READ_TIMEOUT" default:"2s"`
+	WriteTimeout time.Duration `envconfig:"WRITE_TIMEOUT" default:"5s"`
+
+	// A read that has not been answered after HedgeDelay is sent again, up
+	// to Hedges more times, and the first answer wins.
+	HedgeDelay time.Duration `envconfig:"HEDGE_DELAY" default:"300ms"`
+	Hedges     int           `envconfig:"HEDGES" default:"1"`
+
+	// After BreakerFailures calls in a row fail because of the service, the
+	// breaker opens and calls fail at once for BreakerCooldown. Then a single
+	// call is let through, and the breaker closes if it succeeds.
+	BreakerFailures int           `envconfig:"BREAKER_FAILURES" default:"5"`
+	BreakerCooldown time.Duration `envconfig:"BREAKER_COOLDOWN" default:"10s"`
+}
+
+// DialOptions returns the options that apply c to a connection to the
+// service described by desc. reads names its methods that only read, which
+// are the ones that may be retried and hedged.
+func (c Config) DialOptions(desc grpc.ServiceDesc, reads ...string) ([]grpc.DialOption, error) {
+	serviceConfig, err := c.serviceConfig(desc, reads)
+	if err != nil {
+		return nil, err
+	}
+
+	fullReads := map[string]bool{}
+	for _, method := range reads {
+		fullReads["/"+desc.ServiceName+"/"+method] = true
+	}
+
+	var unary []grpc.UnaryClientInterceptor
+	var stream []grpc.StreamClientInterceptor
+	if c.BreakerFailures > 0 {
+		b := newBreaker(desc.ServiceName, c.BreakerFailures, c.BreakerCooldown)
+		unary = append(unary, b.unary)
+		stream = append(stream, b.stream)
+	}
+	if c.HedgeDelay > 0 && c.Hedges > 0 {
+		unary = append(unary, hedge(c.HedgeDelay, c.Hedges, fullReads))
+	}
+
+	opts := []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(unary...),
+		grpc.WithChainStreamInterceptor(stream...),
+	}
+	if serviceConfig != "" {
+		opts = append(opts, grpc.WithDefaultServiceConfig(serviceConfig))
+	}
+	return opts, nil
+}
+
+// The service config is described in
+// https://github.com/grpc/grpc/blob/master/doc/service_config.md.
+type serviceConfig struct {
+	MethodConfig []methodConfig `json:"methodConfig"`
+}
+
+type methodConfig struct {
+	Name        []methodName `json:"name"`
+	Timeout     string       `json:"timeout,omitempty"`
+	RetryPolicy *retryPolicy `json:"retryPolicy,omitempty"`
+}
+
+type methodName struct {
+	Service string `json:"service"`
+	Method  string `json:"method"`
+}
+
+type retryPolicy struct {
+	MaxAttempts          int      `json:"maxAttempts"`
+	InitialBackoff       string   `json:"initialBackoff"`
+	MaxBackoff           string   `json:"maxBackoff"`
+	BackoffMultiplier    float64  `json:"backoffMultiplier"`
+	RetryableStatusCodes []string `json:"retryableStatusCodes"`
+}
+
+func (c Config) serviceConfig(desc grpc.ServiceDesc, reads []string) (string, error) {
+	isRead := map[string]bool{}
+	for _, method := range reads {
+		isRead[method] = true
+	}
+
+	read := methodConfig{Timeout: duration(c.ReadTimeout)}
+	write := methodConfig{Timeout: duration(c.WriteTimeout)}
+	for _, m := range desc.Methods {
+		name := methodName{Service: desc.ServiceName, Method: m.MethodName}
+		if isRead[m.MethodName] {
+			read.Name = append(read.Name, name)
+		} else {
+			write.Name = append(write.Name, name)
+		}
+	}
+
+	if c.MaxAttempts > 1 {
+		if c.InitialBackoff <= 0 || c.MaxBackoff <= 0 {
+			return "", errors.New("resilience: the backoffs must be positive when reads are retried")
+		}
+		read.RetryPolicy = &retryPolicy{
+			MaxAttempts:          c.MaxAttempts,
+			InitialBackoff:       duration(c.InitialBackoff),
+			MaxBackoff:           duration(c.MaxBackoff),
+			BackoffMultiplier:    2,
+			RetryableStatusCodes: []string{"UNAVAILABLE"},
+		}
+	}
+
+	var config serviceConfig
+	for _, m := range []methodConfig{read, write} {
+		if len(m.Name) > 0 && (m.Timeout != "" || m.RetryPolicy != nil) {
+			config.MethodConfig = append(config.MethodConfig, m)
+		}
+	}
+	if len(config.MethodConfig) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(config)
+	return string(b), err
+}
+
+// duration formats d as the service config expects, or returns "" for no
+// duration.
+func duration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
@@ -0,0 +1,255 @@
+package resilience_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/valkyraycho/go-microservices/resilience"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// flaky serves the test.Flaky service, whose Read and Write methods run
+// handle with the number of the call, counting from 1, and answer with that
+// number.
+type flaky struct {
+	handle func(ctx context.Context, method string, call int) error
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (f *flaky) Calls(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+func (f *flaky) methodDesc(name string) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(_ any, ctx context.Context, dec func(any) error, _ grpc.UnaryServerInterceptor) (any, error) {
+			if err := dec(&emptypb.Empty{}); err != nil {
+				return nil, err
+			}
+			f.mu.Lock()
+			f.calls[name]++
+			call := f.calls[name]
+			f.mu.Unlock()
+
+			if err := f.handle(ctx, name, call); err != nil {
+				return nil, err
+			}
+			return wrapperspb.Int64(int64(call)), nil
+		},
+	}
+}
+
+func (f *flaky) desc() grpc.ServiceDesc {
+	return grpc.ServiceDesc{
+		ServiceName: "test.Flaky",
+		HandlerType: (*any)(nil),
+		Methods:     []grpc.MethodDesc{f.methodDesc("Read"), f.methodDesc("Write")},
+	}
+}
+
+// dial serves f over bufconn and connects to it with policy, treating Read
+// as the only read.
+func dial(t *testing.T, f *flaky, policy resilience.Config) *grpc.ClientConn {
+	t.Helper()
+
+	f.calls = map[string]int{}
+	desc := f.desc()
+	server := grpc.NewServer()
+	server.RegisterService(&desc, f)
+	lis := bufconn.Listen(1 << 20)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	opts, err := policy.DialOptions(desc, "Read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = append(opts,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+	)
+	conn, err := grpc.NewClient("passthrough:///flaky", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func call(conn *grpc.ClientConn, method string) (int64, error) {
+	var res wrapperspb.Int64Value
+	err := conn.Invoke(context.Background(), "/test.Flaky/"+method, &emptypb.Empty{}, &res)
+	return res.Value, err
+}
+
+var unavailable = status.Error(codes.Unavailable, "try again")
+
+func TestRetriesReads(t *testing.T) {
+	f := &flaky{handle: func(_ context.Context, _ string, call int) error {
+		if call < 3 {
+			return unavailable
+		}
+		return nil
+	}}
+	conn := dial(t, f, resilience.Config{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+
+	if n, err := call(conn, "Read"); err != nil || n != 3 {
+		t.Errorf("Read = %d, %v; want 3, nil", n, err)
+	}
+	if _, err := call(conn, "Write"); status.Code(err) != codes.Unavailable {
+		t.Errorf("Write error = %v, want UNAVAILABLE", err)
+	}
+	if got := f.Calls("Write"); got != 1 {
+		t.Errorf("Write was called %d times, want once", got)
+	}
+}
+
+func TestDeadlines(t *testing.T) {
+	f := &flaky{handle: func(ctx context.Context, _ string, _ int) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	conn := dial(t, f, resilience.Config{ReadTimeout: 20 * time.Millisecond, WriteTimeout: 50 * time.Millisecond})
+
+	for method, timeout := range map[string]time.Duration{"Read": 20 * time.Millisecond, "Write": 50 * time.Millisecond} {
+		start := time.Now()
+		_, err := call(conn, method)
+		if status.Code(err) != codes.DeadlineExceeded {
+			t.Errorf("%s error = %v, want DEADLINE_EXCEEDED", method, err)
+		}
+		if elapsed := time.Since(start); elapsed < timeout || elapsed > timeout+time.Second {
+			t.Errorf("%s took %v, want about %v", method, elapsed, timeout)
+		}
+	}
+}
+
+func TestHedgesSlowReads(t *testing.T) {
+	canceled := make(chan struct{})
+	f := &flaky{handle: func(ctx context.Context, method string, call int) error {
+		if call == 1 {
+			<-ctx.Done()
+			close(canceled)
+			return ctx.Err()
+		}
+		return nil
+	}}
+	conn := dial(t, f, resilience.Config{HedgeDelay: 20 * time.Millisecond, Hedges: 1})
+
+	if n, err := call(conn, "Read"); err != nil || n != 2 {
+		t.Errorf("Read = %d, %v; want the answer of the hedge, 2", n, err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Error("the slow attempt was not canceled")
+	}
+}
+
+func TestHedgingKeepsDefiniteAnswers(t *testing.T) {
+	f := &flaky{handle: func(ctx context.Context, method string, call int) error {
+		if method == "Read" && call == 1 {
+			return status.Error(codes.NotFound, "no such thing")
+		}
+		return nil
+	}}
+	conn := dial(t, f, resilience.Config{HedgeDelay: time.Second, Hedges: 1})
+
+	if _, err := call(conn, "Read"); status.Code(err) != codes.NotFound {
+		t.Errorf("Read error = %v, want NOT_FOUND", err)
+	}
+	if got := f.Calls("Read"); got != 1 {
+		t.Errorf("Read was called %d times, want once", got)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	var mu sync.Mutex
+	healthy := false
+	f := &flaky{handle: func(context.Context, string, int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !healthy {
+			return unavailable
+		}
+		return nil
+	}}
+	conn := dial(t, f, resilience.Config{BreakerFailures: 2, BreakerCooldown: 50 * time.Millisecond})
+
+	for range 2 {
+		call(conn, "Write")
+	}
+	if _, err := call(conn, "Read"); status.Code(err) != codes.Unavailable {
+		t.Errorf("Read error with the breaker open = %v, want UNAVAILABLE", err)
+	}
+	if got := f.Calls("Read"); got != 0 {
+		t.Errorf("Read reached the service %d times with the breaker open", got)
+	}
+
+	// The probe after the cooldown fails, so the breaker opens again.
+	time.Sleep(60 * time.Millisecond)
+	call(conn, "Read")
+	call(conn, "Read")
+	if got := f.Calls("Read"); got != 1 {
+		t.Errorf("Read reached the service %d times after the cooldown, want once", got)
+	}
+
+	mu.Lock()
+	healthy = true
+	mu.Unlock()
+	time.Sleep(60 * time.Millisecond)
+	for range 3 {
+		if _, err := call(conn, "Read"); err != nil {
+			t.Errorf("Read once the service recovered: %v", err)
+		}
+	}
+}
+
+func TestBreakerIgnoresCallerErrors(t *testing.T) {
+	f := &flaky{handle: func(context.Context, string, int) error {
+		return status.Error(codes.InvalidArgument, "bad request")
+	}}
+	conn := dial(t, f, resilience.Config{BreakerFailures: 1, BreakerCooldown: time.Minute})
+
+	for range 3 {
+		call(conn, "Write")
+	}
+	if got := f.Calls("Write"); got != 3 {
+		t.Errorf("Write reached the service %d times, want 3", got)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	var policy resilience.Config
+	if err := envconfig.Process("CLIENT", &policy); err != nil {
+		t.Fatal(err)
+	}
+	f := &flaky{handle: func(context.Context, string, int) error { return nil }}
+	conn := dial(t, f, policy)
+	if _, err := call(conn, "Read"); err != nil {
+		t.Errorf("Read: %v", err)
+	}
+}
+
+func TestInvalidBackoff(t *testing.T) {
+	policy := resilience.Config{MaxAttempts: 3}
+	if _, err := policy.DialOptions(grpc.ServiceDesc{ServiceName: "test.Flaky"}, "Read"); err == nil {
+		t.Error("DialOptions accepted retries without a backoff")
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/valkyraycho/go-microservices/graceful"
+	"github.com/valkyraycho/go-microservices/ratelimit"
+	"github.com/valkyraycho/go-microservices/resilience"
+	"github.com/valkyraycho/go-microservices/rest"
+	"github.com/valkyraycho/go-microservices/telemetry"
+	"github.com/valkyraycho/go-microservices/tlsconfig"
+)
+
+type Config struct {
+	AccountURL string `envconfig:"ACCOUNT_SERVICE_URL"`
+	CatalogURL string `envconfig:"CATALOG_SERVICE_URL"`
+	OrderURL   string `envconfig:"ORDER_SERVICE_URL"`
+
+	MetricsPort int `envconfig:"METRICS_PORT" default:"9090"`
+	// ShutdownTimeout bounds how long the gateway waits for requests in
+	// flight once it is asked to stop.
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"10s"`
+
+	// HTTPTLS serves the gateway over HTTPS, and GRPCTLS secures its
+	// connections to the services with mutual TLS.
+	HTTPTLS tlsconfig.Config `envconfig:"HTTP_TLS"`
+	GRPCTLS tlsconfig.Config `envconfig:"GRPC_TLS"`
+
+	// Client sets the retries, deadlines, hedging and circuit breakers of the
+	// calls to the services.
+	Client resilience.Config `envconfig:"CLIENT"`
+
+	// RateLimit bounds the requests of each client, told apart by IP address.
+	RateLimit ratelimit.Limit `envconfig:"RATE_LIMIT" default:"50/1s"`
+}
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
+	var cfg Config
+	if err := envconfig.Process("", &cfg); err != nil {
+		return err
+	}
+
+	shutdown, err := telemetry.Setup(context.Background(), "rest")
+	if err != nil {
+		return err
+	}
+	// Spans still buffered are sent once everything else has shut down.
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			slog.Error("flushing spans", "err", err)
+		}
+	}()
+
+	go func() {
+		log.Fatal(telemetry.ListenMetrics(cfg.MetricsPort, nil))
+	}()
+
+	dialCreds, err := tlsconfig.DialOption(cfg.GRPCTLS)
+	if err != nil {
+		return err
+	}
+
+	// The connections to the services are closed once the server has
+	// stopped, not when the signal arrives, so requests in flight can finish.
+	connCtx, closeConns := context.WithCancel(context.Background())
+	defer closeConns()
+
+	h, err := rest.NewHandler(connCtx, cfg.AccountURL, cfg.CatalogURL, cfg.OrderURL, cfg.Client, cfg.RateLimit, dialCreds)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: h}
+	if cfg.HTTPTLS.Enabled() {
+		certs, err := tlsconfig.Load(cfg.HTTPTLS)
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = certs.ServerConfig()
+	}
+
+	lis, err := net.Listen("tcp", ":8080")
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := graceful.SignalContext()
+	defer stop()
+
+	slog.Info("listening", "port", 8080)
+	if err := graceful.ServeHTTP(ctx, server, lis, cfg.ShutdownTimeout); err != nil {
+		return err
+	}
+	slog.Info("shut down")
+	return nil
+}
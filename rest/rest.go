@@ -0,0 +1,149 @@
+// Package rest serves the account, catalog and order services as a JSON API
+// over HTTP. Each request is translated into a call to the gRPC service, so
+// the API has the same semantics and errors as the services.
+package rest
+
+import (
+	"context"
+	"embed"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/valkyraycho/go-microservices/account"
+	accountpb "github.com/valkyraycho/go-microservices/account/proto"
+	"github.com/valkyraycho/go-microservices/catalog"
+	catalogpb "github.com/valkyraycho/go-microservices/catalog/proto"
+	"github.com/valkyraycho/go-microservices/order"
+	orderpb "github.com/valkyraycho/go-microservices/order/proto"
+	"github.com/valkyraycho/go-microservices/ratelimit"
+	"github.com/valkyraycho/go-microservices/resilience"
+	"github.com/valkyraycho/go-microservices/telemetry"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// The OpenAPI documents are generated from the service protos along with the
+// gateway code.
+//
+//go:embed openapi/api.swagger.json openapi/openapi.yaml
+var openapi embed.FS
+
+// NewHandler returns a handler that proxies REST requests to the services at
+// the given urls and serves the OpenAPI v2 and v3 documents at
+// /openapi/v2.json and /openapi/v3.yaml. Clients that send more requests than
+// limit allows are answered 429. Calls to the services are made resilient to
+// failures as set by policy, and extra dial options are applied after the
+// defaults, as with the service clients. The connections are closed when ctx
+// is done. Requests are traced, continuing the trace of the caller, and
+// logged with a request id that is passed on to the services.
+func NewHandler(ctx context.Context, accountURL, catalogURL, orderURL string, policy resilience.Config, limit ratelimit.Limit, opts ...grpc.DialOption) (http.Handler, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			// Send every field, so clients do not have to know the zero
+			// value of a missing one.
+			MarshalOptions:   protojson.MarshalOptions{EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+		runtime.WithErrorHandler(handleError),
+	)
+
+	// Each connection gets its own options, since the methods that may be
+	// retried differ and each service has its own circuit breaker.
+	dialOptions := func(desc grpc.ServiceDesc, reads []string) ([]grpc.DialOption, error) {
+		resilient, err := policy.DialOptions(desc, reads...)
+		if err != nil {
+			return nil, err
+		}
+		return append(append([]grpc.DialOption{
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+			grpc.WithChainUnaryInterceptor(telemetry.ClientMetrics.UnaryClientInterceptor(), telemetry.UnaryClientRequestID, ratelimit.UnaryClientInterceptor),
+			grpc.WithChainStreamInterceptor(telemetry.ClientMetrics.StreamClientInterceptor(), telemetry.StreamClientRequestID, ratelimit.StreamClientInterceptor),
+		}, resilient...), opts...), nil
+	}
+
+	accountOpts, err := dialOptions(accountpb.AccountService_ServiceDesc, account.ReadMethods)
+	if err != nil {
+		return nil, err
+	}
+	if err := accountpb.RegisterAccountServiceHandlerFromEndpoint(ctx, mux, accountURL, accountOpts); err != nil {
+		return nil, err
+	}
+	catalogOpts, err := dialOptions(catalogpb.CatalogService_ServiceDesc, catalog.ReadMethods)
+	if err != nil {
+		return nil, err
+	}
+	if err := catalogpb.RegisterCatalogServiceHandlerFromEndpoint(ctx, mux, catalogURL, catalogOpts); err != nil {
+		return nil, err
+	}
+	orderOpts, err := dialOptions(orderpb.OrderService_ServiceDesc, order.ReadMethods)
+	if err != nil {
+		return nil, err
+	}
+	if err := orderpb.RegisterOrderServiceHandlerFromEndpoint(ctx, mux, orderURL, orderOpts); err != nil {
+		return nil, err
+	}
+
+	h := http.NewServeMux()
+	h.Handle("/v1/", otelhttp.NewHandler(telemetry.LogRequests(withRateLimit(limit, mux)), "rest"))
+	h.HandleFunc("GET /openapi/v2.json", serveDocument("openapi/api.swagger.json", "application/json"))
+	h.HandleFunc("GET /openapi/v3.yaml", serveDocument("openapi/openapi.yaml", "application/yaml"))
+	return h, nil
+}
+
+// handleError tells clients when to retry calls a service throttled, and
+// otherwise answers like the default handler. Messages of internal failures
+// are logged and replaced, so clients never see the details of a service.
+func handleError(ctx context.Context, mux *runtime.ServeMux, m runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	if retryAfter, ok := ratelimit.RetryDelay(err); ok {
+		w.Header().Set("Retry-After", ratelimit.RetryAfter(retryAfter))
+	}
+	switch status.Code(err) {
+	case codes.Internal, codes.Unknown, codes.DataLoss:
+		slog.ErrorContext(ctx, "internal error", "path", r.URL.Path, "err", err)
+		err = status.Error(codes.Internal, "internal server error")
+	}
+	runtime.DefaultHTTPErrorHandler(ctx, mux, m, w, r, err)
+}
+
+// withRateLimit answers 429 to clients, told apart by IP address, that send
+// more requests than limit allows. The body is a status, as for the errors of
+// the services. The client is named in the calls to the services, which limit
+// each client of the gateway on its own.
+func withRateLimit(limit ratelimit.Limit, next http.Handler) http.Handler {
+	limiter := ratelimit.NewLimiter(limit)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		if ok, retryAfter := limiter.Allow(client); !ok {
+			body, _ := protojson.Marshal(status.Convert(ratelimit.Exhausted(retryAfter)).Proto())
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", ratelimit.RetryAfter(retryAfter))
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write(body)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ratelimit.WithClient(r.Context(), client)))
+	})
+}
+
+func serveDocument(name, contentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		doc, err := openapi.ReadFile(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write(doc)
+	}
+}
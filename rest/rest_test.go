@@ -0,0 +1,283 @@
+package rest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valkyraycho/go-microservices/account"
+	"github.com/valkyraycho/go-microservices/catalog"
+	"github.com/valkyraycho/go-microservices/order"
+	"github.com/valkyraycho/go-microservices/ratelimit"
+	"github.com/valkyraycho/go-microservices/resilience"
+	"github.com/valkyraycho/go-microservices/rest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestHandler runs every service with an in-memory repository, connected
+// over bufconn, behind a REST handler without a rate limit.
+func newTestHandler(t *testing.T) http.Handler {
+	t.Helper()
+	return newLimitedTestHandler(t, ratelimit.Limit{})
+}
+
+// newLimitedTestHandler is newTestHandler with a rate limit for each client.
+func newLimitedTestHandler(t *testing.T, limit ratelimit.Limit) http.Handler {
+	t.Helper()
+	return newStackHandler(t, limit, account.NewMemoryRepository())
+}
+
+// newStackHandler is newLimitedTestHandler with the given account store.
+func newStackHandler(t *testing.T, limit ratelimit.Limit, accounts account.Repository) http.Handler {
+	t.Helper()
+
+	listeners := map[string]*bufconn.Listener{}
+	dialer := grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return listeners[addr].DialContext(ctx)
+	})
+	serve := func(name string, server *grpc.Server) {
+		lis := bufconn.Listen(1 << 20)
+		listeners[name] = lis
+		go server.Serve(lis)
+		t.Cleanup(server.Stop)
+	}
+
+	serve("account", account.NewGRPCServer(account.NewService(accounts)))
+	serve("catalog", catalog.NewGRPCServer(catalog.NewService(catalog.NewMemoryRepository())))
+
+	accountClient, err := account.NewClient("passthrough:///account", resilience.Config{}, dialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(accountClient.Close)
+
+	catalogClient, err := catalog.NewClient("passthrough:///catalog", resilience.Config{}, dialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(catalogClient.Close)
+
+	serve("order", order.NewGRPCServer(order.NewService(order.NewMemoryRepository()), accountClient, catalogClient))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	h, err := rest.NewHandler(ctx, "passthrough:///account", "passthrough:///catalog", "passthrough:///order", resilience.Config{}, limit, dialer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// do sends a request with an optional JSON body and decodes the JSON
+// response into out. It returns the status code.
+func do(t *testing.T, h http.Handler, method, path string, body any, out any) int {
+	t.Helper()
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, path, &reqBody))
+
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decoding %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+type accountResponse struct {
+	Account struct{ ID, Name string }
+}
+
+func TestAccounts(t *testing.T) {
+	h := newTestHandler(t)
+
+	var created accountResponse
+	if code := do(t, h, "POST", "/v1/accounts", map[string]any{"name": "alice"}, &created); code != http.StatusOK {
+		t.Fatalf("POST /v1/accounts = %d", code)
+	}
+	if created.Account.ID == "" || created.Account.Name != "alice" {
+		t.Fatalf("created account = %+v", created.Account)
+	}
+
+	var got accountResponse
+	if code := do(t, h, "GET", "/v1/accounts/"+created.Account.ID, nil, &got); code != http.StatusOK || got.Account != created.Account {
+		t.Errorf("GET /v1/accounts/%s = %d %+v, want %+v", created.Account.ID, code, got.Account, created.Account)
+	}
+
+	var batch struct {
+		Accounts []struct{ ID, Name string }
+	}
+	path := "/v1/accounts:batchGet?ids=" + created.Account.ID + "&ids=missing"
+	if code := do(t, h, "GET", path, nil, &batch); code != http.StatusOK || len(batch.Accounts) != 1 {
+		t.Errorf("GET %s = %d %+v, want one account", path, code, batch.Accounts)
+	}
+}
+
+func TestErrorsMapToHTTPStatus(t *testing.T) {
+	h := newTestHandler(t)
+
+	var notFound struct {
+		Code    int
+		Message string
+	}
+	if code := do(t, h, "GET", "/v1/accounts/missing", nil, &notFound); code != http.StatusNotFound {
+		t.Errorf("GET /v1/accounts/missing = %d, want %d", code, http.StatusNotFound)
+	}
+
+	var invalid struct {
+		Message string
+		Details []struct {
+			FieldViolations []struct{ Field, Description string }
+		}
+	}
+	if code := do(t, h, "POST", "/v1/products", map[string]any{"name": "", "price": -1}, &invalid); code != http.StatusBadRequest {
+		t.Fatalf("POST /v1/products = %d, want %d", code, http.StatusBadRequest)
+	}
+	if len(invalid.Details) != 1 || len(invalid.Details[0].FieldViolations) != 2 {
+		t.Errorf("details = %+v, want a violation for name and price", invalid.Details)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	h := newLimitedTestHandler(t, ratelimit.Limit{Requests: 1, Period: time.Minute})
+
+	if code := do(t, h, "GET", "/v1/accounts", nil, nil); code != http.StatusOK {
+		t.Fatalf("GET /v1/accounts = %d", code)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/accounts", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("GET /v1/accounts over the limit = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if retryAfter := rec.Header().Get("Retry-After"); retryAfter != "60" {
+		t.Errorf("Retry-After = %q, want 60", retryAfter)
+	}
+	var exhausted struct {
+		Code    codes.Code
+		Details []struct {
+			Type       string `json:"@type"`
+			RetryDelay string
+		}
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &exhausted); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+	if exhausted.Code != codes.ResourceExhausted || len(exhausted.Details) != 1 || exhausted.Details[0].RetryDelay == "" {
+		t.Errorf("body = %s, want RESOURCE_EXHAUSTED with a RetryInfo detail", rec.Body.String())
+	}
+}
+
+func TestOrders(t *testing.T) {
+	h := newTestHandler(t)
+
+	var acc accountResponse
+	do(t, h, "POST", "/v1/accounts", map[string]any{"name": "alice"}, &acc)
+
+	var product struct {
+		Product struct{ ID string }
+	}
+	do(t, h, "POST", "/v1/products", map[string]any{"name": "Keyboard", "description": "", "price": 10}, &product)
+
+	type orderResponse struct {
+		Order struct {
+			ID         string
+			TotalPrice float64
+			Status     string
+		}
+	}
+	var created orderResponse
+	code := do(t, h, "POST", "/v1/orders", map[string]any{
+		"accountId": acc.Account.ID,
+		"products":  []map[string]any{{"productId": product.Product.ID, "quantity": 2}},
+	}, &created)
+	if code != http.StatusOK || created.Order.TotalPrice != 20 {
+		t.Fatalf("POST /v1/orders = %d %+v, want total price 20", code, created.Order)
+	}
+
+	// Nobody is authenticated, so orders cannot be changed or watched, nor
+	// products archived.
+	for _, route := range []struct{ method, path string }{
+		{"PATCH", "/v1/orders/" + created.Order.ID},
+		{"GET", "/v1/orders:watch"},
+		{"POST", "/v1/products/" + product.Product.ID + ":archive"},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(route.method, route.path, strings.NewReader(`{"status": "PAID"}`)))
+		if rec.Code < http.StatusBadRequest {
+			t.Errorf("%s %s = %d, want it not published", route.method, route.path, rec.Code)
+		}
+	}
+
+	var listed struct {
+		Orders []struct{ ID string }
+	}
+	path := "/v1/accounts/" + acc.Account.ID + "/orders"
+	if code := do(t, h, "GET", path, nil, &listed); code != http.StatusOK || len(listed.Orders) != 1 || listed.Orders[0].ID != created.Order.ID {
+		t.Errorf("GET %s = %d %+v, want [%s]", path, code, listed.Orders, created.Order.ID)
+	}
+}
+
+func TestOpenAPIDocuments(t *testing.T) {
+	h := newTestHandler(t)
+
+	var v2 struct {
+		Swagger string
+		Paths   map[string]any
+	}
+	if code := do(t, h, "GET", "/openapi/v2.json", nil, &v2); code != http.StatusOK || v2.Swagger != "2.0" {
+		t.Fatalf("GET /openapi/v2.json = %d, swagger %q", code, v2.Swagger)
+	}
+	for _, path := range []string{"/v1/accounts", "/v1/products", "/v1/orders"} {
+		if _, ok := v2.Paths[path]; !ok {
+			t.Errorf("OpenAPI v2 document has no path %s", path)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/openapi/v3.yaml", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "openapi: 3.0.3") {
+		t.Errorf("GET /openapi/v3.yaml = %d, want an OpenAPI 3 document", rec.Code)
+	}
+}
+
+// brokenAccounts fails to look up accounts the way a database would.
+type brokenAccounts struct {
+	account.Repository
+}
+
+func (brokenAccounts) GetAccountByID(ctx context.Context, id string) (*account.Account, error) {
+	return nil, errors.New(`pq: relation "accounts" does not exist`)
+}
+
+func TestInternalErrorsAreRedacted(t *testing.T) {
+	h := newStackHandler(t, ratelimit.Limit{}, brokenAccounts{account.NewMemoryRepository()})
+
+	var res struct {
+		Code    int
+		Message string
+	}
+	if code := do(t, h, "GET", "/v1/accounts/a1", nil, &res); code != http.StatusInternalServerError {
+		t.Errorf("GET /v1/accounts/a1 = %d, want 500", code)
+	}
+	if res.Code != int(codes.Internal) || res.Message != "internal server error" {
+		t.Errorf("error = %+v, want an internal error without details", res)
+	}
+}
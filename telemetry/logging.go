@@ -0,0 +1,339 @@
+package telemetry
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDHeader is the HTTP header the gateways read a request id from and
+// return it in. Between processes the id travels in the x-request-id gRPC
+// metadata key.
+const RequestIDHeader = "X-Request-Id"
+
+const (
+	requestIDMetadata = "x-request-id"
+	accountIDMetadata = "x-account-id"
+)
+
+// maxRequestIDLength bounds the request ids accepted from clients, so they
+// cannot blow up every log line.
+const maxRequestIDLength = 128
+
+type (
+	requestIDKey  struct{}
+	accountIDKey  struct{}
+	requestLogKey struct{}
+)
+
+// WithRequestID returns a copy of ctx that carries id. Calls to the services
+// made with it send the id along, and records logged with it include it.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request id ctx carries, or "" if it has none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithAccountID returns a copy of ctx that carries the id of the account a
+// request acts for. Calls to the services made with it send the id along, and
+// records logged with it include it. Within a request logged by LogRequests,
+// the request record includes the first account id set too.
+func WithAccountID(ctx context.Context, id string) context.Context {
+	if l, ok := ctx.Value(requestLogKey{}).(*requestLog); ok {
+		l.setAccountID(id)
+	}
+	return context.WithValue(ctx, accountIDKey{}, id)
+}
+
+// AccountID returns the account id ctx carries, or "" if it has none.
+func AccountID(ctx context.Context) string {
+	id, _ := ctx.Value(accountIDKey{}).(string)
+	return id
+}
+
+// accountIDOf returns the id of the account a request to method names: its
+// account_id field, or the id field of a call to the account service.
+func accountIDOf(method string, req any) string {
+	if r, ok := req.(interface{ GetAccountId() string }); ok {
+		return r.GetAccountId()
+	}
+	if r, ok := req.(interface{ GetId() string }); ok && strings.HasPrefix(method, "/account_service.AccountService/") {
+		return r.GetId()
+	}
+	return ""
+}
+
+// NewRequestID returns a new unique request id.
+func NewRequestID() string {
+	return ksuid.New().String()
+}
+
+// NewLogger returns a logger that writes JSON records at level and above to
+// w, tagged with the service name. Records logged with a context include the
+// request id and trace id it carries. Setup makes a logger writing to stdout
+// the default for slog, and for the log package through it.
+func NewLogger(w io.Writer, serviceName string, level slog.Level) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+	return slog.New(contextHandler{handler}).With("service", serviceName)
+}
+
+// contextHandler adds the request id, account id and trace id a context
+// carries to the records logged with it.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if id := AccountID(ctx); id != "" {
+		r.AddAttrs(slog.String("account_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		r.AddAttrs(slog.String("trace_id", span.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// UnaryServerLogging logs every call with its method, duration, status code,
+// request id and account id. The request id and account id sent by the
+// caller are kept in the context, and a new request id is generated if there
+// is none. Without an account id from the caller, the one the request names
+// is used, if any.
+func UnaryServerLogging(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx = incomingAccountID(incomingRequestID(ctx), info.FullMethod, req)
+	start := time.Now()
+	res, err := handler(ctx, req)
+	logCall(ctx, info.FullMethod, start, err)
+	return res, err
+}
+
+// StreamServerLogging logs streams like UnaryServerLogging logs calls, once
+// the stream has ended. The account id is taken from the first message the
+// client sends.
+func StreamServerLogging(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := incomingAccountID(incomingRequestID(ss.Context()), info.FullMethod, nil)
+	stream := &loggedStream{ServerStream: ss, ctx: ctx, method: info.FullMethod}
+	start := time.Now()
+	err := handler(srv, stream)
+	logCall(stream.ctx, info.FullMethod, start, err)
+	return err
+}
+
+type loggedStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	method   string
+	received bool
+}
+
+func (s *loggedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *loggedStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil && !s.received {
+		s.received = true
+		if AccountID(s.ctx) == "" {
+			s.ctx = incomingAccountID(s.ctx, s.method, m)
+		}
+	}
+	return err
+}
+
+func incomingRequestID(ctx context.Context) context.Context {
+	if ids := metadata.ValueFromIncomingContext(ctx, requestIDMetadata); len(ids) > 0 && validRequestID(ids[0]) {
+		return WithRequestID(ctx, ids[0])
+	}
+	return WithRequestID(ctx, NewRequestID())
+}
+
+func incomingAccountID(ctx context.Context, method string, req any) context.Context {
+	if ids := metadata.ValueFromIncomingContext(ctx, accountIDMetadata); len(ids) > 0 && validRequestID(ids[0]) {
+		return WithAccountID(ctx, ids[0])
+	}
+	if id := accountIDOf(method, req); id != "" {
+		return WithAccountID(ctx, id)
+	}
+	return ctx
+}
+
+func validRequestID(id string) bool {
+	return id != "" && len(id) <= maxRequestIDLength
+}
+
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("code", code.String()),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+	}
+	slog.LogAttrs(ctx, callLevel(method, code), "call", attrs...)
+}
+
+// callLevel logs failures the server is responsible for as errors and those
+// of the caller as warnings. Health checks are polled, so they are only
+// logged at debug level.
+func callLevel(method string, code codes.Code) slog.Level {
+	switch {
+	case strings.HasPrefix(method, "/grpc.health.v1.Health/"):
+		return slog.LevelDebug
+	case code == codes.OK:
+		return slog.LevelInfo
+	case code == codes.Unknown, code == codes.DeadlineExceeded, code == codes.Unimplemented,
+		code == codes.Internal, code == codes.Unavailable, code == codes.DataLoss:
+		return slog.LevelError
+	default:
+		return slog.LevelWarn
+	}
+}
+
+// UnaryClientRequestID sends the request id and account id in the context,
+// if any, to the service called. Without an account id in the context, the
+// one the request names is sent, and recorded for the log of the request.
+func UnaryClientRequestID(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if AccountID(ctx) == "" {
+		if id := accountIDOf(method, req); id != "" {
+			ctx = WithAccountID(ctx, id)
+		}
+	}
+	return invoker(outgoingRequestID(ctx), method, req, reply, cc, opts...)
+}
+
+// StreamClientRequestID is the stream counterpart of UnaryClientRequestID.
+func StreamClientRequestID(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(outgoingRequestID(ctx), desc, cc, method, opts...)
+}
+
+func outgoingRequestID(ctx context.Context) context.Context {
+	if id := RequestID(ctx); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, requestIDMetadata, id)
+	}
+	if id := AccountID(ctx); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, accountIDMetadata, id)
+	}
+	return ctx
+}
+
+// LogRequests gives every request an id, taken from the X-Request-Id header
+// when the client sends one, and returns it in the same header. Each request
+// is logged once it is done, with its method, path, status and duration, and
+// the account it acted for when the calls it made to the services named one.
+func LogRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = NewRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		log := &requestLog{}
+		ctx := context.WithValue(WithRequestID(r.Context(), id), requestLogKey{}, log)
+
+		rec := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Duration("duration", time.Since(start)),
+		}
+		if id := log.accountID(); id != "" {
+			attrs = append(attrs, slog.String("account_id", id))
+		}
+		slog.LogAttrs(ctx, slog.LevelInfo, "request", attrs...)
+	})
+}
+
+// requestLog collects what the handlers of a request learn about it for its
+// record. Resolvers run concurrently, so it is locked.
+type requestLog struct {
+	mu      sync.Mutex
+	account string
+}
+
+func (l *requestLog) setAccountID(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.account == "" {
+		l.account = id
+	}
+}
+
+func (l *requestLog) accountID() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.account
+}
+
+// statusRecorder remembers the status of a response. It can still be
+// flushed and hijacked, which the subscription transports need.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil && r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
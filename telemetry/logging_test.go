@@ -0,0 +1,269 @@
+package telemetry_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/valkyraycho/go-microservices/telemetry"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// captureLogs makes slog write to a buffer for the rest of the test and
+// returns a function that decodes the records written so far.
+func captureLogs(t *testing.T) func() []map[string]any {
+	t.Helper()
+
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(telemetry.NewLogger(&buf, "test", slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	return func() []map[string]any {
+		var records []map[string]any
+		dec := json.NewDecoder(bytes.NewReader(buf.Bytes()))
+		for dec.More() {
+			var record map[string]any
+			if err := dec.Decode(&record); err != nil {
+				t.Fatal(err)
+			}
+			records = append(records, record)
+		}
+		return records
+	}
+}
+
+type accountRequest struct{ accountID string }
+
+func (r accountRequest) GetAccountId() string { return r.accountID }
+
+// idRequest stands for the requests of the account service, which name the
+// account by id.
+type idRequest struct{ id string }
+
+func (r idRequest) GetId() string { return r.id }
+
+func TestUnaryServerLogging(t *testing.T) {
+	records := captureLogs(t)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "req-1"))
+	info := &grpc.UnaryServerInfo{FullMethod: "/order.OrderService/GetOrdersForAccount"}
+
+	var handled string
+	telemetry.UnaryServerLogging(ctx, accountRequest{"acc-1"}, info, func(ctx context.Context, req any) (any, error) {
+		handled = telemetry.RequestID(ctx)
+		return nil, status.Error(codes.NotFound, "account not found")
+	})
+
+	if handled != "req-1" {
+		t.Errorf("handler saw request id %q, want %q", handled, "req-1")
+	}
+
+	got := records()
+	if len(got) != 1 {
+		t.Fatalf("logged %d records, want 1", len(got))
+	}
+	for key, want := range map[string]any{
+		"level":      "WARN",
+		"msg":        "call",
+		"service":    "test",
+		"method":     info.FullMethod,
+		"code":       "NotFound",
+		"request_id": "req-1",
+		"account_id": "acc-1",
+		"error":      "account not found",
+	} {
+		if got[0][key] != want {
+			t.Errorf("%s = %v, want %v", key, got[0][key], want)
+		}
+	}
+	if _, ok := got[0]["duration"]; !ok {
+		t.Error("record has no duration")
+	}
+}
+
+func TestUnaryServerLoggingNewRequestID(t *testing.T) {
+	records := captureLogs(t)
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/account_service.AccountService/GetAccounts"}
+	var handled string
+	telemetry.UnaryServerLogging(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		handled = telemetry.RequestID(ctx)
+		return nil, nil
+	})
+
+	if handled == "" {
+		t.Fatal("handler saw no request id")
+	}
+	got := records()
+	if got[0]["request_id"] != handled || got[0]["level"] != "INFO" {
+		t.Errorf("record = %v, want an INFO record with request id %s", got[0], handled)
+	}
+	if _, ok := got[0]["account_id"]; ok {
+		t.Errorf("record has an account id for a request without one: %v", got[0])
+	}
+}
+
+func TestUnaryServerLoggingAccountID(t *testing.T) {
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		method string
+		req    any
+	}{
+		{"FromCaller", metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-account-id", "acc-1")),
+			"/catalog_service.CatalogService/GetProducts", nil},
+		{"FromAccountService", context.Background(), "/account_service.AccountService/GetAccount", idRequest{"acc-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := captureLogs(t)
+
+			info := &grpc.UnaryServerInfo{FullMethod: tt.method}
+			telemetry.UnaryServerLogging(tt.ctx, tt.req, info, func(ctx context.Context, req any) (any, error) {
+				slog.InfoContext(ctx, "handling")
+				return nil, nil
+			})
+
+			got := records()
+			if len(got) != 2 {
+				t.Fatalf("logged %d records, want 2", len(got))
+			}
+			for _, record := range got {
+				if record["account_id"] != "acc-1" {
+					t.Errorf("record %v has no account id acc-1", record)
+				}
+			}
+		})
+	}
+
+	// Other services name things other than accounts by id.
+	records := captureLogs(t)
+	info := &grpc.UnaryServerInfo{FullMethod: "/catalog_service.CatalogService/GetProduct"}
+	telemetry.UnaryServerLogging(context.Background(), idRequest{"p1"}, info, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+	if got := records(); got[0]["account_id"] != nil {
+		t.Errorf("record = %v, want no account id", got[0])
+	}
+}
+
+func TestUnaryClientRequestID(t *testing.T) {
+	ctx := telemetry.WithRequestID(context.Background(), "req-1")
+
+	var sent []string
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		sent = md.Get("x-request-id")
+		return nil
+	}
+	if err := telemetry.UnaryClientRequestID(ctx, "/account.AccountService/GetAccount", nil, nil, nil, invoker); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || sent[0] != "req-1" {
+		t.Errorf("sent request ids %v, want [req-1]", sent)
+	}
+}
+
+func TestUnaryClientAccountID(t *testing.T) {
+	var sent []string
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		sent = md.Get("x-account-id")
+		return nil
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		req  any
+		want []string
+	}{
+		{"FromContext", telemetry.WithAccountID(context.Background(), "acc-1"), accountRequest{"acc-2"}, []string{"acc-1"}},
+		{"FromRequest", context.Background(), accountRequest{"acc-2"}, []string{"acc-2"}},
+		{"None", context.Background(), nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := telemetry.UnaryClientRequestID(tt.ctx, "/order_service.OrderService/PostOrder", tt.req, nil, nil, invoker); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(sent, tt.want) {
+				t.Errorf("sent account ids %v, want %v", sent, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogRequestsAccountID(t *testing.T) {
+	records := captureLogs(t)
+
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	h := telemetry.LogRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		telemetry.UnaryClientRequestID(r.Context(), "/account_service.AccountService/GetAccount", idRequest{"acc-1"}, nil, nil, invoker)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/graphql", nil))
+
+	got := records()
+	if len(got) != 1 || got[0]["account_id"] != "acc-1" {
+		t.Errorf("records = %v, want the request logged with account id acc-1", got)
+	}
+}
+
+func TestLogRequests(t *testing.T) {
+	h := telemetry.LogRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Seen", telemetry.RequestID(r.Context()))
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	tests := []struct {
+		name     string
+		sent     string
+		wantSame bool
+	}{
+		{name: "Generated"},
+		{name: "FromClient", sent: "client-id", wantSame: true},
+		{name: "TooLong", sent: strings.Repeat("x", 200)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := captureLogs(t)
+
+			req := httptest.NewRequest("POST", "/graphql", nil)
+			if tt.sent != "" {
+				req.Header.Set(telemetry.RequestIDHeader, tt.sent)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(telemetry.RequestIDHeader)
+			if id == "" || id != rec.Header().Get("X-Seen") {
+				t.Fatalf("response id %q, handler saw %q", id, rec.Header().Get("X-Seen"))
+			}
+			if (id == tt.sent) != tt.wantSame {
+				t.Errorf("request id = %q, sent %q", id, tt.sent)
+			}
+
+			got := records()
+			if len(got) != 1 {
+				t.Fatalf("logged %d records, want 1", len(got))
+			}
+			if got[0]["request_id"] != id || got[0]["path"] != "/graphql" || got[0]["status"] != float64(http.StatusTeapot) {
+				t.Errorf("record = %v", got[0])
+			}
+		})
+	}
+}
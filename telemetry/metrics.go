@@ -0,0 +1,53 @@
+package telemetry
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	grpcprom "github.com/grpc-ecosystem/go-grpc-middleware/providers/prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ServerMetrics and ClientMetrics count the gRPC calls a process serves and
+// makes, by method and status code, and time how long they take. They are
+// registered once per process and shared by every server and client.
+var (
+	ServerMetrics = grpcprom.NewServerMetrics(grpcprom.WithServerHandlingTimeHistogram())
+	ClientMetrics = grpcprom.NewClientMetrics(grpcprom.WithClientHandlingTimeHistogram())
+)
+
+func init() {
+	prometheus.MustRegister(ServerMetrics, ClientMetrics)
+}
+
+// MetricsHandler serves every registered metric in the Prometheus format.
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ListenMetrics serves the metrics at /metrics on its own port, so they are
+// never exposed through a public listener. Other internal handlers, such as
+// health checks, are served next to them at the paths handlers maps them to.
+func ListenMetrics(port int, handlers map[string]http.Handler) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", MetricsHandler())
+	for path, h := range handlers {
+		mux.Handle(path, h)
+	}
+	return http.ListenAndServe(fmt.Sprintf(":%d", port), mux)
+}
+
+// RegisterDBStats reports the connection pool statistics of db, labeled with
+// name. The returned function removes them again and is meant to be called
+// when db is closed. If another pool with the same name is already reported,
+// db is not.
+func RegisterDBStats(db *sql.DB, name string) func() {
+	collector := collectors.NewDBStatsCollector(db, name)
+	if err := prometheus.Register(collector); err != nil {
+		return func() {}
+	}
+	return func() { prometheus.Unregister(collector) }
+}
@@ -0,0 +1,83 @@
+// Package telemetry sets up logging, OpenTelemetry tracing and Prometheus
+// metrics for the services and the gateways. Spans are propagated between
+// processes with W3C trace context, and request ids in gRPC metadata.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/kelseyhightower/envconfig"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Exporters that can be chosen with OTEL_TRACES_EXPORTER.
+const (
+	ExporterNone    = "none"
+	ExporterOTLP    = "otlp"
+	ExporterConsole = "console"
+)
+
+// Config selects where spans are sent and which records are logged. The OTLP
+// exporter also reads the standard OTEL_EXPORTER_OTLP_* variables, such as
+// OTEL_EXPORTER_OTLP_ENDPOINT, and the sampler reads OTEL_TRACES_SAMPLER.
+type Config struct {
+	Exporter string     `envconfig:"OTEL_TRACES_EXPORTER" default:"none"`
+	LogLevel slog.Level `envconfig:"LOG_LEVEL" default:"info"`
+}
+
+// Setup installs the default JSON logger and the global tracer provider and
+// propagator for the named service, configured from the environment. The
+// returned function flushes pending spans and must be called before the
+// process exits.
+func Setup(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	var cfg Config
+	if err := envconfig.Process("", &cfg); err != nil {
+		return nil, err
+	}
+
+	slog.SetDefault(NewLogger(os.Stdout, serviceName, cfg.LogLevel))
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exporter, err = otlptracegrpc.New(ctx)
+	case ExporterConsole:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown traces exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
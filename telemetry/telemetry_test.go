@@ -0,0 +1,77 @@
+package telemetry_test
+
+import (
+	"context"
+	"database/sql"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/valkyraycho/go-microservices/telemetry"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestSetup(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	t.Run("None", func(t *testing.T) {
+		t.Setenv("OTEL_TRACES_EXPORTER", telemetry.ExporterNone)
+		shutdown, err := telemetry.Setup(context.Background(), "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := shutdown(context.Background()); err != nil {
+			t.Errorf("shutdown: %v", err)
+		}
+	})
+
+	t.Run("Console", func(t *testing.T) {
+		t.Setenv("OTEL_TRACES_EXPORTER", telemetry.ExporterConsole)
+		shutdown, err := telemetry.Setup(context.Background(), "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer shutdown(context.Background())
+
+		if _, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider); !ok {
+			t.Errorf("tracer provider = %T, want the SDK provider", otel.GetTracerProvider())
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		t.Setenv("OTEL_TRACES_EXPORTER", "jaeger")
+		if _, err := telemetry.Setup(context.Background(), "test"); err == nil {
+			t.Error("Setup accepted an unknown exporter")
+		}
+	})
+}
+
+func TestRegisterDBStats(t *testing.T) {
+	db, err := sql.Open("postgres", "postgres://localhost/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	series := `go_sql_max_open_connections{db_name="test"}`
+	exported := func() bool {
+		rec := httptest.NewRecorder()
+		telemetry.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		return strings.Contains(rec.Body.String(), series)
+	}
+
+	unregister := telemetry.RegisterDBStats(db, "test")
+	// A second pool with the same name is ignored rather than rejected.
+	telemetry.RegisterDBStats(db, "test")()
+	if !exported() {
+		t.Fatalf("metrics have no series %s", series)
+	}
+
+	unregister()
+	if exported() {
+		t.Errorf("metrics still have %s after unregistering", series)
+	}
+}
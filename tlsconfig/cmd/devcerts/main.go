@@ -0,0 +1,112 @@
+// Command devcerts writes a local CA and a certificate for each service and
+// gateway, for trying out TLS in development:
+//
+//	go run ./tlsconfig/cmd/devcerts -out certs
+//
+// For every name it writes <name>.pem and <name>-key.pem next to ca.pem. A CA
+// already in the directory is reused, so certificates can be reissued while
+// the processes that trust it keep running.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/valkyraycho/go-microservices/tlsconfig"
+)
+
+var defaultNames = []string{
+	"account-service",
+	"catalog-service",
+	"order-service",
+	"graphql-gateway",
+	"rest-gateway",
+}
+
+func main() {
+	out := flag.String("out", "certs", "directory to write the certificates to")
+	validFor := flag.Duration("valid-for", 365*24*time.Hour, "how long the certificates are valid")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: devcerts [flags] [name ...]\n\nNames default to the services and gateways of docker-compose.yaml.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	names := flag.Args()
+	if len(names) == 0 {
+		names = defaultNames
+	}
+
+	if err := run(*out, *validFor, names); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(dir string, validFor time.Duration, names []string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	ca, err := loadCA(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		if ca, err = tlsconfig.NewCA(validFor); err != nil {
+			return err
+		}
+		if err := write(dir, "ca", ca.CertPEM, ca.KeyPEM); err != nil {
+			return err
+		}
+		log.Printf("created a CA in %s", filepath.Join(dir, "ca.pem"))
+	} else if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		cert, key, err := ca.Issue(name, validFor)
+		if err != nil {
+			return err
+		}
+		if err := write(dir, name, cert, key); err != nil {
+			return err
+		}
+		log.Printf("issued %s", filepath.Join(dir, name+".pem"))
+	}
+	return nil
+}
+
+func loadCA(dir string) (*tlsconfig.CA, error) {
+	cert, err := os.ReadFile(filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		return nil, err
+	}
+	key, err := os.ReadFile(filepath.Join(dir, "ca-key.pem"))
+	if err != nil {
+		return nil, err
+	}
+	return tlsconfig.ParseCA(cert, key)
+}
+
+// write writes a key and certificate pair. A process that reloads between the
+// two writes finds a mismatched pair and keeps its old certificate until it
+// checks again.
+func write(dir, name string, cert, key []byte) error {
+	if err := replace(filepath.Join(dir, name+"-key.pem"), key, 0o600); err != nil {
+		return err
+	}
+	return replace(filepath.Join(dir, name+".pem"), cert, 0o644)
+}
+
+// replace swaps in the new file through a rename, so nothing ever reads a
+// half-written one.
+func replace(path string, data []byte, perm os.FileMode) error {
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
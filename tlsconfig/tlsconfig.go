@@ -0,0 +1,244 @@
+// Package tlsconfig loads the certificates that secure the gateways' HTTP
+// listeners and the gRPC links between the gateways and the services, and
+// reloads them when their files change, so certificates can be rotated
+// without a restart.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Config names the PEM files a process loads its certificate, key and CA
+// from. Processes read it from variables with a prefix, such as
+// GRPC_TLS_CERT_FILE for the gRPC links and HTTP_TLS_CERT_FILE for a
+// gateway's listener. Without a certificate TLS is off.
+type Config struct {
+	CertFile string `envconfig:"CERT_FILE"`
+	KeyFile  string `envconfig:"KEY_FILE"`
+	// CAFile holds the CA that signs the peers' certificates. A server
+	// requires clients to present one when it is set, and a client trusts
+	// only that CA instead of the system's.
+	CAFile string `envconfig:"CA_FILE"`
+	// AllowedPeers are the names clients must have among the DNS SANs of
+	// their certificates. When it is empty, any client with a certificate
+	// signed by the CA is accepted.
+	AllowedPeers []string `envconfig:"ALLOWED_PEERS"`
+}
+
+// Enabled reports whether c turns TLS on.
+func (c Config) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// ReloadInterval is how often, at most, the files are checked for changes.
+// Checks happen during handshakes, so idle processes do not poll.
+var ReloadInterval = 5 * time.Second
+
+// Certificates holds the certificate and CA loaded from a Config, reloading
+// them when the files change. A reload that fails is logged and the previous
+// certificates stay in use.
+type Certificates struct {
+	cfg Config
+
+	mu      sync.Mutex
+	checked time.Time
+	stamps  []fileStamp
+	cert    *tls.Certificate
+	roots   *x509.CertPool
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Load reads the files named by cfg.
+func Load(cfg Config) (*Certificates, error) {
+	if !cfg.Enabled() || cfg.KeyFile == "" {
+		return nil, errors.New("tlsconfig: a certificate and a key file are required")
+	}
+	c := &Certificates{cfg: cfg}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	c.checked = time.Now()
+	return c, nil
+}
+
+func (c *Certificates) files() []string {
+	files := []string{c.cfg.CertFile, c.cfg.KeyFile}
+	if c.cfg.CAFile != "" {
+		files = append(files, c.cfg.CAFile)
+	}
+	return files
+}
+
+func (c *Certificates) load() error {
+	stamps, err := c.stat()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.cfg.CertFile, c.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("tlsconfig: %w", err)
+	}
+
+	var roots *x509.CertPool
+	if c.cfg.CAFile != "" {
+		pem, err := os.ReadFile(c.cfg.CAFile)
+		if err != nil {
+			return fmt.Errorf("tlsconfig: %w", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("tlsconfig: no certificates in %s", c.cfg.CAFile)
+		}
+	}
+
+	c.cert, c.roots, c.stamps = &cert, roots, stamps
+	return nil
+}
+
+func (c *Certificates) stat() ([]fileStamp, error) {
+	var stamps []fileStamp
+	for _, name := range c.files() {
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, fmt.Errorf("tlsconfig: %w", err)
+		}
+		stamps = append(stamps, fileStamp{info.ModTime(), info.Size()})
+	}
+	return stamps, nil
+}
+
+// current returns the certificate and CA to use for a handshake, reloading
+// them first if the files changed since they were last checked.
+func (c *Certificates) current() (*tls.Certificate, *x509.CertPool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.checked) >= ReloadInterval {
+		c.checked = time.Now()
+		if stamps, err := c.stat(); err != nil || !slices.Equal(stamps, c.stamps) {
+			if err := c.load(); err != nil {
+				slog.Error("reloading certificates", "err", err)
+			} else {
+				slog.Info("reloaded certificates", "cert", c.cfg.CertFile)
+			}
+		}
+	}
+	return c.cert, c.roots
+}
+
+// ServerConfig returns the TLS config of a server. When a CA is configured,
+// clients must present a certificate signed by it whose SANs name one of the
+// allowed peers.
+func (c *Certificates) ServerConfig() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, _ := c.current()
+			return cert, nil
+		},
+	}
+	if c.cfg.CAFile != "" {
+		// The CA can change, so clients are verified against the current
+		// one rather than a pool fixed in the config.
+		cfg.ClientAuth = tls.RequireAnyClientCert
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			_, roots := c.current()
+			return verifyPeer(cs, roots, x509.ExtKeyUsageClientAuth, "", c.cfg.AllowedPeers)
+		}
+	}
+	return cfg
+}
+
+// ClientConfig returns the TLS config of a client, which presents the
+// certificate and verifies that the server's SANs include the host it dialed.
+func (c *Certificates) ClientConfig() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := c.current()
+			return cert, nil
+		},
+	}
+	if c.cfg.CAFile != "" {
+		// Verification is done in VerifyConnection against the current CA,
+		// including the check of the server name. AllowedPeers only
+		// restricts the clients of a server.
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			_, roots := c.current()
+			return verifyPeer(cs, roots, x509.ExtKeyUsageServerAuth, cs.ServerName, nil)
+		}
+	}
+	return cfg
+}
+
+func verifyPeer(cs tls.ConnectionState, roots *x509.CertPool, usage x509.ExtKeyUsage, name string, allowed []string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tlsconfig: peer sent no certificate")
+	}
+	leaf := cs.PeerCertificates[0]
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		DNSName:       name,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	})
+	if err != nil {
+		return fmt.Errorf("tlsconfig: %w", err)
+	}
+
+	if len(allowed) > 0 && !slices.ContainsFunc(leaf.DNSNames, func(san string) bool {
+		return slices.Contains(allowed, san)
+	}) {
+		return fmt.Errorf("tlsconfig: peer %v is not allowed", leaf.DNSNames)
+	}
+	return nil
+}
+
+// ServerOption returns the credentials of a gRPC server: mutual TLS when cfg
+// is enabled, plaintext otherwise.
+func ServerOption(cfg Config) (grpc.ServerOption, error) {
+	if !cfg.Enabled() {
+		return grpc.Creds(insecure.NewCredentials()), nil
+	}
+	certs, err := Load(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return grpc.Creds(credentials.NewTLS(certs.ServerConfig())), nil
+}
+
+// DialOption returns the credentials of a gRPC client: mutual TLS when cfg
+// is enabled, plaintext otherwise.
+func DialOption(cfg Config) (grpc.DialOption, error) {
+	if !cfg.Enabled() {
+		return grpc.WithTransportCredentials(insecure.NewCredentials()), nil
+	}
+	certs, err := Load(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(certs.ClientConfig())), nil
+}
@@ -0,0 +1,209 @@
+package tlsconfig_test
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/valkyraycho/go-microservices/health"
+	"github.com/valkyraycho/go-microservices/tlsconfig"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// issue writes a certificate for name signed by ca into dir, next to the CA
+// certificate, and returns the config that loads them.
+func issue(t *testing.T, dir string, ca *tlsconfig.CA, name string) tlsconfig.Config {
+	t.Helper()
+
+	cert, key, err := ca.Issue(name, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := tlsconfig.Config{
+		CertFile: filepath.Join(dir, name+".pem"),
+		KeyFile:  filepath.Join(dir, name+"-key.pem"),
+		CAFile:   filepath.Join(dir, "ca.pem"),
+	}
+	for file, data := range map[string][]byte{cfg.CertFile: cert, cfg.KeyFile: key, cfg.CAFile: ca.CertPEM} {
+		if err := os.WriteFile(file, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cfg
+}
+
+func newCA(t *testing.T) *tlsconfig.CA {
+	t.Helper()
+	ca, err := tlsconfig.NewCA(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca
+}
+
+// ping serves the health service with the server config over bufconn and
+// pings it as target with the client config.
+func ping(t *testing.T, server, client tlsconfig.Config, target string) error {
+	t.Helper()
+
+	serverCreds, err := tlsconfig.ServerOption(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(serverCreds)
+	health.Register(s, nil)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	dialCreds, err := tlsconfig.DialOption(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := grpc.NewClient("passthrough:///"+target, dialCreds,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return health.Ping(ctx, conn, "")
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t)
+
+	server := issue(t, dir, ca, "account-service")
+	server.AllowedPeers = []string{"order-service", "graphql-gateway"}
+	order := issue(t, dir, ca, "order-service")
+	rest := issue(t, dir, ca, "rest-gateway")
+
+	otherDir := t.TempDir()
+	impostor := issue(t, otherDir, newCA(t), "order-service")
+	impostor.CAFile = server.CAFile
+
+	tests := []struct {
+		name    string
+		client  tlsconfig.Config
+		target  string
+		wantErr bool
+	}{
+		{name: "AllowedPeer", client: order, target: "account-service"},
+		{name: "PeerNotAllowed", client: rest, target: "account-service", wantErr: true},
+		{name: "OtherCA", client: impostor, target: "account-service", wantErr: true},
+		{name: "WrongServerName", client: order, target: "catalog-service", wantErr: true},
+		{name: "Plaintext", client: tlsconfig.Config{}, target: "account-service", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ping(t, server, tt.client, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Ping error = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlaintext(t *testing.T) {
+	if err := ping(t, tlsconfig.Config{}, tlsconfig.Config{}, "account-service"); err != nil {
+		t.Errorf("Ping without TLS: %v", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	prev := tlsconfig.ReloadInterval
+	tlsconfig.ReloadInterval = 0
+	t.Cleanup(func() { tlsconfig.ReloadInterval = prev })
+
+	dir := t.TempDir()
+	ca := newCA(t)
+	cfg := issue(t, dir, ca, "graphql-gateway")
+	cfg.CAFile = ""
+
+	certs, err := tlsconfig.Load(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig := certs.ServerConfig()
+
+	// served returns the serial number of the certificate the server
+	// presents in a new handshake.
+	served := func() string {
+		t.Helper()
+		serverConn, clientConn := net.Pipe()
+		defer serverConn.Close()
+		defer clientConn.Close()
+
+		go tls.Server(serverConn, serverConfig).Handshake()
+		client := tls.Client(clientConn, &tls.Config{InsecureSkipVerify: true})
+		if err := client.Handshake(); err != nil {
+			t.Fatal(err)
+		}
+		return client.ConnectionState().PeerCertificates[0].SerialNumber.String()
+	}
+
+	before := served()
+	if again := served(); again != before {
+		t.Fatalf("certificate changed from %s to %s without a new file", before, again)
+	}
+
+	// Make sure the new files get a different modification time.
+	time.Sleep(10 * time.Millisecond)
+	issue(t, dir, ca, "graphql-gateway")
+	if after := served(); after == before {
+		t.Error("server still presents the old certificate after it was replaced")
+	}
+
+	// A broken file is not loaded, and the last good certificate stays.
+	if err := os.WriteFile(cfg.CertFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	served()
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	cfg := issue(t, dir, newCA(t), "order-service")
+
+	tests := map[string]tlsconfig.Config{
+		"NoKey":       {CertFile: cfg.CertFile},
+		"MissingFile": {CertFile: filepath.Join(dir, "missing.pem"), KeyFile: cfg.KeyFile},
+		"KeyAsCA":     {CertFile: cfg.CertFile, KeyFile: cfg.KeyFile, CAFile: cfg.KeyFile},
+	}
+	for name, cfg := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := tlsconfig.Load(cfg); err == nil {
+				t.Error("Load succeeded")
+			}
+		})
+	}
+}
+
+func TestParseCA(t *testing.T) {
+	ca := newCA(t)
+	parsed, err := tlsconfig.ParseCA(ca.CertPEM, ca.KeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Certificates issued by the parsed CA are trusted by peers of the
+	// original one.
+	dir := t.TempDir()
+	server := issue(t, dir, ca, "catalog-service")
+	client := issue(t, dir, parsed, "order-service")
+	if err := ping(t, server, client, "catalog-service"); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+}